@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/metrics"
+)
+
+var (
+	apiRequests = metrics.NewCounter("swan_api_requests_total",
+		"API requests, by method, route and status code.", "method", "route", "code")
+	apiRequestDuration = metrics.NewHistogram("swan_api_request_duration_seconds",
+		"Latency of API requests, by method and route.", metrics.DefBuckets, "method", "route")
+)
+
+// statusRecorder keeps the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush passes flushes on, event streams depend on them.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// instrument counts the requests of the route registered for method and
+// path and observes their latency.
+func instrument(method, path string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+
+		handler(rec, r)
+
+		apiRequestDuration.Since(start, method, path)
+		apiRequests.Inc(method, path, strconv.Itoa(rec.code))
+	}
+}
@@ -0,0 +1,44 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/api/utils"
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+// Backup streams a snapshot of the store, which can be restored with the
+// --restore flag of swan.
+func (r *Router) Backup(w http.ResponseWriter, req *http.Request) error {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="swan-%s.db"`, time.Now().Format("20060102150405")))
+
+	return r.backend.Backup(w)
+}
+
+// Export is used to export the definitions of all applications.
+func (r *Router) Export(w http.ResponseWriter, req *http.Request) error {
+	export, err := r.backend.Export()
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(export)
+}
+
+// Import is used to create the applications of an export.
+func (r *Router) Import(w http.ResponseWriter, req *http.Request) error {
+	if err := utils.CheckForJSON(req); err != nil {
+		return err
+	}
+
+	var export types.Export
+	if err := json.NewDecoder(req.Body).Decode(&export); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(r.backend.Import(utils.Actor(req), &export))
+}
@@ -0,0 +1,19 @@
+package admin
+
+import (
+	"io"
+
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+type Backend interface {
+	// Backup writes a consistent snapshot of the store.
+	Backup(io.Writer) error
+
+	// Export returns the definitions of all applications and their versions.
+	Export() (*types.Export, error)
+
+	// Import creates and launches the applications of an export on behalf
+	// of an actor.
+	Import(string, *types.Export) []*types.ImportResult
+}
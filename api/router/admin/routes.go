@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"github.com/Dataman-Cloud/swan/api/router"
+)
+
+type Router struct {
+	routes  []*router.Route
+	backend Backend
+}
+
+// NewRouter initializes a new admin router.
+func NewRouter(b Backend) *Router {
+	r := &Router{
+		backend: b,
+	}
+
+	r.initRoutes()
+	return r
+}
+
+func (r *Router) Routes() []*router.Route {
+	return r.routes
+}
+
+func (r *Router) initRoutes() {
+	r.routes = []*router.Route{
+		router.NewRoute("GET", "/v1/admin/backup", r.Backup),
+		router.NewRoute("GET", "/v1/admin/export", r.Export),
+		router.NewRoute("POST", "/v1/admin/import", r.Import),
+	}
+}
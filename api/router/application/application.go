@@ -29,14 +29,6 @@ func (r *Router) BuildApplication(w http.ResponseWriter, req *http.Request) erro
 		return err
 	}
 
-	app, err := r.backend.FetchApplication(version.ID)
-	if err != nil {
-		return err
-	}
-	if app != nil {
-		return errors.New("Applicaiton Id Duplicated")
-	}
-
 	user := req.Form.Get("user")
 	if user == "" {
 		user = "default"
@@ -56,15 +48,11 @@ func (r *Router) BuildApplication(w http.ResponseWriter, req *http.Request) erro
 		Updated:           time.Now().Unix(),
 	}
 
-	if err := r.backend.SaveApplication(&application); err != nil {
-		return err
-	}
-
-	if err := r.backend.SaveVersion(version.ID, &version); err != nil {
+	if err := r.backend.CreateApplication(utils.Actor(req), &application, &version); err != nil {
 		return err
 	}
 
-	if err := r.backend.LaunchApplication(&version); err != nil {
+	if err := r.backend.LaunchApplication(utils.Actor(req), &version); err != nil {
 		logrus.Infof("Launch application %s failed with error: %s", version.ID, err.Error())
 		return err
 	}
@@ -98,7 +86,32 @@ func (r *Router) FetchApplication(w http.ResponseWriter, req *http.Request) erro
 func (r *Router) DeleteApplication(w http.ResponseWriter, req *http.Request) error {
 	vars := mux.Vars(req)
 
-	if err := r.backend.DeleteApplication(vars["appId"]); err != nil {
+	if err := r.backend.DeleteApplication(utils.Actor(req), vars["appId"]); err != nil {
+		return err
+	}
+
+	// The deletion has been queued only.
+	w.WriteHeader(http.StatusAccepted)
+
+	return nil
+}
+
+// PauseFailover is used to stop replacing the tasks of application whose health checks failed.
+func (r *Router) PauseFailover(w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	if err := r.backend.PauseFailover(utils.Actor(req), vars["appId"]); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ResumeFailover is used to replace the tasks of application whose health checks failed again.
+func (r *Router) ResumeFailover(w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	if err := r.backend.ResumeFailover(utils.Actor(req), vars["appId"]); err != nil {
 		return err
 	}
 
@@ -114,6 +127,17 @@ func (r *Router) ListApplicationTasks(w http.ResponseWriter, req *http.Request)
 		return err
 	}
 
+	// Service discovery lists the running instances which are ready only.
+	if ready, _ := strconv.ParseBool(req.URL.Query().Get("ready")); ready {
+		readyTasks := []*types.Task{}
+		for _, task := range tasks {
+			if task.Ready && task.Status == "RUNNING" {
+				readyTasks = append(readyTasks, task)
+			}
+		}
+		tasks = readyTasks
+	}
+
 	return json.NewEncoder(w).Encode(tasks)
 }
 
@@ -121,21 +145,39 @@ func (r *Router) ListApplicationTasks(w http.ResponseWriter, req *http.Request)
 func (r *Router) DeleteApplicationTasks(w http.ResponseWriter, req *http.Request) error {
 	vars := mux.Vars(req)
 
-	if err := r.backend.DeleteApplicationTasks(vars["appId"]); err != nil {
+	if err := r.backend.DeleteApplicationTasks(utils.Actor(req), vars["appId"]); err != nil {
 		return err
 	}
 
+	// The deletion has been queued only.
+	w.WriteHeader(http.StatusAccepted)
+
 	return nil
 }
 
+// FetchTaskHealth is used to fetch the health of a task and the state of its checks.
+func (r *Router) FetchTaskHealth(w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	health, err := r.backend.FetchTaskHealth(vars["appId"], vars["taskId"])
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(health)
+}
+
 // DeleteApplicationTask is used to delete specified task belong to application via application id and task id.
 func (r *Router) DeleteApplicationTask(w http.ResponseWriter, req *http.Request) error {
 	vars := mux.Vars(req)
 
-	if err := r.backend.DeleteApplicationTask(vars["appId"], vars["taskId"]); err != nil {
+	if err := r.backend.DeleteApplicationTask(utils.Actor(req), vars["appId"], vars["taskId"]); err != nil {
 		return err
 	}
 
+	// The deletion has been queued only.
+	w.WriteHeader(http.StatusAccepted)
+
 	return nil
 }
 
@@ -187,11 +229,11 @@ func (r *Router) UpdateApplication(w http.ResponseWriter, req *http.Request) err
 
 	vars := mux.Vars(req)
 
-	if err := r.backend.SaveVersion(vars["appId"], &version); err != nil {
+	if err := r.backend.SaveVersion(utils.Actor(req), vars["appId"], &version); err != nil {
 		return err
 	}
 
-	if err := r.backend.UpdateApplication(vars["appId"], instances, &version); err != nil {
+	if err := r.backend.UpdateApplication(utils.Actor(req), vars["appId"], instances, &version); err != nil {
 		return err
 	}
 
@@ -211,7 +253,7 @@ func (r *Router) ScaleApplication(w http.ResponseWriter, req *http.Request) erro
 
 	vars := mux.Vars(req)
 
-	if err := r.backend.ScaleApplication(vars["appId"], instances); err != nil {
+	if err := r.backend.ScaleApplication(utils.Actor(req), vars["appId"], instances); err != nil {
 		return err
 	}
 
@@ -222,9 +264,21 @@ func (r *Router) ScaleApplication(w http.ResponseWriter, req *http.Request) erro
 func (r *Router) RollbackApplication(w http.ResponseWriter, req *http.Request) error {
 	vars := mux.Vars(req)
 
-	if err := r.backend.RollbackApplication(vars["appId"]); err != nil {
+	if err := r.backend.RollbackApplication(utils.Actor(req), vars["appId"]); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// ListApplicationDeployments is used to list the deployment history of application.
+func (r *Router) ListApplicationDeployments(w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	deployments, err := r.backend.ListApplicationDeployments(vars["appId"])
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(deployments)
+}
@@ -4,22 +4,28 @@ import (
 	"github.com/Dataman-Cloud/swan/types"
 )
 
+// Backend is the backend of the application routes. The first argument of
+// the methods which change applications is the actor recorded in the audit
+// log.
 type Backend interface {
 	ClusterId() string
 	// RegisterApplication register application in consul.
 	SaveApplication(*types.Application) error
 
+	// CreateApplication saves a new application and its first version at once.
+	CreateApplication(string, *types.Application, *types.Version) error
+
 	// RegisterApplicationVersion register application version in consul.
-	SaveVersion(string, *types.Version) error
+	SaveVersion(string, string, *types.Version) error
 
 	// LaunchApplication launch applications
-	LaunchApplication(*types.Version) error
+	LaunchApplication(string, *types.Version) error
 
 	// DeleteApplication will delete all data associated with application.
-	DeleteApplication(string) error
+	DeleteApplication(string, string) error
 
 	// DeleteApplicationTasks delete all tasks belong to appcaiton but keep that application exists.
-	DeleteApplicationTasks(string) error
+	DeleteApplicationTasks(string, string) error
 
 	ListApplications() ([]*types.Application, error)
 
@@ -27,15 +33,26 @@ type Backend interface {
 
 	ListApplicationTasks(string) ([]*types.Task, error)
 
-	DeleteApplicationTask(string, string) error
+	DeleteApplicationTask(string, string, string) error
+
+	// FetchTaskHealth returns the health of a task and its checks.
+	FetchTaskHealth(string, string) (*types.TaskHealth, error)
 
 	ListApplicationVersions(string) ([]string, error)
 
 	FetchApplicationVersion(string, string) (*types.Version, error)
 
-	UpdateApplication(string, int, *types.Version) error
+	UpdateApplication(string, string, int, *types.Version) error
+
+	ScaleApplication(string, string, int) error
+
+	RollbackApplication(string, string) error
 
-	ScaleApplication(string, int) error
+	// PauseFailover and ResumeFailover stop and restart the replacement of
+	// the tasks of an application whose health checks failed.
+	PauseFailover(string, string) error
+	ResumeFailover(string, string) error
 
-	RollbackApplication(string) error
+	// ListApplicationDeployments list all deployments of application.
+	ListApplicationDeployments(string) ([]*types.Deployment, error)
 }
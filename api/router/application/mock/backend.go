@@ -14,19 +14,23 @@ func (b *Backend) SaveApplication(app *types.Application) error {
 	return nil
 }
 
-func (b *Backend) SaveVersion(app string, version *types.Version) error {
+func (b *Backend) CreateApplication(actor string, app *types.Application, version *types.Version) error {
 	return nil
 }
 
-func (b *Backend) LaunchApplication(version *types.Version) error {
+func (b *Backend) SaveVersion(actor, app string, version *types.Version) error {
 	return nil
 }
 
-func (b *Backend) DeleteApplication(appId string) error {
+func (b *Backend) LaunchApplication(actor string, version *types.Version) error {
 	return nil
 }
 
-func (b *Backend) DeleteApplicationTasks(appId string) error {
+func (b *Backend) DeleteApplication(actor, appId string) error {
+	return nil
+}
+
+func (b *Backend) DeleteApplicationTasks(actor, appId string) error {
 	return nil
 }
 
@@ -42,7 +46,7 @@ func (b *Backend) ListApplicationTasks(appId string) ([]*types.Task, error) {
 	return nil, nil
 }
 
-func (b *Backend) DeleteApplicationTask(appId string, taskId string) error {
+func (b *Backend) DeleteApplicationTask(actor, appId string, taskId string) error {
 	return nil
 }
 
@@ -54,14 +58,30 @@ func (b *Backend) FetchApplicationVersion(appId string, versionId string) (*type
 	return nil, nil
 }
 
-func (b *Backend) UpdateApplication(string, int, *types.Version) error {
+func (b *Backend) UpdateApplication(string, string, int, *types.Version) error {
+	return nil
+}
+
+func (b *Backend) ScaleApplication(actor, appId string, instances int) error {
 	return nil
 }
 
-func (b *Backend) ScaleApplication(appId string, instances int) error {
+func (b *Backend) RollbackApplication(actor, appId string) error {
+	return nil
+}
+
+func (b *Backend) ListApplicationDeployments(appId string) ([]*types.Deployment, error) {
+	return nil, nil
+}
+
+func (b *Backend) FetchTaskHealth(appId, taskId string) (*types.TaskHealth, error) {
+	return nil, nil
+}
+
+func (b *Backend) PauseFailover(actor, appId string) error {
 	return nil
 }
 
-func (b *Backend) RollbackApplication(appId string) error {
+func (b *Backend) ResumeFailover(actor, appId string) error {
 	return nil
 }
@@ -32,12 +32,17 @@ func (r *Router) initRoutes() {
 		router.NewRoute("POST", "/v1/apps/{appId}/update", r.UpdateApplication),
 		router.NewRoute("POST", "/v1/apps/{appId}/scale", r.ScaleApplication),
 		router.NewRoute("POST", "/v1/apps/{appId}/rollback", r.RollbackApplication),
+		router.NewRoute("POST", "/v1/apps/{appId}/failover/pause", r.PauseFailover),
+		router.NewRoute("POST", "/v1/apps/{appId}/failover/resume", r.ResumeFailover),
 
 		router.NewRoute("GET", "/v1/apps/{appId}/tasks", r.ListApplicationTasks),
 		router.NewRoute("DELETE", "/v1/apps/{appId}/tasks", r.DeleteApplicationTasks),
 		router.NewRoute("DELETE", "/v1/apps/{appId}/tasks/{taskId}", r.DeleteApplicationTask),
+		router.NewRoute("GET", "/v1/apps/{appId}/tasks/{taskId}/health", r.FetchTaskHealth),
 
 		router.NewRoute("GET", "/v1/apps/{appId}/versions", r.ListApplicationVersions),
 		router.NewRoute("GET", "/v1/apps/{appId}/versions/{versionId}", r.FetchApplicationVersion),
+
+		router.NewRoute("GET", "/v1/apps/{appId}/deployments", r.ListApplicationDeployments),
 	}
 }
@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+// ListAuditEntries is used to query the audit log. The entries can be
+// selected by application, actor prefix, time since given as unix time or
+// RFC 3339, and limited to the newest ones.
+func (r *Router) ListAuditEntries(w http.ResponseWriter, req *http.Request) error {
+	if err := req.ParseForm(); err != nil {
+		return err
+	}
+
+	query := &types.AuditQuery{
+		AppID: req.Form.Get("app"),
+		Actor: req.Form.Get("actor"),
+	}
+
+	if v := req.Form.Get("since"); v != "" {
+		since, err := parseTime(v)
+		if err != nil {
+			return err
+		}
+		query.Since = since
+	}
+
+	if v := req.Form.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("Invalid limit %s", v)
+		}
+		query.Limit = limit
+	}
+
+	entries, err := r.backend.ListAuditEntries(query)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(entries)
+}
+
+// parseTime parses a unix time or a RFC 3339 time.
+func parseTime(v string) (int64, error) {
+	if unix, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return unix, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time %s, expected unix time or RFC 3339", v)
+	}
+
+	return t.Unix(), nil
+}
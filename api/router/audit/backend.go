@@ -0,0 +1,10 @@
+package audit
+
+import (
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+type Backend interface {
+	// ListAuditEntries lists the audit entries selected by a query, oldest first.
+	ListAuditEntries(*types.AuditQuery) ([]*types.AuditEntry, error)
+}
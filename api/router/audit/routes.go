@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"github.com/Dataman-Cloud/swan/api/router"
+)
+
+type Router struct {
+	routes  []*router.Route
+	backend Backend
+}
+
+// NewRouter initializes a new audit router.
+func NewRouter(b Backend) *Router {
+	r := &Router{
+		backend: b,
+	}
+
+	r.initRoutes()
+	return r
+}
+
+func (r *Router) Routes() []*router.Route {
+	return r.routes
+}
+
+func (r *Router) initRoutes() {
+	r.routes = []*router.Route{
+		router.NewRoute("GET", "/v1/audit", r.ListAuditEntries),
+	}
+}
@@ -0,0 +1,11 @@
+package converge
+
+import (
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+type Backend interface {
+	// Converge runs one convergence pass on behalf of an actor, only reports
+	// the actions if dry run.
+	Converge(string, bool) ([]*types.ConvergeAction, error)
+}
@@ -0,0 +1,41 @@
+package converge
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Dataman-Cloud/swan/api/utils"
+)
+
+// PlanConverge is used to show the actions a convergence pass would take.
+func (r *Router) PlanConverge(w http.ResponseWriter, req *http.Request) error {
+	actions, err := r.backend.Converge(utils.Actor(req), true)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(actions)
+}
+
+// Converge is used to trigger a convergence pass immediately.
+func (r *Router) Converge(w http.ResponseWriter, req *http.Request) error {
+	if err := req.ParseForm(); err != nil {
+		return err
+	}
+
+	dryRun := false
+	if v := req.Form.Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			return err
+		}
+	}
+
+	actions, err := r.backend.Converge(utils.Actor(req), dryRun)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(actions)
+}
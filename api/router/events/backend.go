@@ -0,0 +1,12 @@
+package events
+
+import (
+	"github.com/Dataman-Cloud/swan/event"
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+type Backend interface {
+	// Subscribe subscribes to the events selected by a filter and returns
+	// the kept events published after the given event id.
+	Subscribe(event.Filter, uint64) (*event.Subscription, []*types.Event)
+}
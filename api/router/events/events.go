@@ -0,0 +1,99 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/event"
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+// keepAliveInterval is how often a comment is sent on an idle stream, so
+// proxies in between do not close it.
+const keepAliveInterval = 15 * time.Second
+
+// StreamEvents is used to stream events as server-sent events. Events can
+// be filtered by application and by a comma separated list of types. A
+// client which reconnects with Last-Event-ID first receives the kept events
+// it missed.
+func (r *Router) StreamEvents(w http.ResponseWriter, req *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("Streaming is not supported")
+	}
+
+	if err := req.ParseForm(); err != nil {
+		return err
+	}
+
+	filter := event.Filter{AppID: req.Form.Get("app")}
+	if v := req.Form.Get("type"); v != "" {
+		filter.Types = strings.Split(v, ",")
+	}
+
+	var lastID uint64
+	if v := req.Header.Get("Last-Event-ID"); v != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return fmt.Errorf("Invalid Last-Event-ID %s", v)
+		}
+	}
+
+	sub, replay := r.backend.Subscribe(filter, lastID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for _, e := range replay {
+		if err := writeEvent(w, e); err != nil {
+			return nil
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			// The subscription fell behind and was dropped, the client
+			// catches up when it reconnects.
+			if !ok {
+				return nil
+			}
+
+			if err := writeEvent(w, e); err != nil {
+				return nil
+			}
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		case <-req.Context().Done():
+			return nil
+		}
+
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes e in the server-sent events format.
+func writeEvent(w io.Writer, e *types.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+
+	return err
+}
@@ -0,0 +1,10 @@
+package orphan
+
+import (
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+type Backend interface {
+	// ListOrphans lists tasks running under the framework which swan lost track of.
+	ListOrphans() ([]*types.Orphan, error)
+}
@@ -0,0 +1,16 @@
+package orphan
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ListOrphans is used to list orphan tasks.
+func (r *Router) ListOrphans(w http.ResponseWriter, req *http.Request) error {
+	orphans, err := r.backend.ListOrphans()
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(orphans)
+}
@@ -0,0 +1,25 @@
+package subscriptions
+
+import (
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+type Backend interface {
+	// CreateSubscription registers a callback URL for the selected events.
+	CreateSubscription(string, *types.Subscription) error
+
+	// ListSubscriptions lists the subscriptions, without their secrets.
+	ListSubscriptions() ([]*types.Subscription, error)
+
+	// FetchSubscription fetches a subscription, without its secret.
+	FetchSubscription(string) (*types.Subscription, error)
+
+	// DeleteSubscription deletes a subscription and its dead letters.
+	DeleteSubscription(string, string) error
+
+	// ListDeadLetters lists the events which could not be delivered to a subscription.
+	ListDeadLetters(string) ([]*types.DeadLetter, error)
+
+	// DeleteDeadLetters clears the dead letters of a subscription.
+	DeleteDeadLetters(string) error
+}
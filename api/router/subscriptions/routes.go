@@ -0,0 +1,35 @@
+package subscriptions
+
+import (
+	"github.com/Dataman-Cloud/swan/api/router"
+)
+
+type Router struct {
+	routes  []*router.Route
+	backend Backend
+}
+
+// NewRouter initializes a new subscriptions router.
+func NewRouter(b Backend) *Router {
+	r := &Router{
+		backend: b,
+	}
+
+	r.initRoutes()
+	return r
+}
+
+func (r *Router) Routes() []*router.Route {
+	return r.routes
+}
+
+func (r *Router) initRoutes() {
+	r.routes = []*router.Route{
+		router.NewRoute("POST", "/v1/subscriptions", r.CreateSubscription),
+		router.NewRoute("GET", "/v1/subscriptions", r.ListSubscriptions),
+		router.NewRoute("GET", "/v1/subscriptions/{subscriptionId}", r.FetchSubscription),
+		router.NewRoute("DELETE", "/v1/subscriptions/{subscriptionId}", r.DeleteSubscription),
+		router.NewRoute("GET", "/v1/subscriptions/{subscriptionId}/deadletters", r.ListDeadLetters),
+		router.NewRoute("DELETE", "/v1/subscriptions/{subscriptionId}/deadletters", r.DeleteDeadLetters),
+	}
+}
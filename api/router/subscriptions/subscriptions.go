@@ -0,0 +1,81 @@
+package subscriptions
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Dataman-Cloud/swan/api/utils"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/gorilla/mux"
+)
+
+// CreateSubscription is used to register a callback URL to which the
+// selected events are posted.
+func (r *Router) CreateSubscription(w http.ResponseWriter, req *http.Request) error {
+	if err := utils.CheckForJSON(req); err != nil {
+		return err
+	}
+
+	var subscription types.Subscription
+	if err := json.NewDecoder(req.Body).Decode(&subscription); err != nil {
+		return err
+	}
+
+	if err := r.backend.CreateSubscription(utils.Actor(req), &subscription); err != nil {
+		return err
+	}
+
+	subscription.Secret = ""
+
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(subscription)
+}
+
+// ListSubscriptions is used to list all subscriptions.
+func (r *Router) ListSubscriptions(w http.ResponseWriter, req *http.Request) error {
+	subscriptions, err := r.backend.ListSubscriptions()
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(subscriptions)
+}
+
+// FetchSubscription is used to fetch a subscription via subscription id.
+func (r *Router) FetchSubscription(w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	subscription, err := r.backend.FetchSubscription(vars["subscriptionId"])
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(subscription)
+}
+
+// DeleteSubscription is used to delete a subscription via subscription id.
+func (r *Router) DeleteSubscription(w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	return r.backend.DeleteSubscription(utils.Actor(req), vars["subscriptionId"])
+}
+
+// ListDeadLetters is used to list the events which could not be delivered
+// to a subscription.
+func (r *Router) ListDeadLetters(w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	letters, err := r.backend.ListDeadLetters(vars["subscriptionId"])
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(letters)
+}
+
+// DeleteDeadLetters is used to clear the dead letters of a subscription.
+func (r *Router) DeleteDeadLetters(w http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+
+	return r.backend.DeleteDeadLetters(vars["subscriptionId"])
+}
@@ -2,15 +2,37 @@ package api
 
 import (
 	"github.com/Dataman-Cloud/swan/api/router"
+	"github.com/Dataman-Cloud/swan/api/utils"
+	"github.com/Dataman-Cloud/swan/metrics"
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
 )
 
+// Leader tells whether requests are served by this instance or forwarded
+// to the leader of the cluster.
+type Leader interface {
+	// IsLeader reports whether this instance is the leader.
+	IsLeader() bool
+
+	// Ready reports whether the leader has recovered and serves requests.
+	Ready() bool
+
+	// LeaderAddr returns the API address of the leader.
+	LeaderAddr() string
+
+	// IsPeer reports whether host belongs to a member of the cluster.
+	IsPeer(host string) bool
+}
+
 type Server struct {
 	addr    string
 	routers []router.Router
+	leader  Leader
 }
 
 func NewServer(addr string) *Server {
@@ -26,19 +48,40 @@ func (s *Server) createMux() *mux.Router {
 	logrus.Debug("Registering routers")
 	for _, router := range s.routers {
 		for _, r := range router.Routes() {
-			f := s.makeHTTPHandler(r.Handler())
+			f := instrument(r.Method(), r.Path(), s.makeHTTPHandler(r.Handler()))
 
 			logrus.Debugf("Registering %s, %s", r.Method(), r.Path())
 			m.Path(r.Path()).Methods(r.Method()).Handler(f)
 		}
 	}
 
+	// Metrics are those of this instance, they are never forwarded.
+	m.Path("/metrics").Methods("GET").Handler(metrics.Handler())
+
 	return m
 }
 
 func (s *Server) makeHTTPHandler(handler router.APIFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logrus.WithFields(logrus.Fields{"from": r.RemoteAddr}).Infof("[%s] %s", r.Method, r.URL.Path)
+		// Only peers forward requests, clients can not name another
+		// address for the audit log.
+		if !s.fromPeer(r) {
+			r.Header.Del(utils.ForwardedHeader)
+		}
+
+		if s.leader != nil {
+			if !s.leader.IsLeader() {
+				s.forward(w, r)
+				return
+			}
+
+			if !s.leader.Ready() {
+				http.Error(w, "Leader is recovering", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
 		if err := handler(w, r); err != nil {
 			logrus.Errorf("Handler for %s %s returned error: %v", r.Method, r.URL.Path, err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -46,6 +89,44 @@ func (s *Server) makeHTTPHandler(handler router.APIFunc) http.HandlerFunc {
 	}
 }
 
+// fromPeer reports whether r was sent by a member of the cluster.
+func (s *Server) fromPeer(r *http.Request) bool {
+	if s.leader == nil {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	return s.leader.IsPeer(host)
+}
+
+// forward proxies the request to the leader of the cluster.
+func (s *Server) forward(w http.ResponseWriter, r *http.Request) {
+	leader := s.leader.LeaderAddr()
+	// Forwarded requests are never forwarded twice.
+	if leader == "" || r.Header.Get(utils.ForwardedHeader) != "" {
+		http.Error(w, "No leader available", http.StatusServiceUnavailable)
+		return
+	}
+
+	logrus.Debugf("Forward %s %s to leader %s", r.Method, r.URL.Path, leader)
+	r.Header.Set(utils.ForwardedHeader, r.RemoteAddr)
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: leader})
+	// Event streams are passed on as they come.
+	proxy.FlushInterval = 100 * time.Millisecond
+	proxy.ServeHTTP(w, r)
+}
+
+// SetLeader makes the server forward requests to the leader whenever this
+// instance is not the leader.
+func (s *Server) SetLeader(leader Leader) {
+	s.leader = leader
+}
+
 // InitRouter initializes the list of routers for the server.
 func (s *Server) InitRouter(routers ...router.Router) {
 	for _, r := range routers {
@@ -1,7 +1,13 @@
 package api
 
 import (
+	"errors"
+	"github.com/Dataman-Cloud/swan/api/router"
+	"github.com/Dataman-Cloud/swan/api/utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -27,3 +33,116 @@ func TestListenAndServe(t *testing.T) {
 		close(quit)
 	}()
 }
+
+type fakeLeader struct {
+	leader bool
+	ready  bool
+	addr   string
+	peers  []string
+}
+
+func (l *fakeLeader) IsLeader() bool     { return l.leader }
+func (l *fakeLeader) Ready() bool        { return l.ready }
+func (l *fakeLeader) LeaderAddr() string { return l.addr }
+
+func (l *fakeLeader) IsPeer(host string) bool {
+	for _, peer := range l.peers {
+		if peer == host {
+			return true
+		}
+	}
+	return false
+}
+
+func TestForwardToLeader(t *testing.T) {
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "192.0.2.1:1234", r.Header.Get(utils.ForwardedHeader))
+		w.Write([]byte("leader"))
+	}))
+	defer leader.Close()
+
+	s := NewServer("")
+	s.SetLeader(&fakeLeader{addr: strings.TrimPrefix(leader.URL, "http://"), peers: []string{"10.0.0.1"}})
+	handler := s.makeHTTPHandler(func(w http.ResponseWriter, r *http.Request) error {
+		w.Write([]byte("follower"))
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/v1/apps", nil))
+	assert.Equal(t, "leader", w.Body.String())
+
+	// Clients can not name another address.
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/v1/apps", nil)
+	req.Header.Set(utils.ForwardedHeader, "x.x.x.x")
+	handler(w, req)
+	assert.Equal(t, "leader", w.Body.String())
+
+	// Requests forwarded once are not forwarded again.
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/v1/apps", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set(utils.ForwardedHeader, "x.x.x.x")
+	handler(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestForwardedHeaderFromPeer(t *testing.T) {
+	s := NewServer("")
+	s.SetLeader(&fakeLeader{leader: true, ready: true, peers: []string{"10.0.0.1"}})
+
+	var actor string
+	handler := s.makeHTTPHandler(func(w http.ResponseWriter, r *http.Request) error {
+		actor = utils.Actor(r)
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/v1/apps", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set(utils.ForwardedHeader, "10.0.0.2:5678")
+	handler(httptest.NewRecorder(), req)
+	assert.Equal(t, "api:anonymous@10.0.0.2", actor)
+
+	req = httptest.NewRequest("GET", "/v1/apps", nil)
+	req.Header.Set(utils.ForwardedHeader, "10.0.0.2:5678")
+	handler(httptest.NewRecorder(), req)
+	assert.Equal(t, "api:anonymous@192.0.2.1", actor)
+}
+
+func TestLeaderNotReady(t *testing.T) {
+	s := NewServer("")
+	s.SetLeader(&fakeLeader{leader: true})
+	handler := s.makeHTTPHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/v1/apps", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+type fakeRouter []*router.Route
+
+func (r fakeRouter) Routes() []*router.Route { return r }
+
+func TestMetrics(t *testing.T) {
+	s := NewServer("")
+	s.InitRouter(fakeRouter{
+		router.NewRoute("GET", "/v1/apps/{appId}", func(w http.ResponseWriter, r *http.Request) error {
+			return errors.New("Not Found")
+		}),
+	})
+	m := s.createMux()
+
+	before := apiRequests.Value("GET", "/v1/apps/{appId}", "500")
+
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/apps/foo", nil))
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/apps/bar", nil))
+
+	assert.Equal(t, before+2, apiRequests.Value("GET", "/v1/apps/{appId}", "500"))
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `swan_api_request_duration_seconds_count{method="GET",route="/v1/apps/{appId}"}`)
+}
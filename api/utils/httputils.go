@@ -2,9 +2,20 @@ package utils
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 )
 
+const (
+	// ForwardedHeader holds the address of the client of a request a
+	// follower forwarded to the leader. The API server drops it from
+	// requests which do not come from a peer.
+	ForwardedHeader = "X-Swan-Forwarded-For"
+
+	// UserHeader names the user making a request, for the audit log.
+	UserHeader = "X-Swan-User"
+)
+
 // CheckForJSON makes sure that the request's Content-Type is application/json.
 func CheckForJSON(r *http.Request) error {
 	if r.Header.Get("Content-Type") != "application/json" {
@@ -13,3 +24,23 @@ func CheckForJSON(r *http.Request) error {
 
 	return nil
 }
+
+// Actor returns the caller of the request as recorded in the audit log,
+// api:<user>@<client address>.
+func Actor(r *http.Request) string {
+	user := r.Header.Get(UserHeader)
+	if user == "" {
+		user = "anonymous"
+	}
+
+	addr := r.Header.Get(ForwardedHeader)
+	if addr == "" {
+		addr = r.RemoteAddr
+	}
+
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	return fmt.Sprintf("api:%s@%s", user, addr)
+}
@@ -20,3 +20,16 @@ func TestCheckForJSON(t *testing.T) {
 	err2 := CheckForJSON(r2)
 	assert.NotNil(t, err2)
 }
+
+func TestActor(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/", nil)
+	r.RemoteAddr = "10.0.0.1:4321"
+	assert.Equal(t, "api:anonymous@10.0.0.1", Actor(r))
+
+	r.Header.Set(UserHeader, "alice")
+	assert.Equal(t, "api:alice@10.0.0.1", Actor(r))
+
+	// Requests forwarded by a follower keep the address of the client.
+	r.Header.Set(ForwardedHeader, "10.0.0.2:1234")
+	assert.Equal(t, "api:alice@10.0.0.2", Actor(r))
+}
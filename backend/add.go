@@ -1,36 +1,95 @@
 package backend
 
 import (
-	"github.com/Dataman-Cloud/swan/types"
+	"errors"
+	"fmt"
 	"reflect"
 	"sort"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
 )
 
-// RegisterApplication register application in db.
-func (b *Backend) SaveApplication(application *types.Application) error {
-	return b.store.SaveApplication(application)
-}
+// CreateApplication saves a new application together with its first version,
+// either both are saved or none.
+func (b *Backend) CreateApplication(actor string, application *types.Application, version *types.Version) error {
+	if err := validateVersion(version); err != nil {
+		return err
+	}
 
-// RegisterApplicationVersion register application version in db.
-func (b *Backend) SaveVersion(appId string, version *types.Version) error {
-	versions, err := b.store.ListVersions(appId)
+	err := b.store.Update(func(tx store.Tx) error {
+		app, err := tx.FetchApplication(application.ID)
+		if err != nil {
+			return err
+		}
+
+		if app != nil {
+			return errors.New("Applicaiton Id Duplicated")
+		}
+
+		if err := tx.SaveApplication(application); err != nil {
+			return err
+		}
+
+		if err := store.Audit(tx, actor, "app.create", application.ID, application.ID, nil, application); err != nil {
+			return err
+		}
+
+		return saveVersion(tx, actor, version)
+	})
 	if err != nil {
 		return err
 	}
 
-	if len(versions) != 0 {
-		sort.Strings(versions)
+	b.publish(&types.Event{Type: types.EventAppCreated, AppID: application.ID, Status: application.Status})
+
+	return nil
+}
+
+// RegisterApplication register application in db.
+func (b *Backend) SaveApplication(application *types.Application) error {
+	return b.store.SaveApplication(application)
+}
 
-		newestVersion, err := b.store.FetchVersion(versions[len(versions)-1])
+// RegisterApplicationVersion register application version in db.
+func (b *Backend) SaveVersion(actor, appId string, version *types.Version) error {
+	return b.store.Update(func(tx store.Tx) error {
+		versions, err := tx.ListVersions(appId)
 		if err != nil {
 			return err
 		}
 
-		if reflect.DeepEqual(version, newestVersion) {
-			return nil
+		if len(versions) != 0 {
+			sort.Strings(versions)
+
+			newestVersion, err := tx.FetchVersion(versions[len(versions)-1])
+			if err != nil {
+				return err
+			}
+
+			if reflect.DeepEqual(version, newestVersion) {
+				return nil
+			}
+
 		}
 
+		return saveVersion(tx, actor, version)
+	})
+}
+
+// validateVersion makes sure tasks can be built from version.
+func validateVersion(version *types.Version) error {
+	if version.ID == "" {
+		return errors.New("Version id is required")
+	}
+
+	if version.Container == nil || version.Container.Docker == nil {
+		return fmt.Errorf("Version %s has no docker container", version.ID)
+	}
+
+	if image := version.Container.Docker.Image; image == nil || *image == "" {
+		return fmt.Errorf("Version %s has no image", version.ID)
 	}
 
-	return b.store.SaveVersion(version)
+	return nil
 }
@@ -0,0 +1,166 @@
+package backend
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+// Backup writes a consistent snapshot of the store to w.
+func (b *Backend) Backup(w io.Writer) error {
+	snapshotter, ok := b.store.(io.WriterTo)
+	if !ok {
+		return errors.New("Store does not support backup")
+	}
+
+	_, err := snapshotter.WriteTo(w)
+
+	return err
+}
+
+// Export returns the definitions of all applications and their versions.
+func (b *Backend) Export() (*types.Export, error) {
+	export := &types.Export{
+		Created:      time.Now().Unix(),
+		Applications: make([]*types.ExportedApplication, 0),
+	}
+
+	err := b.store.View(func(tx store.Tx) error {
+		apps, err := tx.ListApplications()
+		if err != nil {
+			return err
+		}
+
+		for _, app := range apps {
+			versions, err := tx.ListVersions(app.ID)
+			if err != nil {
+				return err
+			}
+
+			sort.Strings(versions)
+
+			exported := &types.ExportedApplication{
+				ID:     app.ID,
+				Name:   app.Name,
+				UserId: app.UserId,
+			}
+
+			for _, versionId := range versions {
+				version, err := tx.FetchVersion(versionId)
+				if err != nil {
+					return err
+				}
+
+				exported.Versions = append(exported.Versions, version)
+			}
+
+			export.Applications = append(export.Applications, exported)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Import creates the applications of export and launches their newest
+// version. Every application is imported on its own, existing ones are left
+// untouched.
+func (b *Backend) Import(actor string, export *types.Export) []*types.ImportResult {
+	results := make([]*types.ImportResult, 0)
+
+	for _, exported := range export.Applications {
+		result := &types.ImportResult{AppID: exported.ID}
+		results = append(results, result)
+
+		if err := b.importApplication(actor, exported); err != nil {
+			logrus.Errorf("Import application %s failed: %s", exported.ID, err.Error())
+			result.Error = err.Error()
+		}
+	}
+
+	return results
+}
+
+func (b *Backend) importApplication(actor string, exported *types.ExportedApplication) error {
+	if exported.ID == "" {
+		return errors.New("Application id is required")
+	}
+
+	if len(exported.Versions) == 0 {
+		return errors.New("Application has no version")
+	}
+
+	// Nothing is saved unless every version is valid.
+	for _, version := range exported.Versions {
+		if version == nil {
+			return errors.New("Application has an empty version")
+		}
+
+		if version.ID != exported.ID {
+			return errors.New("Version belongs to another application")
+		}
+
+		if err := validateVersion(version); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().Unix()
+	application := &types.Application{
+		ID:        exported.ID,
+		Name:      exported.Name,
+		UserId:    exported.UserId,
+		ClusterId: b.ClusterId(),
+		Status:    "STAGING",
+		Created:   now,
+		Updated:   now,
+	}
+
+	err := b.store.Update(func(tx store.Tx) error {
+		app, err := tx.FetchApplication(application.ID)
+		if err != nil {
+			return err
+		}
+
+		if app != nil {
+			return errors.New("Applicaiton Id Duplicated")
+		}
+
+		if err := tx.SaveApplication(application); err != nil {
+			return err
+		}
+
+		if err := store.Audit(tx, actor, "app.import", application.ID, application.ID, nil, application); err != nil {
+			return err
+		}
+
+		for _, version := range exported.Versions {
+			if err := saveVersion(tx, actor, version); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	newest := exported.Versions[len(exported.Versions)-1]
+	go func() {
+		if err := b.LaunchApplication(actor, newest); err != nil {
+			logrus.Errorf("Launch imported application %s failed: %s", newest.ID, err.Error())
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+// auditPruneInterval is the interval between two prunings of the audit log.
+const auditPruneInterval = 10 * time.Minute
+
+// AuditConfig configures the retention of the audit log.
+type AuditConfig struct {
+	// MaxAge is how long entries are kept, zero keeps them regardless of age.
+	MaxAge time.Duration
+
+	// MaxEntries is the number of entries kept, zero means no limit.
+	MaxEntries int
+}
+
+// StartAuditPruner periodically deletes the audit entries beyond the
+// retention limits.
+func (b *Backend) StartAuditPruner(config AuditConfig) {
+	if config.MaxAge <= 0 && config.MaxEntries <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(auditPruneInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := b.PruneAudit(config); err != nil {
+				logrus.Errorf("Prune audit log failed: %s", err.Error())
+			}
+
+			<-ticker.C
+		}
+	}()
+}
+
+// PruneAudit deletes the audit entries beyond the retention limits.
+func (b *Backend) PruneAudit(config AuditConfig) error {
+	var before int64
+	if config.MaxAge > 0 {
+		before = time.Now().Add(-config.MaxAge).Unix()
+	}
+
+	return b.store.PruneAuditEntries(before, config.MaxEntries)
+}
+
+// ListAuditEntries lists the audit entries selected by query, oldest first.
+func (b *Backend) ListAuditEntries(query *types.AuditQuery) ([]*types.AuditEntry, error) {
+	entries, err := b.store.ListAuditEntries(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if entries == nil {
+		entries = make([]*types.AuditEntry, 0)
+	}
+
+	return entries, nil
+}
@@ -1,22 +1,114 @@
 package backend
 
 import (
+	"errors"
+	"sort"
+	"sync"
+
 	"github.com/Dataman-Cloud/swan/scheduler"
 	. "github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
 )
 
 type Backend struct {
 	sched *scheduler.Scheduler
 	store Store
+
+	convergeConfig ConvergeConfig
+
+	orphanMu sync.Mutex
+	orphans  map[string]*types.Orphan
 }
 
 func NewBackend(sched *scheduler.Scheduler, store Store) *Backend {
 	return &Backend{
 		sched: sched,
 		store: store,
+
+		orphans: make(map[string]*types.Orphan),
 	}
 }
 
 func (b *Backend) ClusterId() string {
 	return b.sched.ClusterId
 }
+
+// updateApplication changes application with fn in a single transaction,
+// nothing is saved if fn returns an error.
+func (b *Backend) updateApplication(appId string, fn func(app *types.Application) error) error {
+	return b.store.Update(func(tx Tx) error {
+		app, err := tx.FetchApplication(appId)
+		if err != nil {
+			return err
+		}
+
+		if app == nil {
+			return errors.New("Application not found")
+		}
+
+		if err := fn(app); err != nil {
+			return err
+		}
+
+		return tx.SaveApplication(app)
+	})
+}
+
+// setApplicationStatus changes the status of application.
+func (b *Backend) setApplicationStatus(appId, status string) error {
+	err := b.updateApplication(appId, func(app *types.Application) error {
+		app.Status = status
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.publish(&types.Event{Type: types.EventAppStatus, AppID: appId, Status: status})
+
+	return nil
+}
+
+// publish publishes event on the event bus of the scheduler.
+func (b *Backend) publish(event *types.Event) {
+	b.sched.EventBus.Publish(event)
+}
+
+// deleteTask removes task and its health check.
+func (b *Backend) deleteTask(actor, name string) error {
+	return b.store.Update(func(tx Tx) error {
+		// A missing task leaves nothing to record.
+		task, _ := tx.FetchTask(name)
+
+		if err := tx.DeleteCheck(name); err != nil {
+			return err
+		}
+
+		if err := tx.DeleteTask(name); err != nil {
+			return err
+		}
+
+		if task == nil {
+			return nil
+		}
+
+		return Audit(tx, actor, "task.delete", task.AppId, name, task, nil)
+	})
+}
+
+// saveVersion saves version under a new version id and records it in the
+// audit log.
+func saveVersion(tx Tx, actor string, version *types.Version) error {
+	if err := tx.SaveVersion(version); err != nil {
+		return err
+	}
+
+	versions, err := tx.ListVersions(version.ID)
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(versions)
+
+	return Audit(tx, actor, "version.create", version.ID, versions[len(versions)-1], nil, version)
+}
@@ -0,0 +1,348 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+// ConvergeConfig configures the convergence loop.
+type ConvergeConfig struct {
+	// Interval between two convergence passes, zero disables the loop.
+	Interval time.Duration
+
+	// MaxActions is the maximum number of tasks launched or killed in one
+	// pass, zero means no limit.
+	MaxActions int
+
+	// DryRun only reports the actions the loop would take.
+	DryRun bool
+}
+
+// StartConverger periodically compares the desired state of every
+// application with the tasks in the store and in mesos and corrects the drift.
+func (b *Backend) StartConverger(config ConvergeConfig) {
+	b.convergeConfig = config
+	if config.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			actions, err := b.Converge(types.ActorConverger, config.DryRun)
+			if err != nil {
+				logrus.Errorf("Converge applications failed: %s", err.Error())
+				continue
+			}
+
+			for _, action := range actions {
+				logrus.WithFields(logrus.Fields{"app": action.AppID, "task": action.Task, "reason": action.Reason, "dryRun": config.DryRun}).
+					Infof("Converge %s", action.Action)
+			}
+		}
+	}()
+}
+
+// Converge runs one convergence pass over all applications and returns the
+// actions taken, or the actions which would be taken if dryRun is set.
+// Applications are converged in parallel, the ones with operations queued
+// are skipped until the next pass.
+func (b *Backend) Converge(actor string, dryRun bool) ([]*types.ConvergeAction, error) {
+	apps, err := b.store.ListApplications()
+	if err != nil {
+		return nil, err
+	}
+
+	budget := &convergeBudget{limit: b.convergeConfig.MaxActions}
+
+	type result struct {
+		appId   string
+		actions []*types.ConvergeAction
+		done    <-chan error
+	}
+
+	var results []*result
+	for _, app := range apps {
+		// Applications in a deployment are converged by the deployment itself.
+		if app.Status != "RUNNING" && app.Status != "UNHEALTHY" {
+			continue
+		}
+
+		r := &result{appId: app.ID}
+
+		done, ok := b.sched.TrySubmit(app.ID, func() error {
+			var err error
+			r.actions, err = b.convergeApplication(actor, r.appId, budget, dryRun)
+			return err
+		})
+		if !ok {
+			logrus.Debugf("Application %s is busy, skip converging it", app.ID)
+			continue
+		}
+
+		r.done = done
+		results = append(results, r)
+	}
+
+	var actions []*types.ConvergeAction
+	for _, r := range results {
+		if err := <-r.done; err != nil {
+			logrus.Errorf("Converge application %s failed: %s", r.appId, err.Error())
+		}
+
+		actions = append(actions, r.actions...)
+	}
+
+	return actions, nil
+}
+
+// convergeBudget rate limits the tasks launched or killed in a pass.
+type convergeBudget struct {
+	mu    sync.Mutex
+	limit int
+	used  int
+}
+
+// take reports whether one more action is allowed, zero limit means no limit.
+func (c *convergeBudget) take() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limit > 0 && c.used >= c.limit {
+		return false
+	}
+
+	c.used++
+
+	return true
+}
+
+func (b *Backend) convergeApplication(actor, appId string, budget *convergeBudget, dryRun bool) ([]*types.ConvergeAction, error) {
+	// Fetch again, the application may have changed while queued.
+	app, err := b.store.FetchApplication(appId)
+	if err != nil {
+		return nil, err
+	}
+
+	if app == nil || (app.Status != "RUNNING" && app.Status != "UNHEALTHY") {
+		return nil, nil
+	}
+
+	version, err := b.desiredVersion(app)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tasks launched after the snapshot of mesos was taken may be missing
+	// from it, they are neither lost nor counted.
+	snapshot := time.Now()
+
+	mesosTasks, err := b.sched.FrameworkTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := b.store.ListTasks(app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(TaskSorter(tasks))
+
+	byIndex := make(map[int]*types.Task)
+	var actions []*types.ConvergeAction
+
+	running := 0
+	recent := false
+	for _, task := range tasks {
+		if launchedSince(task, snapshot) {
+			recent = true
+		}
+
+		if mesosTask, ok := mesosTasks[task.ID]; ok && mesosTask.State == "TASK_RUNNING" {
+			running++
+		}
+
+		index, err := strconv.Atoi(strings.Split(task.Name, ".")[0])
+		if err != nil {
+			continue
+		}
+
+		if index >= app.Instances {
+			actions = append(actions, &types.ConvergeAction{AppID: app.ID, Task: task.Name, Action: "KILL", Reason: "extra"})
+			continue
+		}
+
+		byIndex[index] = task
+	}
+
+	for index := 0; index < app.Instances; index++ {
+		task, ok := byIndex[index]
+		if !ok {
+			name := fmt.Sprintf("%d.%s.%s.%s", index, app.ID, app.UserId, app.ClusterId)
+			actions = append(actions, &types.ConvergeAction{AppID: app.ID, Task: name, Action: "LAUNCH", Reason: "missing"})
+			continue
+		}
+
+		// A rescheduling task is being launched again right now, and the
+		// unreachable policy decides when an unreachable one moves.
+		if task.Status == "RESCHEDULING" || task.Status == "UNREACHABLE" {
+			continue
+		}
+
+		if _, ok := mesosTasks[task.ID]; !ok {
+			if launchedSince(task, snapshot) {
+				continue
+			}
+
+			actions = append(actions, &types.ConvergeAction{AppID: app.ID, Task: task.Name, Action: "LAUNCH", Reason: "lost"})
+			continue
+		}
+
+		// Partially updated applications deliberately run two versions.
+		if app.UpdatedInstances == 0 && !taskMatchesVersion(task, version) {
+			actions = append(actions, &types.ConvergeAction{AppID: app.ID, Task: task.Name, Action: "RELAUNCH", Reason: "stale"})
+		}
+	}
+
+	var taken []*types.ConvergeAction
+	for _, action := range actions {
+		if !budget.take() {
+			logrus.Warnf("Converge action limit reached, skip %s of task %s", action.Action, action.Task)
+			break
+		}
+
+		taken = append(taken, action)
+		if dryRun {
+			continue
+		}
+
+		if err := b.applyConvergeAction(actor, app, version, action); err != nil {
+			action.Error = err.Error()
+		}
+	}
+
+	if !dryRun && !recent && running != app.RunningInstances {
+		err := b.updateApplication(appId, func(app *types.Application) error {
+			app.RunningInstances = running
+			return nil
+		})
+		if err != nil {
+			return taken, err
+		}
+	}
+
+	return taken, nil
+}
+
+func (b *Backend) applyConvergeAction(actor string, app *types.Application, version *types.Version, action *types.ConvergeAction) error {
+	if action.Action == "KILL" || action.Action == "RELAUNCH" {
+		task, err := b.store.FetchTask(action.Task)
+		if err != nil {
+			return err
+		}
+
+		b.sched.HealthCheckManager.StopCheck(task.Name)
+
+		if err := b.sched.Kill(task); err != nil {
+			return err
+		}
+
+		if err := b.deleteTask(actor, task.Name); err != nil {
+			return err
+		}
+	}
+
+	if action.Action == "LAUNCH" || action.Action == "RELAUNCH" {
+		// A lost task leaves its health check behind.
+		b.sched.HealthCheckManager.StopCheck(action.Task)
+
+		task, err := b.sched.BuildTask(version, action.Task)
+		if err != nil {
+			return err
+		}
+
+		if _, err := b.launchTask(actor, task); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// launchedSince reports whether task was launched at t or later, task ids
+// start with their launch time.
+func launchedSince(task *types.Task, t time.Time) bool {
+	launched, err := strconv.ParseInt(strings.SplitN(task.ID, "-", 2)[0], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return launched >= t.UnixNano()
+}
+
+// desiredVersion returns the version application should be running.
+func (b *Backend) desiredVersion(app *types.Application) (version *types.Version, err error) {
+	err = b.store.View(func(tx store.Tx) error {
+		if app.CurrentVersion != "" {
+			version, err = tx.FetchVersion(app.CurrentVersion)
+			return err
+		}
+
+		versions, err := tx.ListVersions(app.ID)
+		if err != nil {
+			return err
+		}
+
+		if len(versions) == 0 {
+			return fmt.Errorf("No version found for application %s", app.ID)
+		}
+
+		sort.Strings(versions)
+
+		version, err = tx.FetchVersion(versions[len(versions)-1])
+		return err
+	})
+
+	return version, err
+}
+
+// setCurrentVersion records the version which all instances of application run.
+func (b *Backend) setCurrentVersion(appId, versionId string) error {
+	return b.updateApplication(appId, func(app *types.Application) error {
+		app.CurrentVersion = versionId
+		return nil
+	})
+}
+
+// taskMatchesVersion reports whether task has been built from version.
+func taskMatchesVersion(task *types.Task, version *types.Version) bool {
+	if task.Cpus != version.Cpus || task.Mem != version.Mem || task.Disk != version.Disk {
+		return false
+	}
+
+	if version.Container == nil || version.Container.Docker == nil {
+		return true
+	}
+
+	docker := version.Container.Docker
+	if task.Network != docker.Network {
+		return false
+	}
+
+	if task.Image == nil || docker.Image == nil {
+		return task.Image == docker.Image
+	}
+
+	return *task.Image == *docker.Image
+}
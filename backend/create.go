@@ -3,98 +3,89 @@ package backend
 import (
 	"fmt"
 	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
-	"github.com/Sirupsen/logrus"
-	"net/http"
+	"sort"
 )
 
-func (b *Backend) LaunchApplication(version *types.Version) error {
-	b.sched.TaskLaunched = 0
+func (b *Backend) LaunchApplication(actor string, version *types.Version) error {
+	return <-b.sched.Submit(version.ID, func() error {
+		if err := b.doLaunch(actor, version); err != nil {
+			return err
+		}
 
-	// Set scheduler's status to busy for accepting resource.
-	b.sched.Status = "busy"
-	// Set scheduler's status back to idle after launch applicaiton.
-	defer func() {
-		b.sched.Status = "idle"
-	}()
+		versions, err := b.store.ListVersions(version.ID)
+		if err != nil {
+			return err
+		}
 
-	resources := b.sched.BuildResources(version.Cpus, version.Mem, version.Disk)
-	offers, err := b.sched.RequestOffers(resources)
-	if err != nil {
-		logrus.Errorf("Request offers failed: %s", err.Error())
-		return err
-	}
+		sort.Strings(versions)
 
-	for _, offer := range offers {
-		cpus, mem, disk := b.sched.OfferedResources(offer)
-		var tasks []*mesos.TaskInfo
-		for b.sched.TaskLaunched < version.Instances &&
-			cpus >= version.Cpus &&
-			mem >= version.Mem &&
-			disk >= version.Disk {
-			task, err := b.sched.BuildTask(offer, version, "")
-			if err != nil {
-				return fmt.Errorf("Build task failed: %s", err.Error())
-			}
+		return b.setCurrentVersion(version.ID, versions[len(versions)-1])
+	})
+}
 
-			if err := b.store.SaveTask(task); err != nil {
-				return fmt.Errorf("Save task failed: %s", err.Error())
-			}
+// doLaunch builds all instances of version and launches them together so
+// they can share offers.
+func (b *Backend) doLaunch(actor string, version *types.Version) error {
+	var tasks []*types.Task
+	for i := 0; i < version.Instances; i++ {
+		task, err := b.sched.BuildTask(version, "")
+		if err != nil {
+			return fmt.Errorf("Build task failed: %s", err.Error())
+		}
 
-			taskInfo := b.sched.BuildTaskInfo(offer, resources, task)
-			tasks = append(tasks, taskInfo)
-
-			if len(task.HealthChecks) != 0 {
-				if err := b.store.SaveCheck(task,
-					*taskInfo.Container.Docker.PortMappings[0].HostPort,
-					version.ID); err != nil {
-				}
-				for _, healthCheck := range task.HealthChecks {
-					check := types.Check{
-						ID:       task.Name,
-						Address:  *task.AgentHostname,
-						Port:     int(*taskInfo.Container.Docker.PortMappings[0].HostPort),
-						TaskID:   task.Name,
-						AppID:    version.ID,
-						Protocol: healthCheck.Protocol,
-						Interval: int(healthCheck.IntervalSeconds),
-						Timeout:  int(healthCheck.TimeoutSeconds),
-					}
-					if healthCheck.Command != nil {
-						check.Command = healthCheck.Command
-					}
-
-					if healthCheck.Path != nil {
-						check.Path = *healthCheck.Path
-					}
-
-					if healthCheck.MaxConsecutiveFailures != nil {
-						check.MaxFailures = *healthCheck.MaxConsecutiveFailures
-					}
-
-					b.sched.HealthCheckManager.Add(&check)
-				}
-			}
+		tasks = append(tasks, task)
+	}
 
-			b.sched.TaskLaunched++
-			cpus -= version.Cpus
-			mem -= version.Mem
-			disk -= version.Disk
-		}
+	errs := make(chan error, len(tasks))
+	for _, task := range tasks {
+		go func(task *types.Task) {
+			_, err := b.launchTask(actor, task)
+			errs <- err
+		}(task)
+	}
 
-		if len(tasks) == 0 {
-			return fmt.Errorf("Not enough resource")
+	var err error
+	for range tasks {
+		if e := <-errs; e != nil {
+			err = e
 		}
+	}
 
-		resp, err := b.sched.LaunchTasks(offer, tasks)
-		if err != nil {
-			return fmt.Errorf("Launchs task failed: %s", err.Error())
+	return err
+}
+
+// launchTask launches task, saves it and registers its health checks. The
+// instance it replaces, if any, is recorded as the state before the launch.
+func (b *Backend) launchTask(actor string, task *types.Task) (*mesos.TaskInfo, error) {
+	taskInfo, err := b.sched.Launch(task)
+	if err != nil {
+		return nil, fmt.Errorf("Launchs task failed: %s", err.Error())
+	}
+
+	err = b.store.Update(func(tx store.Tx) error {
+		replaced, _ := tx.FetchTask(task.Name)
+
+		if err := tx.SaveTask(task); err != nil {
+			return err
 		}
 
-		if resp != nil && resp.StatusCode != http.StatusAccepted {
-			return fmt.Errorf("status code %d received", resp.StatusCode)
+		if store.HasChecks(task) {
+			if err := tx.SaveCheck(task); err != nil {
+				return err
+			}
 		}
+
+		return store.Audit(tx, actor, "task.launch", task.AppId, task.Name, replaced, task)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Save task failed: %s", err.Error())
+	}
+
+	for _, check := range store.TaskChecks(task) {
+		b.sched.HealthCheckManager.Add(check)
 	}
 
-	return nil
+	return taskInfo, nil
 }
@@ -1,62 +1,114 @@
 package backend
 
 import (
-	"net/http"
+	"fmt"
+	"strings"
 
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
 	"github.com/Sirupsen/logrus"
 )
 
-// DeleteApplication will delete all data associated with application.
-func (b *Backend) DeleteApplication(appId string) error {
+// DeleteApplication will delete all data associated with application. The
+// deletion is queued behind the operations running on application and
+// DeleteApplication returns without waiting for it.
+func (b *Backend) DeleteApplication(actor, appId string) error {
+	b.submitDelete(appId, "application "+appId, func() error {
+		return b.deleteApplication(actor, appId)
+	})
+
+	return nil
+}
+
+// submitDelete queues the deletion fn of what on the work queue of
+// application, its failure is logged and deleting again retries it.
+func (b *Backend) submitDelete(appId, what string, fn func() error) {
+	done := b.sched.Submit(appId, fn)
+
+	go func() {
+		if err := <-done; err != nil {
+			logrus.Errorf("Delete %s failed: %s", what, err.Error())
+		}
+	}()
+}
+
+func (b *Backend) deleteApplication(actor, appId string) error {
 	tasks, err := b.store.ListTasks(appId)
 	if err != nil {
 		return err
 	}
 
+	// Tasks which could not be killed are kept, along with the
+	// application, so deleting it again retries them.
+	var failed []string
 	for _, task := range tasks {
+		// Kill task via mesos
+		if err := b.sched.Kill(task); err != nil {
+			logrus.Errorf("Kill task %s failed: %s", task.Name, err.Error())
+			failed = append(failed, fmt.Sprintf("%s: %s", task.Name, err.Error()))
+			continue
+		}
+
 		// Stop task health check
 		b.sched.HealthCheckManager.StopCheck(task.Name)
 
-		// Kill task via mesos
-		resp, err := b.sched.KillTask(task)
+		// Delete task and its health check from db
+		if err := b.deleteTask(actor, task.Name); err != nil {
+			logrus.Errorf("Delete task %s from db failed: %s", task.ID, err.Error())
+		}
+	}
+
+	if len(failed) != 0 {
+		return fmt.Errorf("Kill tasks of application %s failed: %s", appId, strings.Join(failed, "; "))
+	}
+
+	err = b.store.Update(func(tx store.Tx) error {
+		app, err := tx.FetchApplication(appId)
 		if err != nil {
-			logrus.Errorf("Kill task failed: %s", err.Error())
-			break
+			return err
 		}
 
-		// Decline offer
-		if resp.StatusCode == http.StatusAccepted {
-			b.sched.DeclineResource(task.OfferId)
+		versions, err := tx.ListVersions(appId)
+		if err != nil {
+			return err
 		}
 
-		// Delete task from db
-		if err := b.store.DeleteTask(task.Name); err != nil {
-			logrus.Errorf("Delete task %s from db failed: %s", task.ID, err.Error())
+		for _, version := range versions {
+			if err := tx.DeleteVersion(version); err != nil {
+				return err
+			}
 		}
 
-		// Delete task health check
-		if err := b.store.DeleteCheck(task.Name); err != nil {
-			logrus.Errorf("Delete task health check %s from db failed: %s", task.ID, err.Error())
+		if err := tx.DeleteApplication(appId); err != nil {
+			return err
 		}
 
-	}
+		if app == nil {
+			return nil
+		}
 
-	versions, err := b.store.ListVersions(appId)
+		return store.Audit(tx, actor, "app.delete", appId, appId, app, nil)
+	})
 	if err != nil {
 		return err
 	}
 
-	for _, version := range versions {
-		if err := b.store.DeleteVersion(version); err != nil {
-			return err
-		}
-	}
+	b.publish(&types.Event{Type: types.EventAppDeleted, AppID: appId})
 
-	return b.store.DeleteApplication(appId)
+	return nil
 }
 
 // DeleteApplicationTasks delete all tasks belong to appcaiton but keep that application exists.
-func (b *Backend) DeleteApplicationTasks(id string) error {
+// Like DeleteApplication it only queues the deletion.
+func (b *Backend) DeleteApplicationTasks(actor, id string) error {
+	b.submitDelete(id, "tasks of application "+id, func() error {
+		return b.deleteApplicationTasks(actor, id)
+	})
+
+	return nil
+}
+
+func (b *Backend) deleteApplicationTasks(actor, id string) error {
 	tasks, err := b.store.ListTasks(id)
 	if err != nil {
 		return err
@@ -64,12 +116,12 @@ func (b *Backend) DeleteApplicationTasks(id string) error {
 
 	for _, task := range tasks {
 		// Kill task via mesos
-		if _, err := b.sched.KillTask(task); err != nil {
+		if err := b.sched.Kill(task); err != nil {
 			logrus.Errorf("Kill task failed: %s", err.Error())
 		}
 
 		// Delete task from db
-		if err := b.store.DeleteTask(task.ID); err != nil {
+		if err := b.deleteTask(actor, task.Name); err != nil {
 			logrus.Errorf("Delete task %s from db failed: %s", task.ID, err.Error())
 		}
 	}
@@ -77,17 +129,32 @@ func (b *Backend) DeleteApplicationTasks(id string) error {
 	return nil
 }
 
-func (b *Backend) DeleteApplicationTask(applicationId, taskId string) error {
+// DeleteApplicationTask queues the deletion of a task, a missing task is
+// reported right away.
+func (b *Backend) DeleteApplicationTask(actor, applicationId, taskId string) error {
+	if _, err := b.store.FetchTask(taskId); err != nil {
+		return err
+	}
+
+	b.submitDelete(applicationId, "task "+taskId, func() error {
+		return b.deleteApplicationTask(actor, taskId)
+	})
+
+	return nil
+}
+
+func (b *Backend) deleteApplicationTask(actor, taskId string) error {
 	task, err := b.store.FetchTask(taskId)
 	if err != nil {
 		return err
 	}
 
-	if err := b.store.DeleteTask(taskId); err != nil {
+	// Delete task and its health check from db
+	if err := b.deleteTask(actor, taskId); err != nil {
 		return err
 	}
 
-	if _, err := b.sched.KillTask(task); err != nil {
+	if err := b.sched.Kill(task); err != nil {
 		logrus.Errorf("Kill task failed: %s", err.Error())
 		return err
 	}
@@ -95,10 +162,5 @@ func (b *Backend) DeleteApplicationTask(applicationId, taskId string) error {
 	logrus.Infof("Stop health check for task %s", task.Name)
 	b.sched.HealthCheckManager.StopCheck(task.Name)
 
-	// Delete task health check
-	if err := b.store.DeleteCheck(task.Name); err != nil {
-		logrus.Errorf("Delete task health check %s from db failed: %s", task.ID, err.Error())
-	}
-
 	return nil
 }
@@ -0,0 +1,127 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+// newDeployment creates a deployment record for an operation on application
+// requested by actor.
+func newDeployment(actor, appId, deploymentType, versionId string) *types.Deployment {
+	now := time.Now()
+	return &types.Deployment{
+		ID:        fmt.Sprintf("%d", now.UnixNano()),
+		AppID:     appId,
+		Type:      deploymentType,
+		Actor:     actor,
+		VersionID: versionId,
+		Status:    "RUNNING",
+		Created:   now.Unix(),
+		Updated:   now.Unix(),
+	}
+}
+
+// advanceDeployment records one more completed step for deployment.
+func (b *Backend) advanceDeployment(deployment *types.Deployment) error {
+	err := b.store.Update(func(tx store.Tx) error {
+		return stepDeployment(tx, deployment)
+	})
+	if err != nil {
+		return err
+	}
+
+	b.publishDeployment(types.EventDeploymentStep, deployment)
+
+	return nil
+}
+
+// publishDeployment publishes an event of type eventType about deployment.
+func (b *Backend) publishDeployment(eventType string, deployment *types.Deployment) {
+	message := fmt.Sprintf("%s %s, %d steps completed", deployment.Type, deployment.ID, deployment.Completed)
+	if deployment.Message != "" {
+		message += ": " + deployment.Message
+	}
+
+	b.publish(&types.Event{
+		Type:    eventType,
+		AppID:   deployment.AppID,
+		Status:  deployment.Status,
+		Message: message,
+	})
+}
+
+// stepDeployment records one more completed step for deployment in tx.
+func stepDeployment(tx store.Tx, deployment *types.Deployment) error {
+	deployment.Completed += 1
+	deployment.Updated = time.Now().Unix()
+
+	return tx.SaveDeployment(deployment)
+}
+
+// finishDeployment records the final status of deployment.
+func (b *Backend) finishDeployment(deployment *types.Deployment, status, message string) {
+	deployment.Status = status
+	if message != "" {
+		deployment.Message = message
+	}
+	deployment.Updated = time.Now().Unix()
+
+	err := b.store.Update(func(tx store.Tx) error {
+		if err := tx.SaveDeployment(deployment); err != nil {
+			return err
+		}
+
+		return store.Audit(tx, deployment.Actor, "deployment.finish", deployment.AppID, deployment.ID, nil, deployment)
+	})
+	if err != nil {
+		logrus.Errorf("Save deployment %s for application %s failed: %s", deployment.ID, deployment.AppID, err.Error())
+	}
+
+	b.publishDeployment(types.EventDeploymentFinished, deployment)
+
+	deploymentDuration.Observe(deploymentAge(deployment).Seconds(), deployment.Type, status)
+}
+
+// deploymentAge returns the time since deployment was created, deployment
+// ids are their creation time in nanoseconds.
+func deploymentAge(deployment *types.Deployment) time.Duration {
+	if created, err := strconv.ParseInt(deployment.ID, 10, 64); err == nil {
+		return time.Since(time.Unix(0, created))
+	}
+
+	return time.Since(time.Unix(deployment.Created, 0))
+}
+
+// ListApplicationDeployments list all deployments of application sorted by creation time.
+func (b *Backend) ListApplicationDeployments(appId string) ([]*types.Deployment, error) {
+	deployments, err := b.store.ListDeployments(appId)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(DeploymentSorter(deployments))
+
+	return deployments, nil
+}
+
+type DeploymentSorter []*types.Deployment
+
+func (s DeploymentSorter) Len() int           { return len(s) }
+func (s DeploymentSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s DeploymentSorter) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+// taskNames returns the names of tasks.
+func taskNames(tasks []*types.Task) []string {
+	names := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		names = append(names, task.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Dataman-Cloud/swan/store"
+)
+
+// PauseFailover defers the replacement of the tasks of application appId
+// whose health checks failed until ResumeFailover is called.
+func (b *Backend) PauseFailover(actor, appId string) error {
+	return b.setFailoverPaused(actor, appId, true)
+}
+
+// ResumeFailover lets the tasks of application appId whose health checks
+// failed be replaced again, the deferred ones included.
+func (b *Backend) ResumeFailover(actor, appId string) error {
+	return b.setFailoverPaused(actor, appId, false)
+}
+
+func (b *Backend) setFailoverPaused(actor, appId string, paused bool) error {
+	op := "app.failover.resume"
+	if paused {
+		op = "app.failover.pause"
+	}
+
+	return b.store.Update(func(tx store.Tx) error {
+		app, err := tx.FetchApplication(appId)
+		if err != nil {
+			return err
+		}
+
+		if app == nil {
+			return errors.New("Application not found")
+		}
+
+		before := fmt.Sprintf("failoverPaused=%t", app.FailoverPaused)
+
+		app.FailoverPaused = paused
+		if err := tx.SaveApplication(app); err != nil {
+			return err
+		}
+
+		return store.Audit(tx, actor, op, appId, appId, before, fmt.Sprintf("failoverPaused=%t", paused))
+	})
+}
@@ -5,7 +5,39 @@ import (
 )
 
 func (b *Backend) ListApplications() ([]*types.Application, error) {
-	return b.store.ListApplications()
+	apps, err := b.store.ListApplications()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, app := range apps {
+		if err := b.countHealth(app); err != nil {
+			return nil, err
+		}
+	}
+
+	return apps, nil
+}
+
+// countHealth sets the healthy and unhealthy instances of app from the
+// recorded health of its tasks.
+func (b *Backend) countHealth(app *types.Application) error {
+	tasks, err := b.store.ListTasks(app.ID)
+	if err != nil {
+		return err
+	}
+
+	app.HealthyInstances, app.UnhealthyInstances = 0, 0
+	for _, task := range tasks {
+		switch task.Health {
+		case "healthy":
+			app.HealthyInstances++
+		case "unhealthy":
+			app.UnhealthyInstances++
+		}
+	}
+
+	return nil
 }
 
 func (b *Backend) ListApplicationTasks(appId string) ([]*types.Task, error) {
@@ -0,0 +1,9 @@
+package backend
+
+import (
+	"github.com/Dataman-Cloud/swan/metrics"
+)
+
+var deploymentDuration = metrics.NewHistogram("swan_deployment_duration_seconds",
+	"Duration of deployments, by type and final status.",
+	[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}, "type", "status")
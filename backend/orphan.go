@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+// OrphanConfig configures the orphan task detection.
+type OrphanConfig struct {
+	// Interval between two detection passes, zero disables the detection.
+	Interval time.Duration
+
+	// Kill orphans which are still running after GracePeriod, otherwise
+	// they are only reported.
+	Kill        bool
+	GracePeriod time.Duration
+}
+
+// StartOrphanDetector periodically looks for tasks running under the
+// framework of swan which are not in the store.
+func (b *Backend) StartOrphanDetector(config OrphanConfig) {
+	if config.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := b.DetectOrphans(config); err != nil {
+				logrus.Errorf("Detect orphan tasks failed: %s", err.Error())
+			}
+		}
+	}()
+}
+
+// DetectOrphans runs one detection pass. The tasks reported by implicit
+// reconciliation since the last pass are merged with the master state, and
+// every one of them which is not in the store is an orphan.
+func (b *Backend) DetectOrphans(config OrphanConfig) error {
+	candidates := make(map[string]*types.Orphan)
+
+	for id, status := range b.sched.ObservedTasks() {
+		candidates[id] = &types.Orphan{
+			ID:      id,
+			AgentID: status.GetAgentId().GetValue(),
+			State:   status.GetState().String(),
+		}
+	}
+
+	mesosTasks, err := b.sched.FrameworkTasks()
+	if err != nil {
+		logrus.Warnf("Fetch tasks from mesos master failed: %s", err.Error())
+	}
+
+	for id, task := range mesosTasks {
+		candidates[id] = &types.Orphan{
+			ID:      id,
+			AgentID: task.SlaveID,
+			State:   task.State,
+		}
+	}
+
+	// Refresh the reports for the next pass.
+	if err := b.sched.ReconcileImplicit(); err != nil {
+		logrus.Warnf("Implicit reconciliation failed: %s", err.Error())
+	}
+
+	known, err := b.knownTasks()
+	if err != nil {
+		return err
+	}
+
+	b.orphanMu.Lock()
+	defer b.orphanMu.Unlock()
+
+	now := time.Now()
+	for id := range b.orphans {
+		if _, ok := candidates[id]; !ok || known[id] {
+			delete(b.orphans, id)
+		}
+	}
+
+	for id, candidate := range candidates {
+		if known[id] {
+			continue
+		}
+
+		orphan, ok := b.orphans[id]
+		if !ok {
+			orphan = candidate
+			orphan.AppID = orphanAppId(id)
+			orphan.Status = "ORPHANED"
+			orphan.FirstSeen = now.Unix()
+			b.orphans[id] = orphan
+
+			logrus.Warnf("Found orphan task %s on agent %s", id, orphan.AgentID)
+		}
+		orphan.State = candidate.State
+
+		if !config.Kill || orphan.Status == "KILLED" ||
+			now.Sub(time.Unix(orphan.FirstSeen, 0)) < config.GracePeriod {
+			continue
+		}
+
+		logrus.Infof("Kill orphan task %s", id)
+		task := &types.Task{
+			ID:      id,
+			Name:    id,
+			AgentId: &orphan.AgentID,
+		}
+
+		if err := b.sched.Kill(task); err != nil {
+			orphan.Message = err.Error()
+			logrus.Errorf("Kill orphan task %s failed: %s", id, err.Error())
+			continue
+		}
+
+		orphan.Status = "KILLED"
+		orphan.Message = ""
+	}
+
+	return nil
+}
+
+// ListOrphans returns the orphan tasks found by the last detection pass.
+func (b *Backend) ListOrphans() ([]*types.Orphan, error) {
+	b.orphanMu.Lock()
+	defer b.orphanMu.Unlock()
+
+	orphans := make([]*types.Orphan, 0, len(b.orphans))
+	for _, orphan := range b.orphans {
+		o := *orphan
+		orphans = append(orphans, &o)
+	}
+
+	sort.Sort(OrphanSorter(orphans))
+
+	return orphans, nil
+}
+
+// knownTasks returns the ids of all tasks in the store.
+func (b *Backend) knownTasks() (map[string]bool, error) {
+	apps, err := b.store.ListApplications()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, app := range apps {
+		tasks, err := b.store.ListTasks(app.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, task := range tasks {
+			known[task.ID] = true
+		}
+	}
+
+	return known, nil
+}
+
+// orphanAppId extracts the application id from a task id of format
+// <timestamp>-<index>.<appId>.<userId>.<clusterId>.
+func orphanAppId(id string) string {
+	parts := strings.SplitN(id, "-", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+
+	fields := strings.Split(parts[1], ".")
+	if len(fields) < 2 {
+		return ""
+	}
+
+	return fields[1]
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/mesosproto/sched"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+// reconcileTimeout is how long to wait for mesos to report the state of
+// all known tasks before resuming interrupted deployments.
+const reconcileTimeout = 30 * time.Second
+
+// Recover waits for the scheduler to subscribe with mesos, reconciles task
+// states and then resumes deployments interrupted by a previous shutdown.
+func (b *Backend) Recover() {
+	<-b.sched.GetEvent(sched.Event_SUBSCRIBED)
+
+	if err := b.sched.Reconcile(reconcileTimeout); err != nil {
+		logrus.Errorf("Reconcile tasks failed: %s", err.Error())
+	}
+
+	if err := b.ResumeDeployments(); err != nil {
+		logrus.Errorf("Resume deployments failed: %s", err.Error())
+	}
+}
+
+// ResumeDeployments continues every deployment which was still in progress
+// when swan stopped. Interrupted rolling-updates are rolled back instead if
+// the update policy of the version says so.
+func (b *Backend) ResumeDeployments() error {
+	apps, err := b.store.ListApplications()
+	if err != nil {
+		return err
+	}
+
+	for _, app := range apps {
+		if app.Status != "UPDATING" && app.Status != "SCALING" && app.Status != "ROLLINGBACK" {
+			continue
+		}
+
+		deployment, err := b.interruptedDeployment(app.ID)
+		if err != nil {
+			logrus.Errorf("Find interrupted deployment for application %s failed: %s", app.ID, err.Error())
+			continue
+		}
+
+		if deployment == nil {
+			logrus.Warnf("No deployment recorded for application %s in %s, reset status to RUNNING", app.ID, app.Status)
+			if err := b.setApplicationStatus(app.ID, "RUNNING"); err != nil {
+				logrus.Errorf("Updating application %s status failed: %s", app.ID, err.Error())
+			}
+			continue
+		}
+
+		b.submitResume(app, deployment)
+	}
+
+	return nil
+}
+
+// submitResume resumes deployment on the work queue of application, like
+// every other operation on it.
+func (b *Backend) submitResume(app *types.Application, deployment *types.Deployment) {
+	done := b.sched.Submit(app.ID, func() error {
+		return b.resumeDeployment(app, deployment)
+	})
+
+	go func() {
+		if err := <-done; err != nil {
+			logrus.Errorf("Resume deployment %s for application %s failed: %s", deployment.ID, app.ID, err.Error())
+		}
+	}()
+}
+
+// interruptedDeployment returns the newest deployment of application which
+// has not finished yet.
+func (b *Backend) interruptedDeployment(appId string) (*types.Deployment, error) {
+	deployments, err := b.ListApplicationDeployments(appId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(deployments) - 1; i >= 0; i-- {
+		if deployments[i].Status == "RUNNING" {
+			return deployments[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (b *Backend) resumeDeployment(app *types.Application, deployment *types.Deployment) error {
+	version, err := b.store.FetchVersion(deployment.VersionID)
+	if err != nil {
+		b.finishDeployment(deployment, "FAILED", err.Error())
+		return err
+	}
+
+	tasks, err := b.store.ListTasks(app.ID)
+	if err != nil {
+		b.finishDeployment(deployment, "FAILED", err.Error())
+		return err
+	}
+
+	switch deployment.Type {
+	case "UPDATE":
+		if version.UpdatePolicy != nil && version.UpdatePolicy.Action == "rollback" {
+			logrus.Infof("Rollback interrupted update of application %s", app.ID)
+			b.finishDeployment(deployment, "ROLLEDBACK", "Interrupted by restart, rollback by update policy")
+			return b.RollbackApplication(deployment.Actor, app.ID)
+		}
+
+		logrus.Infof("Resume update of application %s from step %d", app.ID, deployment.Completed)
+		deployment.Message = fmt.Sprintf("Resumed after restart from step %d", deployment.Completed)
+
+		return b.finishUpdate(deployment, remainingTasks(deployment, tasks), version)
+	case "SCALE":
+		logrus.Infof("Resume scaling of application %s to %d instances", app.ID, deployment.Instances)
+		deployment.Message = fmt.Sprintf("Resumed after restart with %d instances", deployment.Instances)
+
+		return b.finishScale(deployment, app, version)
+	case "ROLLBACK":
+		logrus.Infof("Resume rollback of application %s from step %d", app.ID, deployment.Completed)
+		deployment.Message = fmt.Sprintf("Resumed after restart from step %d", deployment.Completed)
+
+		return b.finishRollback(deployment, remainingTasks(deployment, tasks), version)
+	}
+
+	err = errors.New("Unknown deployment type")
+	b.finishDeployment(deployment, "FAILED", err.Error())
+
+	return err
+}
+
+// remainingTasks returns the tasks deployment has not replaced yet, in the
+// order it replaces them. Tasks which are gone are left out.
+func remainingTasks(deployment *types.Deployment, tasks []*types.Task) []*types.Task {
+	byName := make(map[string]*types.Task, len(tasks))
+	for _, task := range tasks {
+		byName[task.Name] = task
+	}
+
+	names := deployment.Tasks
+	if deployment.Completed < len(names) {
+		names = names[deployment.Completed:]
+	} else {
+		names = nil
+	}
+
+	remaining := make([]*types.Task, 0, len(names))
+	for _, name := range names {
+		if task, ok := byName[name]; ok {
+			remaining = append(remaining, task)
+		}
+	}
+
+	return remaining
+}
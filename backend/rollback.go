@@ -2,71 +2,117 @@ package backend
 
 import (
 	"errors"
-	"fmt"
-	"net/http"
 	"sort"
 
-	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
 	"github.com/Sirupsen/logrus"
 )
 
 // RollbackApplication rollback application to previous version.
-func (b *Backend) RollbackApplication(appId string) error {
+func (b *Backend) RollbackApplication(actor, appId string) error {
 	logrus.Infof("Rollback application %s", appId)
-	app, err := b.store.FetchApplication(appId)
-	if err != nil {
-		return err
-	}
 
-	if app == nil {
-		logrus.Errorf("Application %s not found for rollback", appId)
-		return errors.New("Application not found")
-	}
+	var (
+		tasks      []*types.Task
+		version    *types.Version
+		deployment *types.Deployment
+	)
 
-	// Update application status to ROLLINGBACK
-	if err := b.store.UpdateApplicationStatus(app.ID, "ROLLINGBACK"); err != nil {
-		return err
-	}
+	err := b.store.Update(func(tx store.Tx) error {
+		app, err := tx.FetchApplication(appId)
+		if err != nil {
+			return err
+		}
+
+		if app == nil {
+			logrus.Errorf("Application %s not found for rollback", appId)
+			return errors.New("Application not found")
+		}
+
+		versions, err := tx.ListVersions(appId)
+		if err != nil {
+			return err
+		}
+
+		sort.Strings(versions)
+
+		rollbackVer := versions[len(versions)-2]
+		version, err = tx.FetchVersion(rollbackVer)
+		if err != nil {
+			return err
+		}
+
+		tasks, err = tx.ListTasks(appId)
+		if err != nil {
+			return err
+		}
+
+		sort.Sort(TaskSorter(tasks))
+
+		deployment = newDeployment(actor, appId, "ROLLBACK", rollbackVer)
+		deployment.End = len(tasks)
+		deployment.Tasks = taskNames(tasks)
+		if err := tx.SaveDeployment(deployment); err != nil {
+			return err
+		}
+
+		before := store.Summarize(app)
+
+		// Update application status to ROLLINGBACK
+		app.Status = "ROLLINGBACK"
+		if err := tx.SaveApplication(app); err != nil {
+			return err
+		}
 
-	versions, err := b.store.ListVersions(appId)
+		rolledBack := *app
+		rolledBack.CurrentVersion = rollbackVer
+
+		return store.Audit(tx, actor, "app.rollback", appId, deployment.ID, before, &rolledBack)
+	})
 	if err != nil {
 		return err
 	}
 
-	sort.Strings(versions)
+	b.publish(&types.Event{Type: types.EventAppStatus, AppID: appId, Status: "ROLLINGBACK"})
+	b.publishDeployment(types.EventDeploymentStarted, deployment)
 
-	rollbackVer := versions[len(versions)-2]
-	version, err := b.store.FetchVersion(rollbackVer)
-	if err != nil {
+	b.sched.Submit(appId, func() error {
+		return b.finishRollback(deployment, tasks, version)
+	})
+
+	return nil
+}
+
+// finishRollback runs the rollback described by deployment and updates
+// application status according to the result.
+func (b *Backend) finishRollback(deployment *types.Deployment, tasks []*types.Task, version *types.Version) error {
+	if err := b.doRollback(deployment, tasks, version); err != nil {
+		logrus.Errorf("Rollback application failed: %s", deployment.AppID)
+		b.finishDeployment(deployment, "FAILED", err.Error())
+		if err := b.setApplicationStatus(deployment.AppID, "ROLLBACK-FAILED"); err != nil {
+			return err
+		}
 		return err
 	}
 
-	tasks, err := b.store.ListTasks(appId)
+	err := b.updateApplication(deployment.AppID, func(app *types.Application) error {
+		app.CurrentVersion = deployment.VersionID
+		app.Status = "RUNNING"
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	go func() error {
-		if err := b.doRollback(tasks, version); err != nil {
-			logrus.Errorf("Rollback application failed: %s", appId)
-			if err := b.store.UpdateApplicationStatus(app.ID, "ROLLBACK-FAILED"); err != nil {
-				return err
-			}
-			return err
-		}
-
-		if err := b.store.UpdateApplicationStatus(app.ID, "RUNNING"); err != nil {
-			return err
-		}
+	b.publish(&types.Event{Type: types.EventAppStatus, AppID: deployment.AppID, Status: "RUNNING"})
 
-		return nil
-	}()
+	b.finishDeployment(deployment, "FINISHED", "")
 
 	return nil
 }
 
-func (b *Backend) doRollback(tasks []*types.Task, version *types.Version) error {
+func (b *Backend) doRollback(deployment *types.Deployment, tasks []*types.Task, version *types.Version) error {
 	for _, task := range tasks {
 		// Stop task health check
 		if b.sched.HealthCheckManager.HasCheck(task.Name) {
@@ -78,84 +124,24 @@ func (b *Backend) doRollback(tasks []*types.Task, version *types.Version) error
 			logrus.Errorf("Delete task health check %s from db failed: %s", task.ID, err.Error())
 		}
 
-		if _, err := b.sched.KillTask(task); err == nil {
+		if err := b.sched.Kill(task); err == nil {
 			b.store.DeleteTask(task.ID)
 		}
 
-		b.sched.Status = "busy"
-
-		resources := b.sched.BuildResources(version.Cpus, version.Mem, version.Disk)
-		offers, err := b.sched.RequestOffers(resources)
-		if err != nil {
-			logrus.Errorf("Request offers failed: %s", err.Error())
-		}
-
-		var choosedOffer *mesos.Offer
-		for _, offer := range offers {
-			cpus, mem, disk := b.sched.OfferedResources(offer)
-			if cpus >= version.Cpus && mem >= version.Mem && disk >= version.Disk {
-				choosedOffer = offer
-				break
-			}
-		}
-
-		task, err := b.sched.BuildTask(choosedOffer, version, task.Name)
+		task, err := b.sched.BuildTask(version, task.Name)
 		if err != nil {
 			logrus.Errorf("Build task failed: %s", err.Error())
 			return err
 		}
 
-		var taskInfos []*mesos.TaskInfo
-		taskInfo := b.sched.BuildTaskInfo(choosedOffer, resources, task)
-		taskInfos = append(taskInfos, taskInfo)
-
-		resp, err := b.sched.LaunchTasks(choosedOffer, taskInfos)
-		if err != nil {
+		if _, err := b.launchTask(deployment.Actor, task); err != nil {
 			logrus.Errorf("Launchs task failed: %s", err.Error())
 			return err
 		}
 
-		if resp != nil && resp.StatusCode != http.StatusAccepted {
-			return fmt.Errorf("status code %d received", resp.StatusCode)
-		}
-
-		if err := b.store.SaveTask(task); err != nil {
+		if err := b.advanceDeployment(deployment); err != nil {
 			return err
 		}
-
-		if len(task.HealthChecks) != 0 {
-			if err := b.store.SaveCheck(task,
-				*taskInfo.Container.Docker.PortMappings[0].HostPort,
-				task.AppId); err != nil {
-			}
-			for _, healthCheck := range task.HealthChecks {
-				check := types.Check{
-					ID:       task.Name,
-					Address:  *task.AgentHostname,
-					Port:     int(*taskInfo.Container.Docker.PortMappings[0].HostPort),
-					TaskID:   task.Name,
-					AppID:    task.AppId,
-					Protocol: healthCheck.Protocol,
-					Interval: int(healthCheck.IntervalSeconds),
-					Timeout:  int(healthCheck.TimeoutSeconds),
-				}
-				if healthCheck.Command != nil {
-					check.Command = healthCheck.Command
-				}
-
-				if healthCheck.Path != nil {
-					check.Path = *healthCheck.Path
-				}
-
-				if healthCheck.MaxConsecutiveFailures != nil {
-					check.MaxFailures = *healthCheck.MaxConsecutiveFailures
-				}
-
-				b.sched.HealthCheckManager.Add(&check)
-			}
-		}
-
-		b.sched.Status = "idle"
 	}
 
 	return nil
@@ -3,183 +3,176 @@ package backend
 import (
 	"errors"
 	"fmt"
-	"net/http"
 	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
 
 	"github.com/Sirupsen/logrus"
 )
 
 // ScaleApplication is used to scale application instances.
-func (b *Backend) ScaleApplication(appId string, instances int) error {
-	app, err := b.store.FetchApplication(appId)
-	if err != nil {
-		return err
-	}
+func (b *Backend) ScaleApplication(actor, appId string, instances int) error {
+	var (
+		app        *types.Application
+		version    *types.Version
+		deployment *types.Deployment
+	)
+
+	err := b.store.Update(func(tx store.Tx) error {
+		var err error
+		app, err = tx.FetchApplication(appId)
+		if err != nil {
+			return err
+		}
 
-	if app.Status != "RUNNING" {
-		return errors.New("Operation Not Allowed")
-	}
+		if app == nil {
+			return errors.New("Application not found")
+		}
 
-	// Update application status to SCALING
-	if err := b.store.UpdateApplicationStatus(appId, "SCALING"); err != nil {
-		logrus.Errorf("Updating application status to SCALING failed: %s", err.Error())
-		return err
-	}
+		if app.Status != "RUNNING" && app.Status != "UNHEALTHY" {
+			return errors.New("Operation Not Allowed")
+		}
 
-	versions, err := b.store.ListVersions(appId)
-	if err != nil {
-		return err
-	}
+		versions, err := tx.ListVersions(appId)
+		if err != nil {
+			return err
+		}
+
+		sort.Strings(versions)
+
+		newestVersion := versions[len(versions)-1]
+		version, err = tx.FetchVersion(newestVersion)
+		if err != nil {
+			return err
+		}
 
-	sort.Strings(versions)
+		deployment = newDeployment(actor, appId, "SCALE", newestVersion)
+		deployment.Instances = instances
+		if err := tx.SaveDeployment(deployment); err != nil {
+			return err
+		}
 
-	newestVersion := versions[len(versions)-1]
-	version, err := b.store.FetchVersion(newestVersion)
+		before := store.Summarize(app)
+
+		// Update application status to SCALING
+		app.Status = "SCALING"
+		if err := tx.SaveApplication(app); err != nil {
+			return err
+		}
+
+		scaled := *app
+		scaled.Instances = instances
+
+		return store.Audit(tx, actor, "app.scale", appId, deployment.ID, before, &scaled)
+	})
 	if err != nil {
+		logrus.Errorf("Start scaling of application %s failed: %s", appId, err.Error())
 		return err
 	}
 
-	go func() error {
-		if app.Instances > instances {
-			tasks, err := b.store.ListTasks(app.ID)
-			if err != nil {
-				return err
-			}
-
-			for _, task := range tasks {
-				taskIndex, err := strconv.Atoi(strings.Split(task.Name, ".")[0])
-				if err != nil {
-					return err
-				}
+	b.publish(&types.Event{Type: types.EventAppStatus, AppID: appId, Status: "SCALING"})
+	b.publishDeployment(types.EventDeploymentStarted, deployment)
 
-				if taskIndex+1 > instances {
-					b.sched.HealthCheckManager.StopCheck(task.Name)
+	b.sched.Submit(appId, func() error {
+		return b.finishScale(deployment, app, version)
+	})
 
-					if err := b.store.DeleteCheck(task.Name); err != nil {
-						logrus.Errorf("Remove health check for %s failed: %s", task.Name, err.Error())
-						return err
-					}
+	return nil
+}
 
-					if _, err := b.sched.KillTask(task); err == nil {
-						b.store.DeleteTask(task.ID)
-					}
+// finishScale runs the scaling described by deployment and records its result.
+func (b *Backend) finishScale(deployment *types.Deployment, app *types.Application, version *types.Version) error {
+	if err := b.doScale(deployment.Actor, app, version, deployment.Instances); err != nil {
+		b.finishDeployment(deployment, "FAILED", err.Error())
+		return err
+	}
 
-					// reduce application tasks count
-					if err := b.store.ReduceApplicationInstances(app.ID); err != nil {
-						logrus.Errorf("Updating application %s instances count failed: %s", app.ID, err.Error())
-						return err
-					}
+	b.finishDeployment(deployment, "FINISHED", "")
 
-					logrus.Infof("Remove health check for task %s", task.Name)
+	return nil
+}
 
-					if err := b.store.DeleteTask(task.Name); err != nil {
-						logrus.Errorf("Delete task %s failed: %s", task.Name, err.Error())
-					}
+// doScale launches or kills instances until application has the specified instances.
+func (b *Backend) doScale(actor string, app *types.Application, version *types.Version, instances int) error {
+	if app.Instances > instances {
+		tasks, err := b.store.ListTasks(app.ID)
+		if err != nil {
+			return err
+		}
 
-				}
+		for _, task := range tasks {
+			taskIndex, err := strconv.Atoi(strings.Split(task.Name, ".")[0])
+			if err != nil {
+				return err
 			}
-		}
 
-		if app.Instances < instances {
-			for i := 0; i < instances-app.Instances; i++ {
-				b.sched.Status = "busy"
+			if taskIndex+1 > instances {
+				b.sched.HealthCheckManager.StopCheck(task.Name)
 
-				resources := b.sched.BuildResources(version.Cpus, version.Mem, version.Disk)
-				offers, err := b.sched.RequestOffers(resources)
-				if err != nil {
-					logrus.Errorf("Request offers failed: %s for rescheduling", err.Error())
+				if err := b.store.DeleteCheck(task.Name); err != nil {
+					logrus.Errorf("Remove health check for %s failed: %s", task.Name, err.Error())
+					return err
 				}
 
-				var choosedOffer *mesos.Offer
-				for _, offer := range offers {
-					cpus, mem, disk := b.sched.OfferedResources(offer)
-					if cpus >= version.Cpus && mem >= version.Mem && disk >= version.Disk {
-						choosedOffer = offer
-						break
-					}
+				if err := b.sched.Kill(task); err == nil {
+					b.store.DeleteTask(task.ID)
 				}
 
-				name := fmt.Sprintf("%d.%s.%s.%s", app.Instances+i, app.ID, app.UserId, app.ClusterId)
-
-				task, err := b.sched.BuildTask(choosedOffer, version, name)
+				// reduce application tasks count
+				err := b.updateApplication(app.ID, func(app *types.Application) error {
+					app.Instances -= 1
+					return nil
+				})
 				if err != nil {
-					logrus.Errorf("Build task failed: %s", err.Error())
+					logrus.Errorf("Updating application %s instances count failed: %s", app.ID, err.Error())
 					return err
 				}
 
-				var taskInfos []*mesos.TaskInfo
-				taskInfo := b.sched.BuildTaskInfo(choosedOffer, resources, task)
-				taskInfos = append(taskInfos, taskInfo)
+				logrus.Infof("Remove health check for task %s", task.Name)
 
-				resp, err := b.sched.LaunchTasks(choosedOffer, taskInfos)
-				if err != nil {
-					logrus.Errorf("Launchs task failed: %s", err.Error())
-					return err
+				if err := b.deleteTask(actor, task.Name); err != nil {
+					logrus.Errorf("Delete task %s failed: %s", task.Name, err.Error())
 				}
 
-				if resp != nil && resp.StatusCode != http.StatusAccepted {
-					return fmt.Errorf("status code %d received", resp.StatusCode)
-				}
-
-				if err := b.store.SaveTask(task); err != nil {
-					return err
-				}
+			}
+		}
+	}
 
-				if len(version.HealthChecks) != 0 {
-					if err := b.store.SaveCheck(task,
-						*taskInfo.Container.Docker.PortMappings[0].HostPort,
-						app.ID); err != nil {
-					}
-					for _, healthCheck := range task.HealthChecks {
-						check := types.Check{
-							ID:       task.Name,
-							Address:  *task.AgentHostname,
-							Port:     int(*taskInfo.Container.Docker.PortMappings[0].HostPort),
-							TaskID:   task.Name,
-							AppID:    app.ID,
-							Protocol: healthCheck.Protocol,
-							Interval: int(healthCheck.IntervalSeconds),
-							Timeout:  int(healthCheck.TimeoutSeconds),
-						}
-						if healthCheck.Command != nil {
-							check.Command = healthCheck.Command
-						}
-
-						if healthCheck.Path != nil {
-							check.Path = *healthCheck.Path
-						}
-
-						if healthCheck.MaxConsecutiveFailures != nil {
-							check.MaxFailures = *healthCheck.MaxConsecutiveFailures
-						}
-
-						b.sched.HealthCheckManager.Add(&check)
-					}
-				}
+	if app.Instances < instances {
+		for i := 0; i < instances-app.Instances; i++ {
+			name := fmt.Sprintf("%d.%s.%s.%s", app.Instances+i, app.ID, app.UserId, app.ClusterId)
 
-				// Increase application task count
-				if err := b.store.IncreaseApplicationInstances(version.ID); err != nil {
-					logrus.Errorf("Updating application %s instance count failed: %s", version.ID, err.Error())
-					return err
-				}
+			task, err := b.sched.BuildTask(version, name)
+			if err != nil {
+				logrus.Errorf("Build task failed: %s", err.Error())
+				return err
+			}
 
-				b.sched.Status = "idle"
+			if _, err := b.launchTask(actor, task); err != nil {
+				logrus.Errorf("Launchs task failed: %s", err.Error())
+				return err
 			}
-		}
 
-		// Update application status to RUNNING
-		if err := b.store.UpdateApplicationStatus(version.ID, "RUNNING"); err != nil {
-			logrus.Errorf("Updating application %s status to RUNNING failed: %s", version.ID, err.Error())
-			return err
+			// Increase application task count
+			err = b.updateApplication(version.ID, func(app *types.Application) error {
+				app.Instances += 1
+				return nil
+			})
+			if err != nil {
+				logrus.Errorf("Updating application %s instance count failed: %s", version.ID, err.Error())
+				return err
+			}
 		}
+	}
 
-		return nil
-	}()
+	// Update application status to RUNNING
+	if err := b.setApplicationStatus(version.ID, "RUNNING"); err != nil {
+		logrus.Errorf("Updating application %s status to RUNNING failed: %s", version.ID, err.Error())
+		return err
+	}
 
 	return nil
 }
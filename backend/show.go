@@ -1,14 +1,48 @@
 package backend
 
 import (
+	"fmt"
+
 	"github.com/Dataman-Cloud/swan/types"
 )
 
 func (b *Backend) FetchApplication(id string) (*types.Application, error) {
-	return b.store.FetchApplication(id)
+	app, err := b.store.FetchApplication(id)
+	if err != nil || app == nil {
+		return app, err
+	}
+
+	if err := b.countHealth(app); err != nil {
+		return nil, err
+	}
+
+	return app, nil
 }
 
 // FetchApplicationVersion is used to fetch specified version from db by version id and application id.
 func (b *Backend) FetchApplicationVersion(applicationId, versionId string) (*types.Version, error) {
 	return b.store.FetchVersion(versionId)
 }
+
+// FetchTaskHealth returns the health of task taskId of application appId
+// and the state of its checks. Tasks without checks have unknown health.
+func (b *Backend) FetchTaskHealth(appId, taskId string) (*types.TaskHealth, error) {
+	task, err := b.store.FetchTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.AppId != appId {
+		return nil, fmt.Errorf("Task %s not found in application %s", taskId, appId)
+	}
+
+	if health := b.sched.HealthCheckManager.TaskHealth(task.Name); health != nil {
+		return health, nil
+	}
+
+	return &types.TaskHealth{
+		TaskID: task.Name,
+		Health: "unknown",
+		Checks: []*types.CheckStatus{},
+	}, nil
+}
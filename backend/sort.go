@@ -16,3 +16,9 @@ func (s TaskSorter) Less(i, j int) bool {
 
 	return a < b
 }
+
+type OrphanSorter []*types.Orphan
+
+func (s OrphanSorter) Len() int           { return len(s) }
+func (s OrphanSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s OrphanSorter) Less(i, j int) bool { return s[i].ID < s[j].ID }
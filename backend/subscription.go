@@ -0,0 +1,148 @@
+package backend
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+// CreateSubscription registers a callback URL for the events selected by
+// subscription.
+func (b *Backend) CreateSubscription(actor string, subscription *types.Subscription) error {
+	u, err := url.Parse(subscription.URL)
+	if err != nil {
+		return fmt.Errorf("Invalid url %s: %s", subscription.URL, err.Error())
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid url %s, expected http or https url", subscription.URL)
+	}
+
+	subscription.ID = fmt.Sprintf("%d", time.Now().UnixNano())
+	subscription.Created = time.Now().Unix()
+
+	err = b.store.Update(func(tx store.Tx) error {
+		if err := tx.SaveSubscription(subscription); err != nil {
+			return err
+		}
+
+		return store.Audit(tx, actor, "subscription.create", subscription.AppID, "subscription/"+subscription.ID, nil, subscription)
+	})
+	if err != nil {
+		return err
+	}
+
+	// the webhook dispatcher reloads its subscriptions on this event.
+	b.publish(&types.Event{
+		Type:    types.EventSubscriptionCreated,
+		AppID:   subscription.AppID,
+		Message: fmt.Sprintf("Subscription %s", subscription.ID),
+	})
+
+	return nil
+}
+
+// ListSubscriptions lists the subscriptions, without their secrets.
+func (b *Backend) ListSubscriptions() ([]*types.Subscription, error) {
+	subscriptions, err := b.store.ListSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subscription := range subscriptions {
+		redact(subscription)
+	}
+
+	return subscriptions, nil
+}
+
+// FetchSubscription fetches a subscription, without its secret.
+func (b *Backend) FetchSubscription(id string) (*types.Subscription, error) {
+	subscription, err := b.store.FetchSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return redact(subscription), nil
+}
+
+// DeleteSubscription deletes a subscription and its dead letters.
+func (b *Backend) DeleteSubscription(actor, id string) error {
+	subscription, err := b.store.FetchSubscription(id)
+	if err != nil {
+		return err
+	}
+
+	err = b.store.Update(func(tx store.Tx) error {
+		if err := tx.DeleteSubscription(id); err != nil {
+			return err
+		}
+
+		return store.Audit(tx, actor, "subscription.delete", subscription.AppID, "subscription/"+id, subscription, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	b.publish(&types.Event{
+		Type:    types.EventSubscriptionDeleted,
+		AppID:   subscription.AppID,
+		Message: fmt.Sprintf("Subscription %s", id),
+	})
+
+	return nil
+}
+
+// ListDeadLetters lists the events which could not be delivered to a
+// subscription, oldest first.
+func (b *Backend) ListDeadLetters(id string) ([]*types.DeadLetter, error) {
+	if _, err := b.store.FetchSubscription(id); err != nil {
+		return nil, err
+	}
+
+	letters, err := b.store.ListDeadLetters(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if letters == nil {
+		letters = make([]*types.DeadLetter, 0)
+	}
+
+	return letters, nil
+}
+
+// DeleteDeadLetters clears the dead letters of a subscription.
+func (b *Backend) DeleteDeadLetters(id string) error {
+	letters, err := b.ListDeadLetters(id)
+	if err != nil {
+		return err
+	}
+
+	return b.store.Update(func(tx store.Tx) error {
+		for _, letter := range letters {
+			if err := tx.DeleteDeadLetter(letter.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// redact hides the secret of subscription, whether it has one is kept
+// visible.
+func redact(subscription *types.Subscription) *types.Subscription {
+	if subscription == nil {
+		return nil
+	}
+
+	if subscription.Secret != "" {
+		subscription.Secret = "******"
+	}
+
+	return subscription
+}
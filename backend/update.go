@@ -4,88 +4,136 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"net/http"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
 
 	"github.com/Sirupsen/logrus"
 )
 
 // UpdateApplication is used for application rolling-update.
-func (b *Backend) UpdateApplication(appId string, instances int, version *types.Version) error {
+func (b *Backend) UpdateApplication(actor, appId string, instances int, version *types.Version) error {
 	logrus.Infof("Updating application %s", appId)
-	app, err := b.store.FetchApplication(appId)
-	if err != nil {
-		return err
-	}
 
-	if app == nil {
-		return errors.New("Application not found")
-	}
+	var (
+		tasks      []*types.Task
+		deployment *types.Deployment
+		begin, end int
+	)
 
-	if app.Status != "RUNNING" {
-		return errors.New("Operation Not Allowed")
-	}
+	// The status moves to UPDATING together with the deployment record so
+	// concurrent operations on the application can not interleave.
+	err := b.store.Update(func(tx store.Tx) error {
+		app, err := tx.FetchApplication(appId)
+		if err != nil {
+			return err
+		}
 
-	// Update application status to UPDATING
-	if err := b.store.UpdateApplicationStatus(appId, "UPDATING"); err != nil {
-		logrus.Errorf("Setting application %s status to UPDATING for rolling-update failed: %s", appId, err.Error())
-		return err
-	}
+		if app == nil {
+			return errors.New("Application not found")
+		}
 
-	tasks, err := b.store.ListTasks(appId)
-	if err != nil {
-		logrus.Errorf("List application %s tasks failed: %s", appId, err.Error())
-		return err
-	}
+		if app.Status != "RUNNING" && app.Status != "UNHEALTHY" {
+			return errors.New("Operation Not Allowed")
+		}
 
-	sort.Sort(TaskSorter(tasks))
+		tasks, err = tx.ListTasks(appId)
+		if err != nil {
+			logrus.Errorf("List application %s tasks failed: %s", appId, err.Error())
+			return err
+		}
 
-	begin, end := app.UpdatedInstances, app.UpdatedInstances+instances
-	if instances == -1 {
-		begin, end = 0, len(tasks)
-	}
+		sort.Sort(TaskSorter(tasks))
 
-	if end > app.Instances {
-		end = app.Instances
-	}
+		begin, end = app.UpdatedInstances, app.UpdatedInstances+instances
+		if instances == -1 {
+			begin, end = 0, len(tasks)
+		}
 
-	go func() error {
-		if err := b.doUpdate(tasks[begin:end], version); err != nil {
-			logrus.Errorf("Update application %s failed, rollback to previous version.", appId)
-			return b.RollbackApplication(appId)
+		if end > app.Instances {
+			end = app.Instances
 		}
 
-		app, err = b.store.FetchApplication(appId)
+		versions, err := tx.ListVersions(appId)
 		if err != nil {
 			return err
 		}
 
-		// Rest application updated instance count to zero.
-		if app.UpdatedInstances == app.Instances {
-			if err := b.store.ResetApplicationUpdatedInstances(app.ID); err != nil {
-				return err
-			}
+		sort.Strings(versions)
 
+		deployment = newDeployment(actor, appId, "UPDATE", versions[len(versions)-1])
+		deployment.Begin, deployment.End = begin, end
+		deployment.Tasks = taskNames(tasks[begin:end])
+		if err := tx.SaveDeployment(deployment); err != nil {
+			return err
 		}
-		// Update application status to RUNNING
-		if err := b.store.UpdateApplicationStatus(app.ID, "RUNNING"); err != nil {
+
+		before := store.Summarize(app)
+
+		// Update application status to UPDATING
+		app.Status = "UPDATING"
+		if err := tx.SaveApplication(app); err != nil {
 			return err
 		}
 
-		logrus.Infof("Updating application %s finished", app.ID)
+		return store.Audit(tx, actor, "app.update", appId, deployment.ID, before, app)
+	})
+	if err != nil {
+		logrus.Errorf("Start rolling-update of application %s failed: %s", appId, err.Error())
+		return err
+	}
+
+	b.publish(&types.Event{Type: types.EventAppStatus, AppID: appId, Status: "UPDATING"})
+	b.publishDeployment(types.EventDeploymentStarted, deployment)
+
+	b.sched.Submit(appId, func() error {
+		return b.finishUpdate(deployment, tasks[begin:end], version)
+	})
+
+	return nil
+}
+
+// finishUpdate runs the rolling-update described by deployment and rollback
+// the application if update failed.
+func (b *Backend) finishUpdate(deployment *types.Deployment, tasks []*types.Task, version *types.Version) error {
+	appId := deployment.AppID
+	if err := b.doUpdate(deployment, tasks, version); err != nil {
+		logrus.Errorf("Update application %s failed, rollback to previous version.", appId)
+		b.finishDeployment(deployment, "FAILED", err.Error())
+		return b.RollbackApplication(deployment.Actor, appId)
+	}
+
+	err := b.updateApplication(appId, func(app *types.Application) error {
+		// Rest application updated instance count to zero.
+		if app.UpdatedInstances == app.Instances {
+			app.UpdatedInstances = 0
+			app.CurrentVersion = deployment.VersionID
+		}
+
+		// Update application status to RUNNING
+		app.Status = "RUNNING"
 
 		return nil
-	}()
+	})
+	if err != nil {
+		return err
+	}
+
+	b.publish(&types.Event{Type: types.EventAppStatus, AppID: appId, Status: "RUNNING"})
+
+	b.finishDeployment(deployment, "FINISHED", "")
+
+	logrus.Infof("Updating application %s finished", appId)
 
 	return nil
 }
 
 // doUpdate update application instances one by one.
-func (b *Backend) doUpdate(tasks []*types.Task, version *types.Version) error {
+func (b *Backend) doUpdate(deployment *types.Deployment, tasks []*types.Task, version *types.Version) error {
 	for _, task := range tasks {
 		// Stop task health check
 		b.sched.HealthCheckManager.StopCheck(task.Name)
@@ -95,121 +143,161 @@ func (b *Backend) doUpdate(tasks []*types.Task, version *types.Version) error {
 			logrus.Errorf("Delete task health check %s from db failed: %s", task.ID, err.Error())
 		}
 
-		if _, err := b.sched.KillTask(task); err == nil {
+		if err := b.sched.Kill(task); err == nil {
 			b.store.DeleteTask(task.ID)
 		}
 
 		//Reduce application running instance count.
-		if err := b.store.ReduceApplicationRunningInstances(task.AppId); err != nil {
+		err := b.updateApplication(task.AppId, func(app *types.Application) error {
+			app.RunningInstances -= 1
+			return nil
+		})
+		if err != nil {
 			return err
 		}
 
-		b.sched.Status = "busy"
-
 		logrus.Infof("Launch task %s with new version", task.Name)
 
-		resources := b.sched.BuildResources(version.Cpus, version.Mem, version.Disk)
-		offers, err := b.sched.RequestOffers(resources)
-		if err != nil {
-			logrus.Errorf("Request offers failed: %s", err.Error())
-		}
-
-		var choosedOffer *mesos.Offer
-		for _, offer := range offers {
-			cpus, mem, disk := b.sched.OfferedResources(offer)
-			if cpus >= version.Cpus && mem >= version.Mem && disk >= version.Disk {
-				choosedOffer = offer
-				break
-			}
-		}
-
-		task, err := b.sched.BuildTask(choosedOffer, version, task.Name)
+		task, err := b.sched.BuildTask(version, task.Name)
 		if err != nil {
 			logrus.Errorf("Build task failed: %s", err.Error())
 			return err
 		}
 
-		var taskInfos []*mesos.TaskInfo
-		taskInfo := b.sched.BuildTaskInfo(choosedOffer, resources, task)
-		taskInfos = append(taskInfos, taskInfo)
-
-		resp, err := b.sched.LaunchTasks(choosedOffer, taskInfos)
-		if err != nil {
+		if _, err := b.launchTask(deployment.Actor, task); err != nil {
 			logrus.Errorf("Launchs task failed: %s", err.Error())
+			return err
 		}
 
-		if resp != nil && resp.StatusCode != http.StatusAccepted {
-			logrus.Errorf("status code %d received", resp.StatusCode)
+		// The next old instance is only killed once the new one is ready,
+		// or passed the update check if it has no readiness checks.
+		if len(task.ReadinessChecks) != 0 {
+			if err := b.waitReady(task.Name, version.UpdatePolicy); err != nil {
+				return err
+			}
+		} else if addr, gracePeriod := updateCheckTarget(task); addr != "" {
+			if err := b.doCheck(addr, gracePeriod, version.UpdatePolicy); err != nil {
+				return err
+			}
 		}
 
-		b.sched.Status = "idle"
+		// The new instance and the progress of the deployment are recorded
+		// together, so a resumed update starts at the right task.
+		err = b.store.Update(func(tx store.Tx) error {
+			app, err := tx.FetchApplication(task.AppId)
+			if err != nil {
+				return err
+			}
 
-		if err := b.store.SaveTask(task); err != nil {
-			return err
-		}
+			if app == nil {
+				return errors.New("Application not found")
+			}
 
-		if err := b.doCheck(
-			fmt.Sprintf("%s:%d",
-				*choosedOffer.Hostname,
-				int(*taskInfo.Container.Docker.PortMappings[0].HostPort)),
-			version.UpdatePolicy); err != nil {
+			app.RunningInstances += 1
+			app.UpdatedInstances += 1
+			if err := tx.SaveApplication(app); err != nil {
+				return err
+			}
+
+			return stepDeployment(tx, deployment)
+		})
+		if err != nil {
 			return err
 		}
 
-		if len(task.HealthChecks) != 0 {
-			if err := b.store.SaveCheck(task,
-				*taskInfo.Container.Docker.PortMappings[0].HostPort,
-				version.ID); err != nil {
-			}
-			for _, healthCheck := range task.HealthChecks {
-				check := types.Check{
-					ID:       task.Name,
-					Address:  *task.AgentHostname,
-					Port:     int(*taskInfo.Container.Docker.PortMappings[0].HostPort),
-					TaskID:   task.Name,
-					AppID:    version.ID,
-					Protocol: healthCheck.Protocol,
-					Interval: int(healthCheck.IntervalSeconds),
-					Timeout:  int(healthCheck.TimeoutSeconds),
-				}
-				if healthCheck.Command != nil {
-					check.Command = healthCheck.Command
-				}
+		b.publishDeployment(types.EventDeploymentStep, deployment)
+	}
 
-				if healthCheck.Path != nil {
-					check.Path = *healthCheck.Path
-				}
+	return nil
+}
 
-				if healthCheck.MaxConsecutiveFailures != nil {
-					check.MaxFailures = *healthCheck.MaxConsecutiveFailures
-				}
+// defaultReadyTimeout is how long a rolling update waits for a new instance
+// to become ready unless the update policy says otherwise.
+const defaultReadyTimeout = 10 * time.Minute
 
-				b.sched.HealthCheckManager.Add(&check)
+// waitReady waits until the readiness checks of the task named name passed.
+func (b *Backend) waitReady(name string, update *types.UpdatePolicy) error {
+	timeout := defaultReadyTimeout
+	if update != nil && update.ReadyTimeout > 0 {
+		timeout = time.Duration(update.ReadyTimeout) * time.Second
+	}
 
-			}
-		}
-		//increase application running instance count.
-		if err := b.store.IncreaseApplicationRunningInstances(task.AppId); err != nil {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	quit := time.After(timeout)
+
+	for {
+		task, err := b.store.FetchTask(name)
+		if err != nil {
 			return err
 		}
 
-		if err := b.store.IncreaseApplicationUpdatedInstances(task.AppId); err != nil {
-			return err
+		if task.Ready {
+			logrus.Infof("Task %s is ready", name)
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return fmt.Errorf("Task %s not ready after %s", name, timeout)
 		}
 	}
+}
 
-	return nil
+// updateCheckTarget returns the address the update policy checks for the
+// new instance task, that of its first TCP or HTTP health check or else its
+// first host port, with the grace period of that check. Empty means there
+// is nothing to check, like a USER network task which has no address yet.
+func updateCheckTarget(task *types.Task) (string, time.Duration) {
+	for _, healthCheck := range task.HealthChecks {
+		switch strings.ToLower(healthCheck.Protocol) {
+		case "tcp", "http", "https":
+			address, port, err := store.CheckTarget(task, healthCheck)
+			if err != nil {
+				logrus.Warnf("Skip update check of task %s: %s", task.Name, err.Error())
+				return "", 0
+			}
+
+			return net.JoinHostPort(address, strconv.Itoa(port)), time.Duration(healthCheck.GracePeriodSeconds) * time.Second
+		}
+	}
+
+	if len(task.Ports) == 0 || task.AgentHostname == nil {
+		return "", 0
+	}
+
+	return net.JoinHostPort(*task.AgentHostname, strconv.Itoa(int(task.Ports[0]))), 0
 }
 
-func (b *Backend) doCheck(addr string, update *types.UpdatePolicy) error {
+// Defaults of the update check unless the update policy says otherwise.
+const (
+	defaultUpdateDelay  = 10 * time.Second
+	defaultMaxFailovers = 3
+)
+
+// doCheck checks addr until the update delay passed, failures in the grace
+// period before the first success do not count.
+func (b *Backend) doCheck(addr string, gracePeriod time.Duration, update *types.UpdatePolicy) error {
+	delay, maxFailovers := defaultUpdateDelay, defaultMaxFailovers
+	if update != nil && update.UpdateDelay > 0 {
+		delay = time.Duration(update.UpdateDelay) * time.Second
+	}
+	if update != nil && update.MaxFailovers > 0 {
+		maxFailovers = update.MaxFailovers
+	}
+
 	ticker := time.NewTicker(time.Duration(2) * time.Second)
 
-	quit := time.After(time.Duration(update.UpdateDelay) * time.Second)
+	quit := time.After(delay)
 
+	graceUntil := time.Now().Add(gracePeriod)
+	succeeded := false
 	failureTimes := 0
 
 	for {
-		if failureTimes >= update.MaxFailovers {
+		if failureTimes >= maxFailovers {
 			return fmt.Errorf("Service Update Failed")
 		}
 
@@ -225,9 +313,12 @@ func (b *Backend) doCheck(addr string, update *types.UpdatePolicy) error {
 				addr,
 				time.Duration(2*time.Second))
 			if err != nil {
-				failureTimes++
+				if succeeded || time.Now().After(graceUntil) {
+					failureTimes++
+				}
 			}
 			if conn != nil {
+				succeeded = true
 				conn.Close()
 			}
 		case <-quit:
@@ -0,0 +1,150 @@
+// Package event implements the bus on which swan publishes the changes of
+// applications, tasks, deployments and health checks.
+package event
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+// subscriptionBuffer is the number of events a subscriber may fall behind
+// before it is dropped.
+const subscriptionBuffer = 256
+
+// Filter selects events, empty fields match every event.
+type Filter struct {
+	AppID string
+	Types []string
+}
+
+// Match reports whether event is selected by f.
+func (f *Filter) Match(event *types.Event) bool {
+	if f.AppID != "" && event.AppID != f.AppID {
+		return false
+	}
+
+	if len(f.Types) == 0 {
+		return true
+	}
+
+	for _, t := range f.Types {
+		if event.Type == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Bus delivers events to its subscribers and keeps the latest ones, so a
+// subscriber which reconnects can catch up. A nil bus drops all events.
+type Bus struct {
+	mu     sync.Mutex
+	size   int
+	lastID uint64
+	recent []*types.Event
+	subs   map[*Subscription]struct{}
+}
+
+// NewBus returns a bus which keeps the latest size events.
+func NewBus(size int) *Bus {
+	return &Bus{
+		size: size,
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the next id to event and delivers it. Subscribers which
+// are too far behind are dropped rather than blocking the publisher, they
+// catch up by subscribing again from the last event they received.
+func (b *Bus) Publish(event *types.Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	if event.Time == 0 {
+		event.Time = time.Now().Unix()
+	}
+
+	b.recent = append(b.recent, event)
+	if len(b.recent) > b.size {
+		b.recent = append([]*types.Event(nil), b.recent[len(b.recent)-b.size:]...)
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.Match(event) {
+			continue
+		}
+
+		select {
+		case sub.c <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events selected by filter and
+// the kept events published after lastID, oldest first. Zero lastID
+// replays nothing, an unknown one, from before a restart, replays all kept
+// events.
+func (b *Bus) Subscribe(filter Filter, lastID uint64) (*Subscription, []*types.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []*types.Event
+	if lastID != 0 {
+		for _, event := range b.recent {
+			if (event.ID > lastID || lastID > b.lastID) && filter.Match(event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	sub := &Subscription{
+		bus:    b,
+		filter: filter,
+		c:      make(chan *types.Event, subscriptionBuffer),
+	}
+	b.subs[sub] = struct{}{}
+
+	return sub, replay
+}
+
+// drop removes sub and closes its channel, b.mu must be held.
+func (b *Bus) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+
+	delete(b.subs, sub)
+	close(sub.c)
+}
+
+// Subscription receives the events published on a bus.
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	c      chan *types.Event
+}
+
+// Events returns the channel of the subscription, it is closed when the
+// subscription is closed or dropped because it fell behind.
+func (s *Subscription) Events() <-chan *types.Event {
+	return s.c
+}
+
+// Close stops the delivery of events to s.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.drop(s)
+}
@@ -0,0 +1,87 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublish(t *testing.T) {
+	bus := NewBus(10)
+
+	all, _ := bus.Subscribe(Filter{}, 0)
+	defer all.Close()
+
+	app, _ := bus.Subscribe(Filter{AppID: "foo", Types: []string{types.EventTaskStatus}}, 0)
+	defer app.Close()
+
+	bus.Publish(&types.Event{Type: types.EventTaskStatus, AppID: "foo", Status: "TASK_RUNNING"})
+	bus.Publish(&types.Event{Type: types.EventAppStatus, AppID: "foo", Status: "RUNNING"})
+	bus.Publish(&types.Event{Type: types.EventTaskStatus, AppID: "bar", Status: "TASK_RUNNING"})
+
+	for i := uint64(1); i <= 3; i++ {
+		event := <-all.Events()
+		assert.Equal(t, i, event.ID)
+		assert.NotEqual(t, int64(0), event.Time)
+	}
+
+	event := <-app.Events()
+	assert.Equal(t, uint64(1), event.ID)
+	assert.Equal(t, 0, len(app.Events()))
+}
+
+func TestReplay(t *testing.T) {
+	bus := NewBus(3)
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(&types.Event{Type: types.EventTaskStatus, AppID: "foo"})
+	}
+
+	sub, replay := bus.Subscribe(Filter{}, 0)
+	sub.Close()
+	assert.Equal(t, 0, len(replay))
+
+	// Only the latest events are kept.
+	sub, replay = bus.Subscribe(Filter{}, 1)
+	sub.Close()
+	assert.Equal(t, 3, len(replay))
+	assert.Equal(t, uint64(3), replay[0].ID)
+
+	sub, replay = bus.Subscribe(Filter{}, 4)
+	sub.Close()
+	assert.Equal(t, 1, len(replay))
+	assert.Equal(t, uint64(5), replay[0].ID)
+
+	// Ids from before a restart replay everything kept.
+	sub, replay = bus.Subscribe(Filter{}, 100)
+	sub.Close()
+	assert.Equal(t, 3, len(replay))
+
+	sub, replay = bus.Subscribe(Filter{AppID: "bar"}, 1)
+	sub.Close()
+	assert.Equal(t, 0, len(replay))
+}
+
+func TestDropSlowSubscriber(t *testing.T) {
+	bus := NewBus(10)
+
+	sub, _ := bus.Subscribe(Filter{}, 0)
+	for i := 0; i < subscriptionBuffer+1; i++ {
+		bus.Publish(&types.Event{Type: types.EventTaskStatus})
+	}
+
+	n := 0
+	for range sub.Events() {
+		n++
+	}
+	assert.Equal(t, subscriptionBuffer, n)
+
+	// Closing a dropped subscription is fine.
+	sub.Close()
+}
+
+func TestNilBus(t *testing.T) {
+	var bus *Bus
+	bus.Publish(&types.Event{Type: types.EventTaskStatus})
+}
@@ -0,0 +1,274 @@
+package ha
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/raft-boltdb"
+)
+
+// Config configures the raft node of a swan instance.
+type Config struct {
+	// Addr is the address raft listens on and advertises to its peers.
+	Addr string
+
+	// Peers are all members of the cluster, this instance included.
+	Peers []Peer
+
+	// DataDir holds the raft log and snapshots.
+	DataDir string
+
+	// FSM is the state machine replicated through raft, if nil only the
+	// leader is elected.
+	FSM raft.FSM
+}
+
+// Node is a member of a swan cluster. Only the leader talks to mesos, the
+// API of the followers forwards requests to it.
+type Node struct {
+	raft      *raft.Raft
+	transport *raft.NetworkTransport
+	notify    chan bool
+	apiAddr   string
+	ready     int32
+}
+
+// Peer is a member of the cluster.
+type Peer struct {
+	APIAddr  string
+	RaftAddr string
+}
+
+// ParsePeers parses a comma separated list of <apiAddr>@<raftAddr>.
+func ParsePeers(peers string) ([]Peer, error) {
+	var parsed []Peer
+	for _, peer := range strings.Split(peers, ",") {
+		peer = strings.TrimSpace(peer)
+		if peer == "" {
+			continue
+		}
+
+		parts := strings.Split(peer, "@")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("Invalid peer %s, expected <apiAddr>@<raftAddr>", peer)
+		}
+
+		parsed = append(parsed, Peer{APIAddr: parts[0], RaftAddr: parts[1]})
+	}
+
+	return parsed, nil
+}
+
+// NewNode starts the raft node. A cluster without state is bootstrapped with
+// all peers, every member is started with the same peers so they agree.
+func NewNode(config Config) (*Node, error) {
+	var self *Peer
+	servers := make([]raft.Server, 0, len(config.Peers))
+	for i, peer := range config.Peers {
+		if peer.RaftAddr == config.Addr {
+			self = &config.Peers[i]
+		}
+
+		servers = append(servers, raft.Server{
+			ID:      raft.ServerID(peer.APIAddr),
+			Address: raft.ServerAddress(peer.RaftAddr),
+		})
+	}
+
+	if self == nil {
+		return nil, fmt.Errorf("Raft address %s is not one of the peers", config.Addr)
+	}
+
+	if err := os.MkdirAll(config.DataDir, 0700); err != nil {
+		return nil, err
+	}
+
+	advertise, err := net.ResolveTCPAddr("tcp", config.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := raft.NewTCPTransport(config.Addr, advertise, 3, 10*time.Second, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := raftboltdb.NewBoltStore(filepath.Join(config.DataDir, "raft.db"))
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+
+	snapshots, err := raft.NewFileSnapshotStore(config.DataDir, 2, os.Stderr)
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+
+	notify := make(chan bool, 1)
+
+	conf := raft.DefaultConfig()
+	conf.LocalID = raft.ServerID(self.APIAddr)
+	conf.NotifyCh = notify
+
+	exists, err := raft.HasExistingState(logs, logs, snapshots)
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+
+	if !exists {
+		logrus.Infof("Bootstrap raft cluster with %d peers", len(servers))
+		if err := raft.BootstrapCluster(conf, logs, logs, snapshots, transport,
+			raft.Configuration{Servers: servers}); err != nil {
+			transport.Close()
+			return nil, err
+		}
+	}
+
+	var machine raft.FSM = &fsm{}
+	if config.FSM != nil {
+		machine = config.FSM
+	}
+
+	r, err := raft.NewRaft(conf, machine, logs, logs, snapshots, transport)
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+
+	return &Node{
+		raft:      r,
+		transport: transport,
+		notify:    notify,
+		apiAddr:   self.APIAddr,
+	}, nil
+}
+
+// LeaderCh reports true when this node becomes leader and false when it
+// loses the leadership.
+func (n *Node) LeaderCh() <-chan bool {
+	return n.notify
+}
+
+// IsLeader reports whether this node is the leader.
+func (n *Node) IsLeader() bool {
+	return n.raft.State() == raft.Leader
+}
+
+// Ready reports whether the leader finished reconciling and serves requests.
+func (n *Node) Ready() bool {
+	return atomic.LoadInt32(&n.ready) == 1
+}
+
+// SetReady marks the leader ready to serve requests.
+func (n *Node) SetReady() {
+	atomic.StoreInt32(&n.ready, 1)
+}
+
+// LeaderAddr returns the API address of the leader, empty if there is no
+// leader at the moment.
+func (n *Node) LeaderAddr() string {
+	leader := n.raft.Leader()
+	if leader == "" {
+		return ""
+	}
+
+	future := n.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return ""
+	}
+
+	for _, server := range future.Configuration().Servers {
+		if server.Address == leader {
+			return string(server.ID)
+		}
+	}
+
+	return ""
+}
+
+// IsPeer reports whether host is the host of the API or raft address of a
+// member of the cluster. Hosts are compared as configured, without
+// resolving names.
+func (n *Node) IsPeer(host string) bool {
+	future := n.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return false
+	}
+
+	for _, server := range future.Configuration().Servers {
+		for _, addr := range []string{string(server.ID), string(server.Address)} {
+			if h, _, err := net.SplitHostPort(addr); err == nil && h == host {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Raft returns the raft instance of this node.
+func (n *Node) Raft() *raft.Raft {
+	return n.raft
+}
+
+// Barrier waits until the leader applied every entry committed by its
+// predecessors, so the state it acts on is up to date.
+func (n *Node) Barrier(timeout time.Duration) error {
+	return n.raft.Barrier(timeout).Error()
+}
+
+// APIAddr returns the API address of this node.
+func (n *Node) APIAddr() string {
+	return n.apiAddr
+}
+
+// Shutdown leaves the cluster. The leader hands its leadership over to
+// another member first so the cluster does not wait for an election timeout.
+func (n *Node) Shutdown() error {
+	if n.IsLeader() {
+		atomic.StoreInt32(&n.ready, 0)
+		if err := n.raft.LeadershipTransfer().Error(); err != nil {
+			logrus.Warnf("Transfer leadership failed: %s", err.Error())
+		}
+	}
+
+	if err := n.raft.Shutdown().Error(); err != nil {
+		return err
+	}
+
+	return n.transport.Close()
+}
+
+// fsm is the state machine of a cluster which does not replicate any
+// state, raft is only used to elect the leader.
+type fsm struct{}
+
+func (f *fsm) Apply(*raft.Log) interface{} {
+	return nil
+}
+
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	return &snapshot{}, nil
+}
+
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	return rc.Close()
+}
+
+type snapshot struct{}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {}
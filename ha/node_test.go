@@ -0,0 +1,63 @@
+package ha
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePeers(t *testing.T) {
+	peers, err := ParsePeers("10.0.0.1:9999@10.0.0.1:2111, 10.0.0.2:9999@10.0.0.2:2111")
+	assert.Nil(t, err)
+	assert.Equal(t, []Peer{
+		{APIAddr: "10.0.0.1:9999", RaftAddr: "10.0.0.1:2111"},
+		{APIAddr: "10.0.0.2:9999", RaftAddr: "10.0.0.2:2111"},
+	}, peers)
+
+	_, err = ParsePeers("10.0.0.1:2111")
+	assert.NotNil(t, err)
+}
+
+func TestSingleNodeLeader(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	dir, err := ioutil.TempDir("", "swan-raft")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = NewNode(Config{
+		Addr:    addr,
+		Peers:   []Peer{{APIAddr: "127.0.0.1:9999", RaftAddr: "127.0.0.1:1"}},
+		DataDir: dir,
+	})
+	assert.NotNil(t, err)
+
+	node, err := NewNode(Config{
+		Addr:    addr,
+		Peers:   []Peer{{APIAddr: "127.0.0.1:9999", RaftAddr: addr}},
+		DataDir: dir,
+	})
+	assert.Nil(t, err)
+	defer node.Shutdown()
+
+	select {
+	case leader := <-node.LeaderCh():
+		assert.True(t, leader)
+	case <-time.After(10 * time.Second):
+		t.Fatal("not elected")
+	}
+
+	assert.True(t, node.IsLeader())
+	assert.False(t, node.Ready())
+	assert.Equal(t, "127.0.0.1:9999", node.LeaderAddr())
+
+	node.SetReady()
+	assert.True(t, node.Ready())
+}
@@ -1,6 +1,60 @@
 package health
 
+import (
+	"context"
+	"time"
+)
+
+// Result is the outcome of one run of a check.
+type Result struct {
+	Healthy bool
+
+	// Ignored results say nothing about the health of the task, like
+	// informational HTTP responses of checks which ignore them.
+	Ignored bool
+
+	// Message is the reason the task is not healthy.
+	Message string
+}
+
+// Checker runs one kind of check against a task. The manager schedules the
+// runs and counts the failures.
 type Checker interface {
-	Start()
-	Stop()
+	// Check runs the check once, it gives up when ctx is done.
+	Check(ctx context.Context) Result
+
+	// Protocol names the kind of check in logs and metrics.
+	Protocol() string
+}
+
+// failureCounter counts the consecutive failures of a check. Failures in
+// the grace period after the launch of the task do not count until the
+// check succeeded once.
+type failureCounter struct {
+	graceUntil time.Time
+	succeeded  bool
+	count      int
+}
+
+// newFailureCounter returns the counter of a check of a task launched at
+// the unix time launched with a grace period in seconds.
+func newFailureCounter(launched int64, gracePeriod int) *failureCounter {
+	return &failureCounter{
+		graceUntil: time.Unix(launched, 0).Add(time.Duration(gracePeriod) * time.Second),
+	}
+}
+
+func (f *failureCounter) success() {
+	f.succeeded = true
+	f.count = 0
+}
+
+// failure records a failure at now and reports whether it counts.
+func (f *failureCounter) failure(now time.Time) bool {
+	if !f.succeeded && now.Before(f.graceUntil) {
+		return false
+	}
+
+	f.count++
+	return true
 }
@@ -0,0 +1,26 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureCounter(t *testing.T) {
+	launched := time.Now()
+	failures := newFailureCounter(launched.Unix(), 60)
+
+	// Failures in the grace period do not count.
+	assert.False(t, failures.failure(launched.Add(time.Second)))
+	assert.Equal(t, 0, failures.count)
+
+	// Past the grace period they do.
+	assert.True(t, failures.failure(launched.Add(2*time.Minute)))
+	assert.Equal(t, 1, failures.count)
+
+	// A success resets the count and ends the grace period.
+	failures.success()
+	assert.Equal(t, 0, failures.count)
+	assert.True(t, failures.failure(launched.Add(time.Second)))
+}
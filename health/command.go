@@ -0,0 +1,91 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+// maxOutputSize is the part of the output of a command kept as the reason
+// of a failure.
+const maxOutputSize = 256
+
+// CommandRunner runs the command of a check, a nil error means healthy. It
+// must return once ctx is done.
+type CommandRunner interface {
+	Run(ctx context.Context, check *types.Check) error
+}
+
+// LocalRunner runs commands with sh on the host of swan. The task is
+// described to the command by the SWAN_APP_ID, SWAN_TASK_ID, SWAN_TASK_HOST
+// and SWAN_TASK_PORT environment variables.
+type LocalRunner struct{}
+
+func (LocalRunner) Run(ctx context.Context, check *types.Check) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", check.Command.Value)
+	cmd.Env = append(os.Environ(),
+		"SWAN_APP_ID="+check.AppID,
+		"SWAN_TASK_ID="+check.TaskID,
+		"SWAN_TASK_HOST="+check.Address,
+		"SWAN_TASK_PORT="+strconv.Itoa(check.Port),
+	)
+	// Children left behind by a killed shell must not hold up the check.
+	cmd.WaitDelay = time.Second
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("Command timed out")
+	}
+
+	if err != nil {
+		msg := strings.TrimSpace(output.String())
+		if len(msg) > maxOutputSize {
+			msg = msg[len(msg)-maxOutputSize:]
+		}
+
+		if msg == "" {
+			return err
+		}
+
+		return fmt.Errorf("%s: %s", err.Error(), msg)
+	}
+
+	return nil
+}
+
+// CommandChecker checks that the command of Target succeeds.
+type CommandChecker struct {
+	Target *types.Check
+	Runner CommandRunner
+}
+
+func NewCommandChecker(target *types.Check, runner CommandRunner) *CommandChecker {
+	return &CommandChecker{
+		Target: target,
+		Runner: runner,
+	}
+}
+
+func (c *CommandChecker) Check(ctx context.Context) Result {
+	if err := c.Runner.Run(ctx, c.Target); err != nil {
+		return Result{Message: err.Error()}
+	}
+
+	return Result{Healthy: true}
+}
+
+func (c *CommandChecker) Protocol() string {
+	return "command"
+}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func commandCheck(command string) *types.Check {
+	return &types.Check{
+		ID:       "yyy-0",
+		Protocol: "command",
+		Address:  "x.x.x.x",
+		Port:     8080,
+		AppID:    "xxx",
+		TaskID:   "yyy",
+		Command:  &types.Command{Value: command},
+	}
+}
+
+func TestLocalRunner(t *testing.T) {
+	runner := LocalRunner{}
+
+	assert.Nil(t, runner.Run(context.Background(), commandCheck("true")))
+	assert.Nil(t, runner.Run(context.Background(), commandCheck(`test "$SWAN_TASK_ID:$SWAN_TASK_PORT" = "yyy:8080"`)))
+
+	err := runner.Run(context.Background(), commandCheck("echo not ready; exit 3"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "exit status 3")
+	assert.Contains(t, err.Error(), "not ready")
+}
+
+func TestLocalRunnerTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := LocalRunner{}.Run(ctx, commandCheck("sleep 10"))
+	assert.Equal(t, "Command timed out", err.Error())
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+type fakeRunner struct {
+	err error
+}
+
+func (r *fakeRunner) Run(ctx context.Context, check *types.Check) error {
+	return r.err
+}
+
+func TestCommandChecker(t *testing.T) {
+	checker := NewCommandChecker(commandCheck("false"), &fakeRunner{err: errors.New("exit status 1")})
+	assert.Equal(t, "command", checker.Protocol())
+
+	result := checker.Check(context.Background())
+	assert.False(t, result.Healthy)
+	assert.Equal(t, "exit status 1", result.Message)
+
+	checker.Runner = &fakeRunner{}
+	assert.True(t, checker.Check(context.Background()).Healthy)
+}
+
+func TestHealthCheckManagerAddCommand(t *testing.T) {
+	m := NewHealthCheckManager(nil, nil)
+	m.CommandRunner = &fakeRunner{}
+
+	m.Add(commandCheck("true"))
+	assert.True(t, m.HasCheck("yyy"))
+	assert.Equal(t, m.CommandRunner, m.entries["yyy-0"].checker.(*CommandChecker).Runner)
+
+	check := commandCheck("")
+	check.TaskID = "zzz"
+	m.Add(check)
+	assert.False(t, m.HasCheck("zzz"))
+}
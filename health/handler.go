@@ -1,12 +1,14 @@
 package health
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
 	"github.com/Sirupsen/logrus"
 )
 
-type HandlerFunc func(string, string) error
-
 func (m *HealthCheckManager) HealthCheckFailedHandler(appId, taskId string) error {
 	logrus.Infof("Reschduler task %s for health check failed", taskId)
 	msg := types.ReschedulerMsg{
@@ -19,3 +21,97 @@ func (m *HealthCheckManager) HealthCheckFailedHandler(appId, taskId string) erro
 
 	return <-msg.Err
 }
+
+// HealthCheckRecoveredHandler tells the scheduler that task taskId, whose
+// failover was deferred, passed its health checks again.
+func (m *HealthCheckManager) HealthCheckRecoveredHandler(appId, taskId string) {
+	logrus.Infof("Task %s passed its health checks again", taskId)
+	m.msgQueue <- types.ReschedulerMsg{
+		AppID:     appId,
+		TaskID:    taskId,
+		Recovered: true,
+	}
+}
+
+// HealthCheckResultHandler publishes an event whenever the aggregated
+// health of a task changes, the first known health included.
+func (m *HealthCheckManager) HealthCheckResultHandler(appId, taskId, health, message string) {
+	if health == "unknown" {
+		return
+	}
+
+	m.resultsMu.Lock()
+	last := m.results[taskId]
+	m.results[taskId] = health
+	m.resultsMu.Unlock()
+
+	if last == health {
+		return
+	}
+
+	if err := m.saveTaskHealth(taskId, health, message); err != nil {
+		logrus.Errorf("Record health of task %s failed: %s", taskId, err.Error())
+	}
+
+	m.EventBus.Publish(&types.Event{
+		Type:    types.EventHealthStatus,
+		AppID:   appId,
+		TaskID:  taskId,
+		Status:  health,
+		Message: message,
+	})
+}
+
+// saveTaskHealth records the health of task taskId in the store.
+func (m *HealthCheckManager) saveTaskHealth(taskId, health, message string) error {
+	return m.store.Update(func(tx store.Tx) error {
+		task, err := tx.FetchTask(taskId)
+		if err != nil {
+			return err
+		}
+
+		before := fmt.Sprintf("health=%s", task.Health)
+
+		task.Health = health
+		task.HealthSince = time.Now().Unix()
+		task.HealthMessage = message
+
+		if err := tx.SaveTask(task); err != nil {
+			return err
+		}
+
+		after := fmt.Sprintf("health=%s message=%s", health, message)
+
+		return store.Audit(tx, types.ActorHealth, "task.health", task.AppId, task.Name, before, after)
+	})
+}
+
+// TaskReadyHandler records that the readiness checks of task taskId passed
+// and publishes it.
+func (m *HealthCheckManager) TaskReadyHandler(appId, taskId string) {
+	logrus.Infof("Task %s is ready", taskId)
+
+	err := m.store.Update(func(tx store.Tx) error {
+		task, err := tx.FetchTask(taskId)
+		if err != nil {
+			return err
+		}
+
+		task.Ready = true
+		if err := tx.SaveTask(task); err != nil {
+			return err
+		}
+
+		return tx.SaveCheck(task)
+	})
+	if err != nil {
+		logrus.Errorf("Record readiness of task %s failed: %s", taskId, err.Error())
+		return
+	}
+
+	m.EventBus.Publish(&types.Event{
+		Type:   types.EventTaskReady,
+		AppID:  appId,
+		TaskID: taskId,
+	})
+}
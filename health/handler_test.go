@@ -1,8 +1,12 @@
 package health
 
 import (
+	"github.com/Dataman-Cloud/swan/event"
 	"github.com/Dataman-Cloud/swan/health/mock"
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/store/memory"
 	"github.com/Dataman-Cloud/swan/types"
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -18,3 +22,91 @@ func TestHealthCheckFailedHandler(t *testing.T) {
 	err := m.HealthCheckFailedHandler("xxxxx", "yyyyy")
 	assert.Nil(t, err)
 }
+
+func TestHealthCheckResultHandler(t *testing.T) {
+	m := NewHealthCheckManager(&mock.Store{}, nil)
+	m.EventBus = event.NewBus(10)
+
+	sub, _ := m.EventBus.Subscribe(event.Filter{}, 0)
+	defer sub.Close()
+
+	m.HealthCheckResultHandler("xxxxx", "yyyyy", "healthy", "")
+	m.HealthCheckResultHandler("xxxxx", "yyyyy", "healthy", "")
+	m.HealthCheckResultHandler("xxxxx", "yyyyy", "unhealthy", "connection refused")
+	m.HealthCheckResultHandler("xxxxx", "yyyyy", "unhealthy", "connection refused")
+
+	// Only changes of the health are published.
+	assert.Equal(t, 2, len(sub.Events()))
+
+	e := <-sub.Events()
+	assert.Equal(t, types.EventHealthStatus, e.Type)
+	assert.Equal(t, "healthy", e.Status)
+
+	e = <-sub.Events()
+	assert.Equal(t, "unhealthy", e.Status)
+	assert.Equal(t, "connection refused", e.Message)
+
+	// A task checked again after its check stopped starts afresh.
+	m.StopCheck("yyyyy")
+	m.HealthCheckResultHandler("xxxxx", "yyyyy", "unhealthy", "connection refused")
+	assert.Equal(t, 1, len(sub.Events()))
+}
+
+func TestHealthCheckResultHandlerSavesHealth(t *testing.T) {
+	store := memory.NewMemoryStore()
+	store.SaveApplication(&types.Application{ID: "xxxxx"})
+	store.SaveTask(&types.Task{ID: "1-yyyyy", Name: "yyyyy", AppId: "xxxxx", Health: "unknown"})
+
+	m := NewHealthCheckManager(store, nil)
+	m.HealthCheckResultHandler("xxxxx", "yyyyy", "unhealthy", "connection refused")
+
+	task, _ := store.FetchTask("yyyyy")
+	assert.Equal(t, "unhealthy", task.Health)
+	assert.Equal(t, "connection refused", task.HealthMessage)
+	assert.NotZero(t, task.HealthSince)
+
+	entries, _ := store.ListAuditEntries(&types.AuditQuery{AppID: "xxxxx"})
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, types.ActorHealth, entries[0].Actor)
+	assert.Equal(t, "task.health", entries[0].Operation)
+	assert.Equal(t, "yyyyy", entries[0].Object)
+	assert.Equal(t, "health=unknown", entries[0].Before)
+	assert.Equal(t, "health=unhealthy message=connection refused", entries[0].After)
+
+	// Tasks which are gone are left alone.
+	m.HealthCheckResultHandler("xxxxx", "zzzzz", "healthy", "")
+	task, _ = store.FetchTask("zzzzz")
+	assert.Nil(t, task)
+}
+
+func TestTaskReadyHandler(t *testing.T) {
+	db := memory.NewMemoryStore()
+	task := &types.Task{
+		ID:              "1-yyyyy",
+		Name:            "yyyyy",
+		AppId:           "xxxxx",
+		AgentHostname:   proto.String("x.x.x.x"),
+		Ports:           []uint32{8080},
+		HealthChecks:    []*types.HealthCheck{&types.HealthCheck{Protocol: "tcp"}},
+		ReadinessChecks: []*types.ReadinessCheck{&types.ReadinessCheck{Path: "/ready"}},
+	}
+	db.SaveTask(task)
+	db.SaveCheck(task)
+
+	status := &types.CheckStatus{ID: "yyyyy-0", Result: "healthy"}
+	db.Update(func(tx store.Tx) error {
+		return tx.SaveCheckStatus(&types.Check{ID: "yyyyy-0", Status: status})
+	})
+
+	m := NewHealthCheckManager(db, nil)
+	m.TaskReadyHandler("xxxxx", "yyyyy")
+
+	task, _ = db.FetchTask("yyyyy")
+	assert.True(t, task.Ready)
+
+	// The readiness check is dropped, the health check keeps its status.
+	checks, _ := db.ListChecks()
+	assert.Equal(t, 1, len(checks))
+	assert.Equal(t, "yyyyy-0", checks[0].ID)
+	assert.Equal(t, "healthy", checks[0].Status.Result)
+}
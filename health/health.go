@@ -1,28 +1,89 @@
 package health
 
 import (
+	"container/heap"
+	"context"
 	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/Dataman-Cloud/swan/event"
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
 	"github.com/Sirupsen/logrus"
-	fifo "github.com/foize/go.fifo"
 )
 
+const (
+	// defaultWorkers bounds the checks run at the same time unless Workers
+	// is set.
+	defaultWorkers = 64
+
+	// defaultSaveInterval is how often the statuses of checks are saved
+	// unless SaveInterval is set.
+	defaultSaveInterval = 30 * time.Second
+)
+
+// HealthCheckManager runs the health checks of tasks. Checks wait in a
+// queue ordered by their next run and a bounded pool of workers runs the
+// due ones, so thousands of checks cost no more goroutines than workers.
 type HealthCheckManager struct {
-	store     Store
-	checkers  map[string]Checker
-	msgQueue  chan types.ReschedulerMsg
-	taskQueue *fifo.Queue
-	quit      chan struct{}
+	store    Store
+	msgQueue chan types.ReschedulerMsg
+
+	// Workers is the number of checks run at the same time, set before
+	// Start.
+	Workers int
+
+	// SaveInterval is how often the changed statuses of checks are saved
+	// to the store, set before Start.
+	SaveInterval time.Duration
+
+	// EventBus publishes the changes of the health of tasks, nil drops them.
+	EventBus *event.Bus
+
+	// CommandRunner runs the commands of command checks, nil runs them
+	// with LocalRunner.
+	CommandRunner CommandRunner
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	tasks   map[string]map[string]*entry
+	queue   entryHeap
+
+	wake    chan struct{}
+	started bool
+	quit    chan struct{}
+	stop    sync.Once
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// failovers holds the failed tasks whose replacement was asked for,
+	// true once it was deferred.
+	failovers map[string]bool
+
+	resultsMu sync.Mutex
+	results   map[string]string
 }
 
 func NewHealthCheckManager(store Store, queue chan types.ReschedulerMsg) *HealthCheckManager {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &HealthCheckManager{
-		store:     store,
-		msgQueue:  queue,
-		taskQueue: fifo.NewQueue(),
-		checkers:  make(map[string]Checker),
-		quit:      make(chan struct{}),
+		store:    store,
+		msgQueue: queue,
+		entries:  make(map[string]*entry),
+		tasks:    make(map[string]map[string]*entry),
+		wake:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		results:  make(map[string]string),
+
+		failovers: make(map[string]bool),
 	}
 }
 
@@ -44,92 +105,533 @@ func (m *HealthCheckManager) Init() {
 	}
 }
 
+// Start runs the due checks until Stop is called, later calls return at
+// once.
 func (m *HealthCheckManager) Start() {
+	m.mu.Lock()
+	if m.started {
+		m.mu.Unlock()
+		return
+	}
+	m.started = true
+	m.mu.Unlock()
+
+	workers := m.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	jobs := make(chan *entry)
+	defer close(jobs)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for e := range jobs {
+				m.run(e)
+			}
+		}()
+	}
+
+	go m.saveStatuses()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
 	for {
+		due, wait := m.due(time.Now())
+		for _, e := range due {
+			select {
+			case jobs <- e:
+			case <-m.quit:
+				return
+			}
+		}
+
+		if len(due) != 0 {
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
 		select {
+		case <-timer.C:
+		case <-m.wake:
 		case <-m.quit:
 			return
+		}
+	}
+}
+
+func (m *HealthCheckManager) Stop() {
+	m.stop.Do(func() {
+		close(m.quit)
+		m.cancel()
+	})
+}
+
+// saveStatuses saves the changed statuses of checks every SaveInterval
+// until Stop is called.
+func (m *HealthCheckManager) saveStatuses() {
+	interval := m.SaveInterval
+	if interval <= 0 {
+		interval = defaultSaveInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.SaveStatuses(); err != nil {
+				logrus.Errorf("Save health check statuses failed: %s", err.Error())
+			}
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// SaveStatuses saves the statuses of the health checks which changed since
+// they were last saved, so checks restored after a restart resume from them.
+func (m *HealthCheckManager) SaveStatuses() error {
+	var dirty []*entry
+	var checks []*types.Check
+
+	m.mu.Lock()
+	for _, e := range m.entries {
+		if !e.dirty || e.check.Readiness {
+			continue
+		}
+
+		check := *e.check
+		check.Status = e.status()
+		checks = append(checks, &check)
+
+		e.dirty = false
+		dirty = append(dirty, e)
+	}
+	m.mu.Unlock()
+
+	if len(checks) == 0 {
+		return nil
+	}
+
+	err := m.store.Update(func(tx store.Tx) error {
+		for _, check := range checks {
+			if err := tx.SaveCheckStatus(check); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		// Try again next time.
+		m.mu.Lock()
+		for _, e := range dirty {
+			e.dirty = true
+		}
+		m.mu.Unlock()
+	}
+
+	return err
+}
+
+// signal wakes the scheduling loop up to look at the queue again.
+func (m *HealthCheckManager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// due takes the checks whose run is due at now off the queue and returns
+// them with the time until the next run.
+func (m *HealthCheckManager) due(now time.Time) ([]*entry, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []*entry
+	for len(m.queue) != 0 && !m.queue[0].next.After(now) {
+		due = append(due, heap.Pop(&m.queue).(*entry))
+	}
+
+	if len(m.queue) == 0 {
+		return due, time.Hour
+	}
+
+	return due, m.queue[0].next.Sub(now)
+}
+
+// run runs the check of e once and schedules its next run.
+func (m *HealthCheckManager) run(e *entry) {
+	m.mu.Lock()
+	skip := e.removed
+	m.mu.Unlock()
+
+	if skip {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, e.timeout)
+	start := time.Now()
+	result := e.checker.Check(ctx)
+	cancel()
+
+	if m.ctx.Err() != nil {
+		return
+	}
+
+	if !result.Ignored {
+		observe(e.checker.Protocol(), start, result.Healthy)
+	}
+
+	m.mu.Lock()
+	if e.removed {
+		m.mu.Unlock()
+		return
+	}
+
+	if e.check.Readiness {
+		ready := m.ready(e, result)
+		m.mu.Unlock()
+
+		m.signal()
+
+		if ready {
+			m.TaskReadyHandler(e.check.AppID, e.check.TaskID)
+		}
+		return
+	}
+
+	now := time.Now()
+	counted, failed := false, false
+	if !result.Ignored {
+		e.dirty = true
+	}
+
+	switch {
+	case result.Ignored:
+		logrus.Debugf("Ignore result of health check %s of task %s: %s", e.check.ID, e.check.TaskID, result.Message)
+	case result.Healthy:
+		e.failures.success()
+		e.result = "healthy"
+		e.lastSuccess = now.Unix()
+		counted = true
+	default:
+		e.lastFailure = now.Unix()
+		e.lastError = result.Message
+
+		if !e.failures.failure(now) {
+			logrus.Debugf("Ignore failed health check %s of task %s in grace period: %s", e.check.ID, e.check.TaskID, result.Message)
+			break
+		}
+
+		logrus.Errorf("Health check %s of task %s protocol %s failed %d times: %s", e.check.ID, e.check.TaskID, e.checker.Protocol(), e.failures.count, result.Message)
+		e.result = "unhealthy"
+		counted = true
+		failed = e.failures.count >= e.maxFailures
+	}
+
+	// Failed tasks are checked until they are replaced, their failover
+	// may be deferred. It is asked for once.
+	e.next = now.Add(jitter(e.interval))
+	heap.Push(&m.queue, e)
+
+	if _, requested := m.failovers[e.check.TaskID]; requested {
+		failed = false
+	} else if failed {
+		m.failovers[e.check.TaskID] = false
+	}
+
+	health := m.taskHealth(e.check.TaskID)
+	recovered := m.recovered(e.check.TaskID, health)
+	m.mu.Unlock()
+
+	m.signal()
+
+	if counted {
+		m.HealthCheckResultHandler(e.check.AppID, e.check.TaskID, health, result.Message)
+	}
+
+	if failed {
+		go m.failover(e.check.AppID, e.check.TaskID)
+	}
+
+	if recovered {
+		go m.HealthCheckRecoveredHandler(e.check.AppID, e.check.TaskID)
+	}
+}
+
+// failover asks for the replacement of the failed task taskId and records
+// whether it was deferred.
+func (m *HealthCheckManager) failover(appId, taskId string) {
+	err := m.HealthCheckFailedHandler(appId, taskId)
+	if err != nil && err != types.ErrFailoverDeferred {
+		logrus.Errorf("Failover of task %s failed: %s", taskId, err.Error())
+	}
+
+	m.mu.Lock()
+	recovered := false
+	if _, requested := m.failovers[taskId]; requested {
+		switch err {
+		case types.ErrFailoverDeferred:
+			m.failovers[taskId] = true
+			recovered = m.recovered(taskId, m.taskHealth(taskId))
 		default:
-			if checker := m.Next(); checker != nil {
-				go func() {
-					checker.(Checker).Start()
-				}()
+			// Failovers which failed are asked for again by the next
+			// failed check.
+			delete(m.failovers, taskId)
+		}
+	}
+	m.mu.Unlock()
+
+	if recovered {
+		m.HealthCheckRecoveredHandler(appId, taskId)
+	}
+}
+
+// recovered forgets the deferred failover of task taskId once its health
+// is not unhealthy any more and reports whether it did. m.mu must be held.
+func (m *HealthCheckManager) recovered(taskId, health string) bool {
+	if deferred := m.failovers[taskId]; !deferred || health == "unhealthy" {
+		return false
+	}
+
+	delete(m.failovers, taskId)
+
+	return true
+}
+
+// ready records the result of the readiness check e, which is removed once
+// it passed, and reports whether all readiness checks of its task passed.
+// m.mu must be held.
+func (m *HealthCheckManager) ready(e *entry, result Result) bool {
+	now := time.Now()
+
+	if result.Healthy && !result.Ignored {
+		m.remove(e)
+		for _, other := range m.tasks[e.check.TaskID] {
+			if other.check.Readiness {
+				return false
 			}
 		}
+
+		return true
+	}
+
+	if !result.Ignored {
+		logrus.Debugf("Readiness check %s of task %s failed: %s", e.check.ID, e.check.TaskID, result.Message)
+		e.lastFailure = now.Unix()
+		e.lastError = result.Message
 	}
+
+	e.next = now.Add(jitter(e.interval))
+	heap.Push(&m.queue, e)
+
+	return false
 }
 
-func (m *HealthCheckManager) Stop() {
-	close(m.quit)
+// taskHealth aggregates the results of the checks of task taskId, m.mu
+// must be held.
+func (m *HealthCheckManager) taskHealth(taskId string) string {
+	health := "healthy"
+	for _, e := range m.tasks[taskId] {
+		if e.check.Readiness {
+			continue
+		}
 
-	for _, checker := range m.checkers {
-		checker.Stop()
+		switch e.result {
+		case "unhealthy":
+			return "unhealthy"
+		case "unknown":
+			health = "unknown"
+		}
 	}
+
+	return health
 }
 
+// TaskHealth returns the health of task taskId and the state of its health
+// checks, nil if the task has none.
+func (m *HealthCheckManager) TaskHealth(taskId string) *types.TaskHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health := &types.TaskHealth{
+		TaskID: taskId,
+		Health: m.taskHealth(taskId),
+	}
+	for _, e := range m.tasks[taskId] {
+		if !e.check.Readiness {
+			health.Checks = append(health.Checks, e.status())
+		}
+	}
+
+	if len(health.Checks) == 0 {
+		return nil
+	}
+	sort.Slice(health.Checks, func(i, j int) bool {
+		return health.Checks[i].ID < health.Checks[j].ID
+	})
+
+	return health
+}
+
+// Add registers check, replacing the check of the same task if any. The
+// first run is at a random point of the first interval, so checks added
+// together spread out.
 func (m *HealthCheckManager) Add(check *types.Check) {
-	switch check.Protocol {
-	case "http", "HTTP":
+	checker := m.newChecker(check)
+	if checker == nil {
+		return
+	}
+
+	m.add(newEntry(check, checker))
+}
+
+func (m *HealthCheckManager) add(e *entry) {
+	e.next = time.Now().Add(time.Duration(rand.Int63n(int64(e.interval))))
+
+	m.mu.Lock()
+	if old, ok := m.entries[e.check.ID]; ok {
+		m.remove(old)
+	}
+	m.entries[e.check.ID] = e
+
+	checks, ok := m.tasks[e.check.TaskID]
+	if !ok {
+		checks = make(map[string]*entry)
+		m.tasks[e.check.TaskID] = checks
+	}
+	checks[e.check.ID] = e
+
+	heap.Push(&m.queue, e)
+	m.mu.Unlock()
+
+	m.signal()
+}
+
+// newChecker returns the checker of the protocol of check, nil if check
+// cannot be run.
+func (m *HealthCheckManager) newChecker(check *types.Check) Checker {
+	switch strings.ToLower(check.Protocol) {
+	case "http", "https":
+		url := checkURL(check)
 		logrus.Infof("Register health check for task %s protocol %s url %s",
 			check.TaskID,
-			"http",
-			fmt.Sprintf("http://%s:%d", check.Address, check.Port),
+			strings.ToLower(check.Protocol),
+			url,
 		)
-		checker := &HTTPChecker{
-			ID:          check.TaskID,
-			Url:         fmt.Sprintf("http://%s:%d", check.Address, check.Port),
-			Interval:    check.Interval,
-			Timeout:     check.Timeout,
-			MaxFailures: check.MaxFailures,
-			FailedHandler: func(appId, taskId string) error {
-				return m.HealthCheckFailedHandler(appId, taskId)
-			},
-			AppID:  check.AppID,
-			TaskID: check.TaskID,
-			quit:   make(chan struct{}),
-		}
-		m.taskQueue.Add(checker)
-		m.checkers[check.TaskID] = checker
-	case "tcp", "TCP":
+
+		ranges, err := parseStatusRanges(check.StatusCodes)
+		if err != nil {
+			logrus.Errorf("Health check for task %s accepts the default status codes: %s", check.TaskID, err.Error())
+			ranges = defaultStatusRanges
+		}
+
+		checker := NewHTTPChecker(url)
+		checker.Method = check.Method
+		checker.Headers = check.Headers
+		checker.StatusRanges = ranges
+		checker.ExpectedBody = check.ExpectedBody
+		checker.IgnoreHttp1xx = check.IgnoreHttp1xx
+
+		return checker
+	case "tcp":
 		logrus.Infof("Add health check for task %s protocol %s address %s port %d",
 			check.TaskID,
 			"tcp",
 			check.Address,
 			check.Port,
 		)
-		checker := &TCPChecker{
-			ID:          check.TaskID,
-			Addr:        fmt.Sprintf("%s:%d", check.Address, check.Port),
-			Interval:    check.Interval,
-			Timeout:     check.Timeout,
-			MaxFailures: check.MaxFailures,
-			FailedHandler: func(appId, taskId string) error {
-				return m.HealthCheckFailedHandler(appId, taskId)
-			},
-			AppID:  check.AppID,
-			TaskID: check.TaskID,
-			quit:   make(chan struct{}),
+
+		return NewTCPChecker(net.JoinHostPort(check.Address, strconv.Itoa(check.Port)))
+	case "command":
+		if check.Command == nil || check.Command.Value == "" {
+			logrus.Errorf("Skip command health check without command for task %s", check.TaskID)
+			return nil
+		}
+
+		logrus.Infof("Add health check for task %s protocol %s command %s",
+			check.TaskID,
+			"command",
+			check.Command.Value,
+		)
+
+		runner := m.CommandRunner
+		if runner == nil {
+			runner = LocalRunner{}
 		}
-		m.taskQueue.Add(checker)
-		m.checkers[check.TaskID] = checker
+
+		return NewCommandChecker(check, runner)
 	}
 
+	logrus.Errorf("Skip health check with unknown protocol %s for task %s", check.Protocol, check.TaskID)
+	return nil
 }
 
-func (m *HealthCheckManager) Next() (item interface{}) {
-	return m.taskQueue.Next()
+// checkURL returns the url requested by an HTTP or HTTPS check.
+func checkURL(check *types.Check) string {
+	scheme := strings.ToLower(check.Protocol)
+
+	path := check.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(check.Address, strconv.Itoa(check.Port)), path)
 }
 
+// remove unregisters e, m.mu must be held. A run in progress is discarded.
+func (m *HealthCheckManager) remove(e *entry) {
+	e.removed = true
+	if e.index >= 0 {
+		heap.Remove(&m.queue, e.index)
+	}
+
+	if m.entries[e.check.ID] == e {
+		delete(m.entries, e.check.ID)
+	}
+
+	if checks := m.tasks[e.check.TaskID]; checks[e.check.ID] == e {
+		delete(checks, e.check.ID)
+		if len(checks) == 0 {
+			delete(m.tasks, e.check.TaskID)
+		}
+	}
+}
+
+// StopCheck removes the checks of task id.
 func (m *HealthCheckManager) StopCheck(id string) {
-	if checker, exist := m.checkers[id]; exist {
-		logrus.Infof("Remove health check for task %s", id)
-		checker.Stop()
-		delete(m.checkers, id)
+	m.mu.Lock()
+	if checks, exist := m.tasks[id]; exist {
+		logrus.Infof("Remove health checks for task %s", id)
+		for _, e := range checks {
+			m.remove(e)
+		}
 	}
+	delete(m.failovers, id)
+	m.mu.Unlock()
+
+	m.resultsMu.Lock()
+	delete(m.results, id)
+	m.resultsMu.Unlock()
 }
 
+// HasCheck reports whether task id has checks.
 func (m *HealthCheckManager) HasCheck(id string) bool {
-	_, exists := m.checkers[id]
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, exists := m.tasks[id]
 
 	return exists
 }
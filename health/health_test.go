@@ -1,7 +1,13 @@
 package health
 
 import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
 	"github.com/Dataman-Cloud/swan/health/mock"
+	"github.com/Dataman-Cloud/swan/store/memory"
 	"github.com/Dataman-Cloud/swan/types"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -48,9 +54,229 @@ func TestHealthCHeckManagerAdd(t *testing.T) {
 	m.Add(&tcpCheck)
 }
 
-func TestHealthCheckNext(t *testing.T) {
+// fakeChecker returns the results in turn, the last one over and over.
+type fakeChecker struct {
+	mu      sync.Mutex
+	results []Result
+	runs    int
+	delay   time.Duration
+}
+
+func (c *fakeChecker) Check(ctx context.Context) Result {
+	if c.delay != 0 {
+		time.Sleep(c.delay)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.runs++
+	if len(c.results) > 1 {
+		result := c.results[0]
+		c.results = c.results[1:]
+		return result
+	}
+	return c.results[0]
+}
+
+func (c *fakeChecker) Protocol() string {
+	return "fake"
+}
+
+func (c *fakeChecker) Runs() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.runs
+}
+
+func fakeEntry(taskId string, checker Checker, maxFailures int) *entry {
+	e := newEntry(&types.Check{ID: taskId + "-0", TaskID: taskId, AppID: "app", MaxFailures: maxFailures}, checker)
+	e.interval = 5 * time.Millisecond
+	return e
+}
+
+func TestHealthCheckFailover(t *testing.T) {
+	queue := make(chan types.ReschedulerMsg)
+	m := NewHealthCheckManager(&mock.Store{}, queue)
+	go m.Start()
+	defer m.Stop()
+
+	checker := &fakeChecker{results: []Result{{Healthy: false, Message: "down"}}}
+	m.add(fakeEntry("xxx", checker, 3))
+
+	select {
+	case msg := <-queue:
+		assert.Equal(t, "app", msg.AppID)
+		assert.Equal(t, "xxx", msg.TaskID)
+		assert.False(t, msg.Recovered)
+		msg.Err <- types.ErrFailoverDeferred
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rescheduling of failed task")
+	}
+
+	// A task whose failover is deferred is still checked, its failover is
+	// not asked for again.
+	runs := checker.Runs()
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, checker.Runs() > runs)
+	select {
+	case msg := <-queue:
+		t.Fatalf("unexpected message for task %s", msg.TaskID)
+	default:
+	}
+
+	// The rescheduler stops the checks once the task is replaced.
+	m.StopCheck("xxx")
+	assert.False(t, m.HasCheck("xxx"))
+}
+
+func TestHealthCheckFailoverRecovered(t *testing.T) {
+	queue := make(chan types.ReschedulerMsg)
+	m := NewHealthCheckManager(&mock.Store{}, queue)
+	go m.Start()
+	defer m.Stop()
+
+	checker := &fakeChecker{results: []Result{{Healthy: false}, {Healthy: false}, {Healthy: false}, {Healthy: true}}}
+	m.add(fakeEntry("xxx", checker, 3))
+
+	msg := <-queue
+	msg.Err <- types.ErrFailoverDeferred
+
+	// The deferred failover is dropped once the task is healthy again.
+	select {
+	case msg := <-queue:
+		assert.Equal(t, "app", msg.AppID)
+		assert.Equal(t, "xxx", msg.TaskID)
+		assert.True(t, msg.Recovered)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no recovery of deferred task")
+	}
+}
+
+func TestHealthCheckConsecutiveFailures(t *testing.T) {
+	queue := make(chan types.ReschedulerMsg, 1)
+	m := NewHealthCheckManager(&mock.Store{}, queue)
+	go m.Start()
+	defer m.Stop()
+
+	checker := &fakeChecker{results: []Result{
+		{Healthy: false}, {Healthy: true},
+		{Healthy: false}, {Healthy: true},
+		{Healthy: false}, {Healthy: true},
+	}}
+	m.add(fakeEntry("xxx", checker, 2))
+
+	for checker.Runs() < 10 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Len(t, queue, 0)
+}
+
+func TestHealthCheckGracePeriod(t *testing.T) {
+	queue := make(chan types.ReschedulerMsg, 1)
+	m := NewHealthCheckManager(&mock.Store{}, queue)
+	go m.Start()
+	defer m.Stop()
+
+	checker := &fakeChecker{results: []Result{{Healthy: false}}}
+	e := newEntry(&types.Check{ID: "xxx-0", TaskID: "xxx", MaxFailures: 1, GracePeriod: 60, Launched: time.Now().Unix()}, checker)
+	e.interval = 5 * time.Millisecond
+	m.add(e)
+
+	for checker.Runs() < 5 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Len(t, queue, 0)
+}
+
+func TestHealthCheckWorkers(t *testing.T) {
+	m := NewHealthCheckManager(&mock.Store{}, make(chan types.ReschedulerMsg))
+	m.Workers = 4
+
+	var running, max int32
+	checkers := make([]*countingChecker, 20)
+	for i := range checkers {
+		checkers[i] = &countingChecker{running: &running, max: &max}
+		m.add(fakeEntry(strconv.Itoa(i), checkers[i], 3))
+	}
+
+	go m.Start()
+	defer m.Stop()
+
+	for _, checker := range checkers {
+		for atomic.LoadInt32(&checker.runs) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	assert.True(t, atomic.LoadInt32(&max) <= 4)
+}
+
+// countingChecker records the most checks running at the same time.
+type countingChecker struct {
+	running *int32
+	max     *int32
+	runs    int32
+}
+
+func (c *countingChecker) Check(ctx context.Context) Result {
+	n := atomic.AddInt32(c.running, 1)
+	for {
+		max := atomic.LoadInt32(c.max)
+		if n <= max || atomic.CompareAndSwapInt32(c.max, max, n) {
+			break
+		}
+	}
+	time.Sleep(2 * time.Millisecond)
+	atomic.AddInt32(c.running, -1)
+	atomic.AddInt32(&c.runs, 1)
+	return Result{Healthy: true}
+}
+
+func (c *countingChecker) Protocol() string {
+	return "fake"
+}
+
+func TestHealthCheckManyChecks(t *testing.T) {
 	m := NewHealthCheckManager(&mock.Store{}, make(chan types.ReschedulerMsg))
-	assert.Nil(t, m.Next())
+	go m.Start()
+	defer m.Stop()
+
+	checkers := make([]*fakeChecker, 5000)
+	var wg sync.WaitGroup
+	for i := range checkers {
+		checkers[i] = &fakeChecker{results: []Result{{Healthy: true}}}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e := fakeEntry(strconv.Itoa(i), checkers[i], 3)
+			e.interval = 100 * time.Millisecond
+			m.add(e)
+		}(i)
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for _, checker := range checkers {
+		for checker.Runs() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("checks did not run")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for i := range checkers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.StopCheck(strconv.Itoa(i))
+		}(i)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	assert.Len(t, m.entries, 0)
+	assert.Len(t, m.queue, 0)
+	m.mu.Unlock()
 }
 
 func TestHealthCheckStopCheck(t *testing.T) {
@@ -74,3 +300,136 @@ func TestHasCheck(t *testing.T) {
 	m := NewHealthCheckManager(&mock.Store{}, make(chan types.ReschedulerMsg))
 	assert.Equal(t, m.HasCheck("xxxxxxx"), false)
 }
+
+func TestHealthCheckMultipleChecks(t *testing.T) {
+	queue := make(chan types.ReschedulerMsg, 1)
+	m := NewHealthCheckManager(&mock.Store{}, queue)
+
+	healthy := &fakeChecker{results: []Result{{Healthy: true}}}
+	m.add(fakeEntry("xxx", healthy, 3))
+
+	failing := &fakeChecker{results: []Result{{Healthy: true}, {Healthy: true}, {Healthy: false, Message: "connection refused"}}}
+	e := newEntry(&types.Check{ID: "xxx-1", TaskID: "xxx", AppID: "app", MaxFailures: 2}, failing)
+	e.interval = 5 * time.Millisecond
+	m.add(e)
+
+	assert.True(t, m.HasCheck("xxx"))
+	health := m.TaskHealth("xxx")
+	assert.Equal(t, "unknown", health.Health)
+	assert.Len(t, health.Checks, 2)
+	assert.Equal(t, "unknown", health.Checks[0].Result)
+
+	go m.Start()
+	defer m.Stop()
+
+	select {
+	case msg := <-queue:
+		assert.Equal(t, "xxx", msg.TaskID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rescheduling of failed task")
+	}
+
+	// One failed check fails the task, its other checks keep running.
+	health = m.TaskHealth("xxx")
+	assert.Equal(t, "unhealthy", health.Health)
+	assert.Equal(t, "xxx-0", health.Checks[0].ID)
+	assert.Equal(t, "healthy", health.Checks[0].Result)
+	assert.NotZero(t, health.Checks[0].LastSuccess)
+	assert.Equal(t, "unhealthy", health.Checks[1].Result)
+	assert.True(t, health.Checks[1].ConsecutiveFailures >= 2)
+	assert.Equal(t, "connection refused", health.Checks[1].LastError)
+	assert.NotZero(t, health.Checks[1].LastFailure)
+
+	runs := healthy.Runs()
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, healthy.Runs() > runs)
+
+	m.StopCheck("xxx")
+	assert.False(t, m.HasCheck("xxx"))
+	assert.Nil(t, m.TaskHealth("xxx"))
+}
+
+func TestHealthCheckReadiness(t *testing.T) {
+	store := memory.NewMemoryStore()
+	store.SaveApplication(&types.Application{ID: "app"})
+	store.SaveTask(&types.Task{ID: "1-xxx", Name: "xxx", AppId: "app", ReadinessChecks: []*types.ReadinessCheck{{Path: "/ready"}}})
+
+	queue := make(chan types.ReschedulerMsg, 1)
+	m := NewHealthCheckManager(store, queue)
+
+	m.add(fakeEntry("xxx", &fakeChecker{results: []Result{{Healthy: true}}}, 3))
+
+	readiness := &fakeChecker{results: []Result{{Healthy: false}, {Healthy: false}, {Healthy: false}, {Healthy: true}}}
+	e := newEntry(&types.Check{ID: "xxx-ready-0", TaskID: "xxx", AppID: "app", MaxFailures: 1, Readiness: true}, readiness)
+	e.interval = 5 * time.Millisecond
+	m.add(e)
+
+	go m.Start()
+	defer m.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		task, _ := store.FetchTask("xxx")
+		if task.Ready {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("task did not become ready")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Failed readiness checks neither fail over nor count towards health,
+	// and they stop once they passed.
+	assert.Len(t, queue, 0)
+	assert.Equal(t, 4, readiness.Runs())
+	assert.Len(t, m.TaskHealth("xxx").Checks, 1)
+	assert.True(t, m.HasCheck("xxx"))
+}
+
+func TestHealthCheckSaveStatuses(t *testing.T) {
+	store := memory.NewMemoryStore()
+	store.SaveApplication(&types.Application{ID: "app"})
+
+	hostname := "x.x.x.x"
+	task := &types.Task{
+		ID:            "1500000000000000000-xxx",
+		Name:          "xxx",
+		AppId:         "app",
+		AgentHostname: &hostname,
+		Ports:         []uint32{8080},
+		HealthChecks:  []*types.HealthCheck{{Protocol: "tcp"}},
+	}
+	store.SaveTask(task)
+	store.SaveCheck(task)
+
+	checks, _ := store.ListChecks()
+	failing := &fakeChecker{results: []Result{{Healthy: false, Message: "connection refused"}}}
+
+	m := NewHealthCheckManager(store, nil)
+	e := newEntry(checks[0], failing)
+	m.add(e)
+	m.run(e)
+	m.run(e)
+	assert.Nil(t, m.SaveStatuses())
+
+	checks, _ = store.ListChecks()
+	assert.Equal(t, "unhealthy", checks[0].Status.Result)
+	assert.Equal(t, 2, checks[0].Status.ConsecutiveFailures)
+	assert.Equal(t, "connection refused", checks[0].Status.LastError)
+
+	// A restored check resumes counting where it stopped.
+	queue := make(chan types.ReschedulerMsg, 1)
+	m = NewHealthCheckManager(store, queue)
+	e = newEntry(checks[0], failing)
+	m.add(e)
+	assert.Equal(t, "unhealthy", e.result)
+	m.run(e)
+
+	select {
+	case msg := <-queue:
+		assert.Equal(t, "xxx", msg.TaskID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rescheduling of failed task")
+	}
+}
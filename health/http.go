@@ -1,96 +1,231 @@
 package health
 
 import (
-	"github.com/Sirupsen/logrus"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// maxBodySize is the part of a response searched for the expected body.
+const maxBodySize = 64 * 1024
+
+// errInformational stops a request at its first informational response.
+var errInformational = errors.New("Informational response")
+
+var (
+	// The transports keep connections to tasks open between checks. Tasks
+	// serve HTTPS with certificates swan cannot verify, so they are not.
+	httpTransport = &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	httpsTransport = &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     90 * time.Second,
+	}
+)
+
+// defaultStatusRanges are the status codes accepted if a check sets none.
+var defaultStatusRanges = []statusRange{{200, 399}}
+
+// statusRange is an inclusive range of accepted status codes.
+type statusRange struct {
+	min, max int
+}
+
+// parseStatusRanges parses status codes like "200" and ranges like
+// "200-399".
+func parseStatusRanges(codes []string) ([]statusRange, error) {
+	if len(codes) == 0 {
+		return defaultStatusRanges, nil
+	}
+
+	var ranges []statusRange
+	for _, code := range codes {
+		bounds := strings.SplitN(strings.TrimSpace(code), "-", 2)
+
+		min, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid status code %s", code)
+		}
+
+		max := min
+		if len(bounds) == 2 {
+			if max, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				return nil, fmt.Errorf("Invalid status code %s", code)
+			}
+		}
+
+		if min < 100 || max > 599 || min > max {
+			return nil, fmt.Errorf("Invalid status code %s", code)
+		}
+
+		ranges = append(ranges, statusRange{min, max})
+	}
+
+	return ranges, nil
+}
+
+// HTTPChecker checks the response of a task to a request of Url.
 type HTTPChecker struct {
-	ID          string
-	Url         string
-	Interval    int
-	Timeout     int
-	MaxFailures int
+	Url string
 
-	FailedHandler HandlerFunc
+	// Method defaults to GET, StatusRanges to 200-399.
+	Method        string
+	Headers       map[string]string
+	StatusRanges  []statusRange
+	ExpectedBody  string
+	IgnoreHttp1xx bool
 
-	AppID  string
-	TaskID string
+	client *http.Client
+}
 
-	quit chan struct{}
+func NewHTTPChecker(url string) *HTTPChecker {
+	c := &HTTPChecker{
+		Url: url,
+	}
+
+	transport := httpTransport
+	if c.Protocol() == "https" {
+		transport = httpsTransport
+	}
+
+	c.client = &http.Client{
+		Transport: transport,
+		// Redirects are judged by the accepted status codes.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return c
 }
 
-func NewHTTPChecker(id, url string, interval, timeout, failures int, handler HandlerFunc, appId, taskId string) *HTTPChecker {
-	return &HTTPChecker{
-		ID:            id,
-		Url:           url,
-		Interval:      interval,
-		Timeout:       timeout,
-		MaxFailures:   failures,
-		FailedHandler: handler,
-		AppID:         appId,
-		TaskID:        taskId,
-		quit:          make(chan struct{}),
+// Protocol is the scheme of the url of the check.
+func (c *HTTPChecker) Protocol() string {
+	if strings.HasPrefix(c.Url, "https://") {
+		return "https"
 	}
+
+	return "http"
 }
 
-func (c *HTTPChecker) Start() {
-	ticker := time.NewTicker(time.Duration(c.Interval) * time.Second)
+// Check sends one request and judges the response.
+func (c *HTTPChecker) Check(ctx context.Context) Result {
+	method := c.Method
+	if method == "" {
+		method = "GET"
+	}
 
-	maxFailures := 0
+	req, err := http.NewRequest(method, c.Url, nil)
+	if err != nil {
+		return Result{Message: err.Error()}
+	}
 
-	for {
+	// The client passes over informational responses on its way to the
+	// final one, the check is judged by the first of them instead.
+	informational := 0
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			informational = code
+			return errInformational
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
 
-		if maxFailures >= c.MaxFailures {
-			c.FailedHandler(c.AppID, c.TaskID)
-			return
+	for name, value := range c.Headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
 		}
+		req.Header.Set(name, value)
+	}
 
-		select {
-		case <-ticker.C:
-			transport := http.Transport{
-				Dial: func(network, addr string) (net.Conn, error) {
-					return net.DialTimeout(network, addr, time.Duration(c.Timeout)*time.Second)
-				},
-			}
+	resp, err := c.client.Do(req)
+	if informational != 0 {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return c.informational(informational)
+	}
+	if err != nil {
+		return Result{Message: err.Error()}
+	}
+	defer resp.Body.Close()
 
-			client := http.Client{
-				Transport: &transport,
-			}
+	result := c.evaluate(resp)
 
-			resp, err := client.Head(c.Url)
-			if err != nil {
-				logrus.WithFields(logrus.Fields{"protocol": "http",
-					"url":      c.Url,
-					"interval": c.Interval,
-					"timeout":  c.Timeout},
-				).Error("[FAILED] check service")
-				maxFailures += 1
-				break
-			}
+	// Drain the body so the connection is reused.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxBodySize))
 
-			if resp.StatusCode != 200 {
-				logrus.WithFields(logrus.Fields{"protocol": "http",
-					"url":      c.Url,
-					"interval": c.Interval,
-					"timeout":  c.Timeout},
-				).Error("[FAILED] check service")
-				maxFailures += 1
-				break
-			}
-			logrus.WithFields(logrus.Fields{"protocol": "http",
-				"url":      c.Url,
-				"interval": c.Interval,
-				"timeout":  c.Timeout},
-			).Info("[OK] check service")
-		case <-c.quit:
-			return
-		}
+	return result
+}
+
+// informational judges an informational response with status code code,
+// a failure unless they are ignored.
+func (c *HTTPChecker) informational(code int) Result {
+	if c.IgnoreHttp1xx {
+		return Result{Ignored: true, Message: fmt.Sprintf("Status code %d", code)}
 	}
+
+	return Result{Message: fmt.Sprintf("Unexpected status code %d", code)}
 }
 
-func (c *HTTPChecker) Stop() {
-	close(c.quit)
+// evaluate judges resp by its status code and body.
+func (c *HTTPChecker) evaluate(resp *http.Response) Result {
+	if resp.StatusCode < 200 && c.IgnoreHttp1xx {
+		return c.informational(resp.StatusCode)
+	}
+
+	ranges := c.StatusRanges
+	if len(ranges) == 0 {
+		ranges = defaultStatusRanges
+	}
+
+	accepted := false
+	for _, r := range ranges {
+		if resp.StatusCode >= r.min && resp.StatusCode <= r.max {
+			accepted = true
+			break
+		}
+	}
+
+	if !accepted {
+		return Result{Message: fmt.Sprintf("Unexpected status code %d", resp.StatusCode)}
+	}
+
+	if c.ExpectedBody == "" {
+		return Result{Healthy: true}
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return Result{Message: fmt.Sprintf("Read body failed: %s", err.Error())}
+	}
+
+	if !strings.Contains(string(body), c.ExpectedBody) {
+		return Result{Message: fmt.Sprintf("Body does not contain %q", c.ExpectedBody)}
+	}
+
+	return Result{Healthy: true}
 }
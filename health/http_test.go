@@ -1,11 +1,20 @@
 package health
 
 import (
+	"context"
 	"fmt"
+	"github.com/Dataman-Cloud/swan/types"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var (
@@ -26,15 +35,199 @@ func startHttpServer() *httptest.Server {
 }
 
 func TestNewHTTPChecker(t *testing.T) {
-	checker := NewHTTPChecker("xxxxx", "x.x.x.x:yyyy", 3, 5, 5, nil, "xxx", "yyy")
-	assert.Equal(t, checker.ID, "xxxxx")
+	checker := NewHTTPChecker("http://x.x.x.x:yyyy")
+	assert.Equal(t, checker.Url, "http://x.x.x.x:yyyy")
+	assert.Equal(t, "http", checker.Protocol())
 }
 
-func TestHTTPCheckerStart(t *testing.T) {
-	handler := func(appId, taskId string) error {
-		fmt.Println(appId, taskId)
-		return nil
+func TestHTTPCheckerCheck(t *testing.T) {
+	// The default mux of the test server has no handler for /.
+	result := NewHTTPChecker(baseUrl).Check(context.Background())
+	assert.False(t, result.Healthy)
+	assert.Equal(t, "Unexpected status code 404", result.Message)
+}
+
+func TestHTTPCheckerTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	result := NewHTTPChecker(srv.URL).Check(ctx)
+	assert.False(t, result.Healthy)
+}
+
+func newTestChecker(url string) *HTTPChecker {
+	checker := NewHTTPChecker(url)
+	checker.StatusRanges = defaultStatusRanges
+	return checker
+}
+
+func TestHTTPCheckerRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/health", r.URL.Path)
+		assert.Equal(t, "probe", r.Header.Get("X-Probe"))
+		assert.Equal(t, "app.example.com", r.Host)
+	}))
+	defer srv.Close()
+
+	checker := newTestChecker(srv.URL + "/health")
+	checker.Method = "POST"
+	checker.Headers = map[string]string{"X-Probe": "probe", "Host": "app.example.com"}
+
+	result := checker.Check(context.Background())
+	assert.True(t, result.Healthy)
+	assert.False(t, result.Ignored)
+	assert.Equal(t, "", result.Message)
+}
+
+func TestHTTPCheckerStatusRanges(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(r.URL.Query().Get("code"))
+		if code == http.StatusMovedPermanently {
+			http.Redirect(w, r, "/nowhere", code)
+			return
+		}
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		codes   []string
+		code    int
+		healthy bool
+	}{
+		{nil, 200, true},
+		{nil, 204, true},
+		{nil, 301, true},
+		{nil, 404, false},
+		{nil, 500, false},
+		{[]string{"200"}, 204, false},
+		{[]string{"200", "500-503"}, 503, true},
+		{[]string{"200", "500-503"}, 504, false},
+	} {
+		checker := newTestChecker(fmt.Sprintf("%s/?code=%d", srv.URL, c.code))
+		ranges, err := parseStatusRanges(c.codes)
+		assert.Nil(t, err)
+		checker.StatusRanges = ranges
+
+		result := checker.Check(context.Background())
+		assert.Equal(t, c.healthy, result.Healthy, "codes %v status %d: %s", c.codes, c.code, result.Message)
 	}
-	checker := NewHTTPChecker("xxxxx", baseUrl, 1, 2, 2, handler, "xxx", "yyy")
-	checker.Start()
+}
+
+func TestHTTPCheckerExpectedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "UP"}`))
+	}))
+	defer srv.Close()
+
+	checker := newTestChecker(srv.URL)
+	checker.ExpectedBody = `"status": "UP"`
+
+	assert.True(t, checker.Check(context.Background()).Healthy)
+
+	checker.ExpectedBody = `"status": "DOWN"`
+
+	result := checker.Check(context.Background())
+	assert.False(t, result.Healthy)
+	assert.Contains(t, result.Message, "DOWN")
+}
+
+func TestHTTPCheckerIgnoreHttp1xx(t *testing.T) {
+	checker := newTestChecker("http://x.x.x.x")
+	resp := &http.Response{StatusCode: http.StatusSwitchingProtocols, Body: ioutil.NopCloser(strings.NewReader(""))}
+
+	result := checker.evaluate(resp)
+	assert.False(t, result.Healthy)
+	assert.False(t, result.Ignored)
+
+	checker.IgnoreHttp1xx = true
+
+	assert.True(t, checker.evaluate(resp).Ignored)
+}
+
+func TestHTTPCheckerInformationalResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	checker := newTestChecker(srv.URL)
+
+	result := checker.Check(context.Background())
+	assert.False(t, result.Healthy)
+	assert.False(t, result.Ignored)
+	assert.Equal(t, "Unexpected status code 103", result.Message)
+
+	checker.IgnoreHttp1xx = true
+
+	result = checker.Check(context.Background())
+	assert.True(t, result.Ignored)
+	assert.Equal(t, "Status code 103", result.Message)
+}
+
+func TestHTTPSChecker(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	checker := newTestChecker(srv.URL)
+	assert.Equal(t, "https", checker.Protocol())
+
+	result := checker.Check(context.Background())
+	assert.True(t, result.Healthy, result.Message)
+}
+
+func TestHTTPCheckerReusesConnections(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		conns int
+	)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	checker := newTestChecker(srv.URL)
+	for i := 0; i < 3; i++ {
+		result := checker.Check(context.Background())
+		assert.True(t, result.Healthy, result.Message)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, conns)
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	ranges, err := parseStatusRanges([]string{"200", " 300 - 399 "})
+	assert.Nil(t, err)
+	assert.Equal(t, []statusRange{{200, 200}, {300, 399}}, ranges)
+
+	for _, codes := range [][]string{{"ok"}, {"200-"}, {"399-200"}, {"99"}, {"200-600"}} {
+		_, err := parseStatusRanges(codes)
+		assert.NotNil(t, err, "%v", codes)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	check := &types.Check{Protocol: "HTTPS", Address: "x.x.x.x", Port: 8443, Path: "health"}
+	assert.Equal(t, "https://x.x.x.x:8443/health", checkURL(check))
+
+	check = &types.Check{Protocol: "http", Address: "x.x.x.x", Port: 80}
+	assert.Equal(t, "http://x.x.x.x:80/", checkURL(check))
 }
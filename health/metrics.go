@@ -0,0 +1,25 @@
+package health
+
+import (
+	"time"
+
+	"github.com/Dataman-Cloud/swan/metrics"
+)
+
+var (
+	checkResults = metrics.NewCounter("swan_health_checks_total",
+		"Health checks run, by protocol and result.", "protocol", "result")
+	checkDuration = metrics.NewHistogram("swan_health_check_duration_seconds",
+		"Latency of health checks, by protocol.", metrics.DefBuckets, "protocol")
+)
+
+// observe records a check of protocol started at start.
+func observe(protocol string, start time.Time, healthy bool) {
+	checkDuration.Since(start, protocol)
+
+	if healthy {
+		checkResults.Inc(protocol, "healthy")
+	} else {
+		checkResults.Inc(protocol, "unhealthy")
+	}
+}
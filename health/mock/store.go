@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
 )
 
@@ -10,3 +11,7 @@ type Store struct {
 func (s *Store) ListChecks() ([]*types.Check, error) {
 	return nil, nil
 }
+
+func (s *Store) Update(fn func(tx store.Tx) error) error {
+	return nil
+}
@@ -0,0 +1,132 @@
+package health
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+const (
+	// Defaults of checks which leave the settings out, those of mesos.
+	defaultInterval    = 10 * time.Second
+	defaultTimeout     = 20 * time.Second
+	defaultMaxFailures = 3
+
+	// jitterFraction of the interval is randomly added to or taken from
+	// every interval, so checks registered together drift apart.
+	jitterFraction = 0.1
+)
+
+// entry is a registered check with its schedule and failure count, it is
+// guarded by the mutex of the manager.
+type entry struct {
+	check   *types.Check
+	checker Checker
+
+	interval    time.Duration
+	timeout     time.Duration
+	maxFailures int
+	failures    *failureCounter
+
+	next time.Time
+
+	// index is the position in the queue, -1 while the check runs or
+	// once it is removed.
+	index   int
+	removed bool
+
+	// result is "healthy", "unhealthy" or "unknown", the times unix
+	// seconds.
+	result      string
+	lastSuccess int64
+	lastFailure int64
+	lastError   string
+
+	// dirty tells the status changed since it was last saved.
+	dirty bool
+}
+
+func newEntry(check *types.Check, checker Checker) *entry {
+	e := &entry{
+		check:       check,
+		checker:     checker,
+		interval:    time.Duration(check.Interval) * time.Second,
+		timeout:     time.Duration(check.Timeout) * time.Second,
+		maxFailures: check.MaxFailures,
+		failures:    newFailureCounter(check.Launched, check.GracePeriod),
+		index:       -1,
+		result:      "unknown",
+	}
+
+	if e.interval <= 0 {
+		e.interval = defaultInterval
+	}
+
+	if e.timeout <= 0 {
+		e.timeout = defaultTimeout
+	}
+
+	if e.maxFailures <= 0 {
+		e.maxFailures = defaultMaxFailures
+	}
+
+	// A check saved with its status resumes from it, a task about to be
+	// replaced is not given a fresh count.
+	if status := check.Status; status != nil {
+		e.result = status.Result
+		e.lastSuccess = status.LastSuccess
+		e.lastFailure = status.LastFailure
+		e.lastError = status.LastError
+		e.failures.count = status.ConsecutiveFailures
+		e.failures.succeeded = status.LastSuccess != 0
+	}
+
+	return e
+}
+
+// status returns the state of e, m.mu must be held.
+func (e *entry) status() *types.CheckStatus {
+	return &types.CheckStatus{
+		ID:                  e.check.ID,
+		Protocol:            e.checker.Protocol(),
+		Result:              e.result,
+		LastSuccess:         e.lastSuccess,
+		LastFailure:         e.lastFailure,
+		ConsecutiveFailures: e.failures.count,
+		LastError:           e.lastError,
+	}
+}
+
+// jitter returns d randomly shortened or lengthened by jitterFraction.
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration((rand.Float64()*2-1)*jitterFraction*float64(d))
+}
+
+// entryHeap orders entries by their next run, it implements heap.Interface.
+type entryHeap []*entry
+
+func (h entryHeap) Len() int           { return len(h) }
+func (h entryHeap) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+
+func (h entryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *entryHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
@@ -1,9 +1,11 @@
 package health
 
 import (
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
 )
 
 type Store interface {
 	ListChecks() ([]*types.Check, error)
+	Update(func(tx store.Tx) error) error
 }
@@ -1,21 +1,29 @@
 package health
 
 import (
-	"github.com/stretchr/testify/assert"
+	"context"
+	"net"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNewTCPChecker(t *testing.T) {
-	checker := NewTCPChecker("xxxxx", "x.x.x.x:yyyy", 8080, 3, 5, 5, nil, "xxx", "yyy")
-	assert.Equal(t, checker.ID, "xxxxx")
+	checker := NewTCPChecker("x.x.x.x:yyyy")
+	assert.Equal(t, checker.Addr, "x.x.x.x:yyyy")
+	assert.Equal(t, "tcp", checker.Protocol())
 }
 
-func TestTCPCheckerStart(t *testing.T) {
-	checker := NewTCPChecker("xxxxx", "x.x.x.x:yyyy", 8080, 3, 5, 5, nil, "xxx", "yyy")
-	checker.Start()
-}
+func TestTCPCheckerCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	checker := NewTCPChecker(ln.Addr().String())
+	assert.True(t, checker.Check(context.Background()).Healthy)
+
+	ln.Close()
 
-func TestTCPCheckerStop(t *testing.T) {
-	checker := NewTCPChecker("xxxxx", "x.x.x.x:yyyy", 8080, 3, 5, 5, nil, "xxx", "yyy")
-	checker.Stop()
+	result := checker.Check(context.Background())
+	assert.False(t, result.Healthy)
+	assert.NotEqual(t, "", result.Message)
 }
@@ -5,16 +5,32 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/Dataman-Cloud/swan/api"
 	"github.com/Dataman-Cloud/swan/api/router"
+	"github.com/Dataman-Cloud/swan/api/router/admin"
 	"github.com/Dataman-Cloud/swan/api/router/application"
+	"github.com/Dataman-Cloud/swan/api/router/audit"
+	"github.com/Dataman-Cloud/swan/api/router/converge"
+	"github.com/Dataman-Cloud/swan/api/router/events"
+	"github.com/Dataman-Cloud/swan/api/router/orphan"
+	"github.com/Dataman-Cloud/swan/api/router/subscriptions"
 	"github.com/Dataman-Cloud/swan/backend"
+	"github.com/Dataman-Cloud/swan/event"
+	"github.com/Dataman-Cloud/swan/ha"
 	"github.com/Dataman-Cloud/swan/health"
 	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
 	"github.com/Dataman-Cloud/swan/scheduler"
+	"github.com/Dataman-Cloud/swan/store"
 	. "github.com/Dataman-Cloud/swan/store/local"
+	"github.com/Dataman-Cloud/swan/store/memory"
+	"github.com/Dataman-Cloud/swan/store/raft"
 	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Dataman-Cloud/swan/webhook"
 	"github.com/Sirupsen/logrus"
 	"github.com/andygrunwald/megos"
 	"github.com/golang/protobuf/proto"
@@ -25,6 +41,34 @@ var (
 	master string
 	user   string
 	debug  bool
+
+	dataDir     string
+	storeDriver string
+	raftAddr    string
+	raftPeers   string
+	restore     string
+
+	convergeInterval   time.Duration
+	convergeMaxActions int
+	convergeDryRun     bool
+
+	orphanInterval    time.Duration
+	orphanPolicy      string
+	orphanGracePeriod time.Duration
+
+	auditMaxAge     time.Duration
+	auditMaxEntries int
+
+	webhookMaxAttempts int
+	webhookBackoff     time.Duration
+
+	healthCheckWorkers      int
+	healthCheckSaveInterval time.Duration
+
+	failoverMaxPercent int
+	failoverWindow     time.Duration
+
+	eventBuffer int
 )
 
 func init() {
@@ -32,6 +76,26 @@ func init() {
 	flag.StringVar(&master, "master", "127.0.0.1:5050", "master address <ip:port>,<ip:port>...")
 	flag.StringVar(&user, "user", "root", "mesos user")
 	flag.BoolVar(&debug, "debug", false, "log level")
+	flag.StringVar(&dataDir, "data-dir", "/var/lib/swan", "directory of the swan database")
+	flag.StringVar(&storeDriver, "store", "local", "store driver <local|raft|memory>, raft replicates the store to all raft peers, memory keeps nothing across restarts")
+	flag.StringVar(&restore, "restore", "", "restore the local store from a backup file while swan is stopped, then exit")
+	flag.StringVar(&raftAddr, "raft-addr", "", "raft address <ip:port> of this instance in a cluster")
+	flag.StringVar(&raftPeers, "raft-peers", "", "cluster members <apiAddr>@<raftAddr>,..., empty to run standalone")
+	flag.DurationVar(&convergeInterval, "converge-interval", time.Minute, "interval between convergence passes, 0 to disable")
+	flag.IntVar(&convergeMaxActions, "converge-max-actions", 10, "max tasks launched or killed per convergence pass, 0 for no limit")
+	flag.BoolVar(&convergeDryRun, "converge-dry-run", false, "only log the actions of convergence passes")
+	flag.DurationVar(&orphanInterval, "orphan-interval", time.Minute, "interval between orphan task detections, 0 to disable")
+	flag.StringVar(&orphanPolicy, "orphan-policy", "report", "what to do with orphan tasks <report|kill>")
+	flag.DurationVar(&orphanGracePeriod, "orphan-grace-period", 5*time.Minute, "how long orphan tasks may run before they are killed")
+	flag.DurationVar(&auditMaxAge, "audit-max-age", 30*24*time.Hour, "how long audit log entries are kept, 0 to keep them regardless of age")
+	flag.IntVar(&auditMaxEntries, "audit-max-entries", 100000, "max entries kept in the audit log, 0 for no limit")
+	flag.IntVar(&webhookMaxAttempts, "webhook-max-attempts", 5, "posts of an event to a subscriber before it becomes a dead letter")
+	flag.DurationVar(&webhookBackoff, "webhook-backoff", time.Second, "wait before the first retry of a webhook post, doubled on every retry")
+	flag.IntVar(&healthCheckWorkers, "health-check-workers", 64, "max health checks run at the same time")
+	flag.DurationVar(&healthCheckSaveInterval, "health-check-save-interval", 30*time.Second, "interval between saves of the changed health check statuses")
+	flag.IntVar(&failoverMaxPercent, "failover-max-percent", 20, "max percentage of the instances of an application replaced per failover window after failed health checks, at least one")
+	flag.DurationVar(&failoverWindow, "failover-window", time.Minute, "window of the failover limit")
+	flag.IntVar(&eventBuffer, "event-buffer", 1000, "number of recent events kept for event stream clients which reconnect")
 
 	flag.Parse()
 }
@@ -64,21 +128,82 @@ func main() {
 
 	setupLogger()
 
-	store, err := NewBoltStore("/var/lib/swan/bolt.db")
-	if err != nil {
-		logrus.Errorf("Init store engine failed:%s", err)
+	if restore != "" {
+		// Only the local store reads the restored database.
+		if storeDriver != "local" {
+			logrus.Errorf("Restore requires store driver local, not %s", storeDriver)
+			os.Exit(1)
+		}
+
+		if err := restoreBackup(restore); err != nil {
+			logrus.Errorf("Restore backup %s failed: %s", restore, err)
+			os.Exit(1)
+		}
+
+		logrus.Infof("Restored backup %s", restore)
 		return
 	}
 
-	frameworkId, err := store.FetchFrameworkID()
-	if err != nil {
-		logrus.Errorf("Fetch framework id failed: %s", err)
+	var node *ha.Node
+	var db store.Store
+
+	switch {
+	case storeDriver != "local" && storeDriver != "raft" && storeDriver != "memory":
+		logrus.Errorf("Unknown store driver: %s", storeDriver)
+		return
+	case storeDriver == "raft" && raftPeers == "":
+		logrus.Error("Store driver raft requires raft peers")
+		return
+	case storeDriver != "raft" && raftPeers != "":
+		logrus.Errorf("Raft peers require store driver raft, not %s", storeDriver)
 		return
 	}
 
-	if frameworkId != "" {
-		fw.Id = &mesos.FrameworkID{
-			Value: proto.String(frameworkId),
+	if raftPeers != "" {
+		peers, err := ha.ParsePeers(raftPeers)
+		if err != nil {
+			logrus.Errorf("Parse raft peers failed: %s", err)
+			return
+		}
+
+		config := ha.Config{
+			Addr:    raftAddr,
+			Peers:   peers,
+			DataDir: filepath.Join(dataDir, "raft"),
+		}
+
+		var fsm *raftstore.FSM
+		if storeDriver == "raft" {
+			fsm, err = raftstore.NewFSM(filepath.Join(dataDir, "state.db"))
+			if err != nil {
+				logrus.Errorf("Init store engine failed:%s", err)
+				return
+			}
+
+			config.FSM = fsm
+		}
+
+		node, err = ha.NewNode(config)
+		if err != nil {
+			logrus.Errorf("Start raft node failed: %s", err)
+			return
+		}
+
+		if fsm != nil {
+			db = raftstore.NewRaftStore(node.Raft(), fsm)
+		}
+	}
+
+	if storeDriver == "memory" {
+		logrus.Warn("Store driver memory loses all applications when swan stops")
+		db = memory.NewMemoryStore()
+	}
+
+	if db == nil {
+		db, err = NewBoltStore(filepath.Join(dataDir, "bolt.db"))
+		if err != nil {
+			logrus.Errorf("Init store engine failed:%s", err)
+			return
 		}
 	}
 
@@ -91,8 +216,8 @@ func main() {
 		masterUrls = append(masterUrls, masterUrl)
 	}
 
-	mesos := megos.NewClient(masterUrls, nil)
-	state, err := mesos.GetStateFromCluster()
+	mesosClient := megos.NewClient(masterUrls, nil)
+	state, err := mesosClient.GetStateFromCluster()
 	if err != nil {
 		panic(err)
 	}
@@ -102,28 +227,163 @@ func main() {
 		cluster = "Unnamed"
 	}
 
+	bus := event.NewBus(eventBuffer)
+
+	healthCheckManager := health.NewHealthCheckManager(db, msgQueue)
+	healthCheckManager.EventBus = bus
+	healthCheckManager.Workers = healthCheckWorkers
+	healthCheckManager.SaveInterval = healthCheckSaveInterval
+
 	sched := scheduler.NewScheduler(
 		state.Leader,
 		fw,
-		store,
+		db,
 		cluster,
-		health.NewHealthCheckManager(store, msgQueue),
+		healthCheckManager,
 		msgQueue,
 	)
+	sched.Mesos = mesosClient
+	sched.EventBus = bus
+	sched.Failover = scheduler.FailoverConfig{
+		MaxPercent: failoverMaxPercent,
+		Window:     failoverWindow,
+	}
 
-	backend := backend.NewBackend(sched, store)
+	convergeConfig := backend.ConvergeConfig{
+		Interval:   convergeInterval,
+		MaxActions: convergeMaxActions,
+		DryRun:     convergeDryRun,
+	}
+
+	if orphanPolicy != "report" && orphanPolicy != "kill" {
+		logrus.Errorf("Unknown orphan policy: %s", orphanPolicy)
+		return
+	}
+
+	orphanConfig := backend.OrphanConfig{
+		Interval:    orphanInterval,
+		Kill:        orphanPolicy == "kill",
+		GracePeriod: orphanGracePeriod,
+	}
+
+	auditConfig := backend.AuditConfig{
+		MaxAge:     auditMaxAge,
+		MaxEntries: auditMaxEntries,
+	}
+
+	webhookConfig := webhook.DefaultConfig
+	webhookConfig.MaxAttempts = webhookMaxAttempts
+	webhookConfig.Backoff = webhookBackoff
+
+	dispatcher := webhook.NewDispatcher(db, bus, webhookConfig)
+
+	backend := backend.NewBackend(sched, db)
 
 	srv := api.NewServer(addr)
 
 	routers := []router.Router{
 		application.NewRouter(backend),
+		converge.NewRouter(backend),
+		orphan.NewRouter(backend),
+		admin.NewRouter(backend),
+		audit.NewRouter(backend),
+		events.NewRouter(bus),
+		subscriptions.NewRouter(backend),
 	}
 
 	srv.InitRouter(routers...)
 
+	// lead runs the duties of the leader until the subscription with mesos
+	// ends. ready is called once the state of the tasks is known again.
+	lead := func(ready func()) {
+		frameworkId, err := db.FetchFrameworkID()
+		if err != nil {
+			logrus.Errorf("Fetch framework id failed: %s", err)
+			return
+		}
+
+		if frameworkId != "" {
+			fw.Id = &mesos.FrameworkID{
+				Value: proto.String(frameworkId),
+			}
+		}
+
+		go func() {
+			backend.Recover()
+			ready()
+			backend.StartConverger(convergeConfig)
+			backend.StartOrphanDetector(orphanConfig)
+			backend.StartAuditPruner(auditConfig)
+			dispatcher.Start()
+		}()
+
+		<-sched.Start()
+	}
+
+	if node == nil {
+		go func() {
+			srv.ListenAndServe()
+		}()
+
+		lead(func() {})
+		return
+	}
+
+	srv.SetLeader(node)
+
 	go func() {
 		srv.ListenAndServe()
 	}()
 
-	<-sched.Start()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	leading := false
+	for {
+		select {
+		case isLeader := <-node.LeaderCh():
+			if isLeader && !leading {
+				logrus.Info("Elected as leader")
+				leading = true
+
+				go func() {
+					// Catch up with the store written by the former leader.
+					if err := node.Barrier(time.Minute); err != nil {
+						logrus.Errorf("Apply raft log failed: %s", err)
+					} else {
+						lead(node.SetReady)
+						logrus.Error("Subscription with mesos ended, leave the cluster")
+					}
+
+					node.Shutdown()
+					os.Exit(1)
+				}()
+			}
+
+			// Tasks and deployments of this instance may still be in
+			// flight, exit so the new leader is the only one acting.
+			if !isLeader && leading {
+				logrus.Error("Lost leadership, exit")
+				os.Exit(1)
+			}
+		case <-signals:
+			logrus.Info("Leave the cluster")
+			if err := node.Shutdown(); err != nil {
+				logrus.Errorf("Shutdown raft node failed: %s", err)
+			}
+			return
+		}
+	}
+}
+
+// restoreBackup replaces the local store with a backup taken from
+// /v1/admin/backup.
+func restoreBackup(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return Restore(filepath.Join(dataDir, "bolt.db"), file)
 }
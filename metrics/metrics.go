@@ -0,0 +1,327 @@
+// Package metrics keeps counters and histograms of swan and exposes them in
+// the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefBuckets are the histogram buckets, in seconds, for latencies.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metric is a family of series which can write itself in the text format.
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds the metrics exposed together.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+	}
+}
+
+// DefaultRegistry holds the metrics created by NewCounter and NewHistogram.
+var DefaultRegistry = NewRegistry()
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[m.name()]; ok {
+		panic(fmt.Sprintf("metric %s registered twice", m.name()))
+	}
+
+	r.metrics[m.name()] = m
+}
+
+// Write writes all metrics of r in the text format, sorted by name.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.mu.Lock()
+		m := r.metrics[name]
+		r.mu.Unlock()
+
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics of r.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		buf := bufio.NewWriter(w)
+		r.Write(buf)
+		buf.Flush()
+	})
+}
+
+// Handler serves the metrics of DefaultRegistry.
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// desc is the name, help and label names shared by the series of a metric.
+type desc struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", d.metricName, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+func (d *desc) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, kind)
+}
+
+// labelPairs formats the labels of a series, extra is appended as is.
+func (d *desc) labelPairs(values []string, extra string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, label := range d.labels {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", label, escapeValue(values[i])))
+	}
+
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a metric which only goes up, partitioned by labels.
+type Counter struct {
+	desc
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// NewCounter creates a counter in DefaultRegistry.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		desc:   desc{metricName: name, help: help, labels: labels},
+		series: make(map[string]*counterSeries),
+	}
+
+	DefaultRegistry.register(c)
+	return c
+}
+
+// Inc adds one to the series of the label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the series of the label
+// values.
+func (c *Counter) Add(v float64, values ...string) {
+	key := c.key(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: values}
+		c.series[key] = s
+	}
+
+	s.value += v
+}
+
+// Value returns the value of the series of the label values.
+func (c *Counter) Value(values ...string) float64 {
+	key := c.key(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.series[key]; ok {
+		return s.value
+	}
+
+	return 0
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.header(w, "counter")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.series))
+	for key := range c.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labelPairs(s.values, ""), formatFloat(s.value))
+	}
+}
+
+// Histogram counts observations in buckets, partitioned by labels.
+type Histogram struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates a histogram in DefaultRegistry with the upper
+// bounds of buckets, in increasing order.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{metricName: name, help: help, labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+
+	DefaultRegistry.register(h)
+	return h
+}
+
+// Observe adds v to the series of the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: values, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// Since observes the seconds elapsed since start.
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+// Count returns the number of observations of the series of the label
+// values.
+func (h *Histogram) Count(values ...string) uint64 {
+	key := h.key(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.header(w, "histogram")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			le := fmt.Sprintf("le=\"%s\"", formatFloat(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(s.values, le), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(s.values, "le=\"+Inf\""), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelPairs(s.values, ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelPairs(s.values, ""), s.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+	valueReplacer = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\"", "\\\"")
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeValue(s string) string {
+	return valueReplacer.Replace(s)
+}
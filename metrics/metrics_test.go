@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRegistry() *Registry {
+	r := NewRegistry()
+	DefaultRegistry, r = r, DefaultRegistry
+	return r
+}
+
+func TestCounter(t *testing.T) {
+	saved := newTestRegistry()
+	defer func() { DefaultRegistry = saved }()
+
+	c := NewCounter("swan_test_total", "Test counter.", "app", "state")
+	c.Inc("foo", "TASK_FAILED")
+	c.Add(2, "foo", "TASK_FAILED")
+	c.Inc("b\"ar", "TASK_LOST")
+
+	assert.Equal(t, float64(3), c.Value("foo", "TASK_FAILED"))
+	assert.Equal(t, float64(0), c.Value("foo", "TASK_LOST"))
+
+	var buf bytes.Buffer
+	DefaultRegistry.Write(&buf)
+
+	assert.Equal(t, `# HELP swan_test_total Test counter.
+# TYPE swan_test_total counter
+swan_test_total{app="b\"ar",state="TASK_LOST"} 1
+swan_test_total{app="foo",state="TASK_FAILED"} 3
+`, buf.String())
+
+	assert.Panics(t, func() { c.Inc("foo") })
+	assert.Panics(t, func() { NewCounter("swan_test_total", "Again.") })
+}
+
+func TestHistogram(t *testing.T) {
+	saved := newTestRegistry()
+	defer func() { DefaultRegistry = saved }()
+
+	h := NewHistogram("swan_test_seconds", "Test histogram.", []float64{0.1, 1}, "protocol")
+	h.Observe(0.05, "http")
+	h.Observe(0.5, "http")
+	h.Observe(2, "http")
+
+	assert.Equal(t, uint64(3), h.Count("http"))
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"))
+	assert.Equal(t, `# HELP swan_test_seconds Test histogram.
+# TYPE swan_test_seconds histogram
+swan_test_seconds_bucket{protocol="http",le="0.1"} 1
+swan_test_seconds_bucket{protocol="http",le="1"} 2
+swan_test_seconds_bucket{protocol="http",le="+Inf"} 3
+swan_test_seconds_sum{protocol="http"} 2.55
+swan_test_seconds_count{protocol="http"} 3
+`, rec.Body.String())
+}
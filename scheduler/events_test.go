@@ -2,7 +2,7 @@ package scheduler
 
 import (
 	"github.com/Dataman-Cloud/swan/mesosproto/sched"
-	"github.com/Dataman-Cloud/swan/scheduler/mock"
+	"github.com/Dataman-Cloud/swan/store/memory"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -13,7 +13,7 @@ func TestAddEvent(t *testing.T) {
 		Type: sched.Event_SUBSCRIBED.Enum(),
 	}
 
-	s := NewScheduler("x.x.x.x:yyyy", nil, &mock.Store{}, "xxxx", nil, nil)
+	s := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
 	s.AddEvent(eventType, event)
 
 	e := <-s.GetEvent(eventType)
@@ -29,7 +29,7 @@ func TestAddEvent(t *testing.T) {
 }
 
 func TestGetEvent(t *testing.T) {
-	s := NewScheduler("x.x.x.x:yyyy", nil, &mock.Store{}, "xxxx", nil, nil)
+	s := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
 	ev := s.GetEvent(sched.Event_UNKNOWN)
 	assert.Nil(t, ev)
 }
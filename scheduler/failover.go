@@ -0,0 +1,226 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// Defaults of the failover limits which are left unset.
+	defaultFailoverMaxPercent = 20
+	defaultFailoverWindow     = time.Minute
+
+	// failoverRetryInterval is how often deferred failovers are tried
+	// again.
+	failoverRetryInterval = 10 * time.Second
+)
+
+// FailoverConfig bounds the replacements of tasks whose health checks
+// failed. At most MaxPercent of the instances of an application, at least
+// one, are replaced per Window.
+type FailoverConfig struct {
+	MaxPercent int
+	Window     time.Duration
+}
+
+// failovers records the recent replacements of the tasks of every
+// application and the failovers deferred by the limits.
+type failovers struct {
+	mu       sync.Mutex
+	replaced map[string][]time.Time
+	deferred map[string]map[string]bool
+}
+
+func newFailovers() *failovers {
+	return &failovers{
+		replaced: make(map[string][]time.Time),
+		deferred: make(map[string]map[string]bool),
+	}
+}
+
+// admit reports why the failover of the task named name of app at now is
+// deferred, empty if it may go ahead, in which case it is recorded.
+func (f *failovers) admit(app *types.Application, name string, config FailoverConfig, now time.Time) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if app.FailoverPaused {
+		f.deferLocked(app.ID, name)
+		return "failover is paused"
+	}
+
+	percent := config.MaxPercent
+	if percent <= 0 {
+		percent = defaultFailoverMaxPercent
+	}
+
+	window := config.Window
+	if window <= 0 {
+		window = defaultFailoverWindow
+	}
+
+	limit := app.Instances * percent / 100
+	if limit < 1 {
+		limit = 1
+	}
+
+	var recent []time.Time
+	for _, t := range f.replaced[app.ID] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= limit {
+		f.replaced[app.ID] = recent
+		f.deferLocked(app.ID, name)
+		return fmt.Sprintf("%d of %d instances replaced in the last %s", len(recent), app.Instances, window)
+	}
+
+	f.replaced[app.ID] = append(recent, now)
+	f.dropLocked(app.ID, name)
+
+	return ""
+}
+
+func (f *failovers) deferLocked(appId, name string) {
+	names, ok := f.deferred[appId]
+	if !ok {
+		names = make(map[string]bool)
+		f.deferred[appId] = names
+	}
+	names[name] = true
+}
+
+// drop forgets the deferred failover of the task named name of appId.
+func (f *failovers) drop(appId, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.dropLocked(appId, name)
+}
+
+func (f *failovers) dropLocked(appId, name string) {
+	delete(f.deferred[appId], name)
+	if len(f.deferred[appId]) == 0 {
+		delete(f.deferred, appId)
+	}
+}
+
+// pending returns the names of the tasks whose failover is deferred, by
+// application.
+func (f *failovers) pending() map[string][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pending := make(map[string][]string)
+	for appId, names := range f.deferred {
+		for name := range names {
+			pending[appId] = append(pending[appId], name)
+		}
+	}
+
+	return pending
+}
+
+// hasDeferred reports whether failovers of appId are deferred.
+func (f *failovers) hasDeferred(appId string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.deferred[appId]) != 0
+}
+
+// failover replaces the task named name of application appId if the
+// failover limits of the application allow it and defers it otherwise.
+// The returned channel yields the outcome.
+func (s *Scheduler) failover(appId, name string) <-chan error {
+	errs := make(chan error, 1)
+
+	app, err := s.store.FetchApplication(appId)
+	if err != nil || app == nil {
+		errs <- fmt.Errorf("Application %s not found for rescheduling task %s", appId, name)
+		return errs
+	}
+
+	reason := s.failovers.admit(app, name, s.Failover, time.Now())
+	s.setFailoverStatus(appId, s.failovers.hasDeferred(appId))
+
+	if reason != "" {
+		logrus.Warnf("Defer failover of task %s: %s", name, reason)
+		failoverResults.Inc(appId, "deferred")
+		errs <- types.ErrFailoverDeferred
+		return errs
+	}
+
+	failoverResults.Inc(appId, "admitted")
+
+	return s.Submit(appId, func() error {
+		return s.reschedule(types.ReschedulerMsg{AppID: appId, TaskID: name})
+	})
+}
+
+// recovered forgets the deferred failover of the task named name of
+// application appId, which passed its health checks again.
+func (s *Scheduler) recovered(appId, name string) {
+	s.failovers.drop(appId, name)
+	s.setFailoverStatus(appId, s.failovers.hasDeferred(appId))
+}
+
+// retryFailovers tries the deferred failovers again. Tasks which were
+// replaced or removed since are forgotten.
+func (s *Scheduler) retryFailovers() {
+	for appId, names := range s.failovers.pending() {
+		for _, name := range names {
+			task, _ := s.store.FetchTask(name)
+			if task == nil || task.Health != "unhealthy" {
+				s.failovers.drop(appId, name)
+				continue
+			}
+
+			go func(name string, done <-chan error) {
+				if err := <-done; err != nil && err != types.ErrFailoverDeferred {
+					logrus.Errorf("Failover of task %s failed: %s", name, err.Error())
+				}
+			}(name, s.failover(appId, name))
+		}
+
+		s.setFailoverStatus(appId, s.failovers.hasDeferred(appId))
+	}
+}
+
+// setFailoverStatus marks a running application UNHEALTHY while failovers
+// of its tasks are deferred and RUNNING again once none are left. The
+// statuses of deployments are left alone.
+func (s *Scheduler) setFailoverStatus(appId string, deferred bool) {
+	from, to := "UNHEALTHY", "RUNNING"
+	if deferred {
+		from, to = "RUNNING", "UNHEALTHY"
+	}
+
+	changed := false
+	err := s.store.Update(func(tx store.Tx) error {
+		app, err := tx.FetchApplication(appId)
+		if err != nil || app == nil || app.Status != from {
+			return err
+		}
+
+		app.Status = to
+		changed = true
+
+		return tx.SaveApplication(app)
+	})
+	if err != nil {
+		logrus.Errorf("Set status of application %s to %s failed: %s", appId, to, err.Error())
+		return
+	}
+
+	if changed {
+		s.EventBus.Publish(&types.Event{Type: types.EventAppStatus, AppID: appId, Status: to})
+	}
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/event"
+	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/store/memory"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailoversAdmit(t *testing.T) {
+	f := newFailovers()
+	config := FailoverConfig{MaxPercent: 20, Window: time.Minute}
+	app := &types.Application{ID: "a", Instances: 10}
+	now := time.Now()
+
+	// 20% of 10 instances are replaced per minute.
+	assert.Equal(t, "", f.admit(app, "0.a.b.c", config, now))
+	assert.Equal(t, "", f.admit(app, "1.a.b.c", config, now))
+	assert.NotEqual(t, "", f.admit(app, "2.a.b.c", config, now))
+	assert.True(t, f.hasDeferred("a"))
+
+	assert.Equal(t, "", f.admit(app, "2.a.b.c", config, now.Add(time.Minute)))
+	assert.False(t, f.hasDeferred("a"))
+
+	// Small applications replace one instance at a time.
+	small := &types.Application{ID: "b", Instances: 2}
+	assert.Equal(t, "", f.admit(small, "0.b.c.d", config, now))
+	assert.NotEqual(t, "", f.admit(small, "1.b.c.d", config, now))
+
+	paused := &types.Application{ID: "c", Instances: 10, FailoverPaused: true}
+	assert.Equal(t, "failover is paused", f.admit(paused, "0.c.d.e", config, now))
+	assert.Equal(t, map[string][]string{"b": {"1.b.c.d"}, "c": {"0.c.d.e"}}, f.pending())
+}
+
+func TestFailoverDeferred(t *testing.T) {
+	db := memory.NewMemoryStore()
+	db.SaveApplication(&types.Application{ID: "a", Instances: 2, Status: "RUNNING"})
+	db.SaveTask(&types.Task{ID: "1-1.a.b.c", Name: "1.a.b.c", AppId: "a", Health: "unhealthy"})
+
+	s := NewScheduler("x.x.x.x:yyyy", &mesos.FrameworkInfo{}, db, "xxxxx", nil, nil)
+	s.EventBus = event.NewBus(10)
+	sub, _ := s.EventBus.Subscribe(event.Filter{}, 0)
+	defer sub.Close()
+
+	// One of two instances was just replaced, so the next one waits and
+	// the application is unhealthy meanwhile.
+	s.failovers.admit(&types.Application{ID: "a", Instances: 2}, "0.a.b.c", s.Failover, time.Now())
+	assert.NotNil(t, <-s.failover("a", "1.a.b.c"))
+
+	app, _ := db.FetchApplication("a")
+	assert.Equal(t, "UNHEALTHY", app.Status)
+	e := <-sub.Events()
+	assert.Equal(t, types.EventAppStatus, e.Type)
+	assert.Equal(t, "UNHEALTHY", e.Status)
+
+	// Once the task is replaced otherwise its failover is forgotten.
+	db.SaveTask(&types.Task{ID: "2-1.a.b.c", Name: "1.a.b.c", AppId: "a", Health: "unknown"})
+	s.retryFailovers()
+
+	assert.False(t, s.failovers.hasDeferred("a"))
+	app, _ = db.FetchApplication("a")
+	assert.Equal(t, "RUNNING", app.Status)
+}
+
+func TestFailoverRecovered(t *testing.T) {
+	db := memory.NewMemoryStore()
+	db.SaveApplication(&types.Application{ID: "a", Instances: 2, Status: "RUNNING"})
+	db.SaveTask(&types.Task{ID: "1-1.a.b.c", Name: "1.a.b.c", AppId: "a", Health: "unhealthy"})
+
+	queue := make(chan types.ReschedulerMsg)
+	s := NewScheduler("x.x.x.x:yyyy", &mesos.FrameworkInfo{}, db, "xxxxx", nil, queue)
+	go s.ReschedulerTask()
+	defer close(s.doneChan)
+
+	s.Failover = FailoverConfig{MaxPercent: 20, Window: time.Minute}
+	s.failovers.admit(&types.Application{ID: "a", Instances: 2}, "0.a.b.c", s.Failover, time.Now())
+
+	msg := types.ReschedulerMsg{AppID: "a", TaskID: "1.a.b.c", Err: make(chan error)}
+	queue <- msg
+	assert.Equal(t, types.ErrFailoverDeferred, <-msg.Err)
+	assert.True(t, s.failovers.hasDeferred("a"))
+
+	// A task which passed its health checks again is not replaced.
+	app, _ := db.FetchApplication("a")
+	assert.Equal(t, "UNHEALTHY", app.Status)
+
+	queue <- types.ReschedulerMsg{AppID: "a", TaskID: "1.a.b.c", Recovered: true}
+	for i := 0; i < 100 && app.Status != "RUNNING"; i++ {
+		time.Sleep(10 * time.Millisecond)
+		app, _ = db.FetchApplication("a")
+	}
+
+	assert.Equal(t, "RUNNING", app.Status)
+	assert.False(t, s.failovers.hasDeferred("a"))
+}
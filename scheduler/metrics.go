@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/metrics"
+)
+
+var (
+	offersReceived = metrics.NewCounter("swan_offers_received_total",
+		"Offers received from mesos.")
+	offersDeclined = metrics.NewCounter("swan_offers_declined_total",
+		"Offers declined because no pending task fit them.")
+	offersRescinded = metrics.NewCounter("swan_offers_rescinded_total",
+		"Offers rescinded by mesos.")
+
+	taskLaunches = metrics.NewCounter("swan_task_launches_total",
+		"Tasks launched, by application and result.", "app", "result")
+	taskKills = metrics.NewCounter("swan_task_kills_total",
+		"Tasks killed, by application and result.", "app", "result")
+	taskFailures = metrics.NewCounter("swan_task_failures_total",
+		"Tasks which failed, by application and final state.", "app", "state")
+	failoverResults = metrics.NewCounter("swan_task_failovers_total",
+		"Failovers of tasks whose health checks failed, by application and whether they were admitted or deferred.", "app", "result")
+
+	mesosCallDuration = metrics.NewHistogram("swan_mesos_call_duration_seconds",
+		"Latency of the calls sent to mesos, by call type.", metrics.DefBuckets, "call")
+	mesosCallResponses = metrics.NewCounter("swan_mesos_call_responses_total",
+		"Responses to the calls sent to mesos, by call type and status code, error if no response was received.", "call", "code")
+)
+
+// result is the label value of the outcome of err.
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
+
+// failed reports whether state ends a task abnormally.
+func failed(state mesos.TaskState) bool {
+	switch state {
+	case mesos.TaskState_TASK_FAILED, mesos.TaskState_TASK_LOST, mesos.TaskState_TASK_ERROR:
+		return true
+	}
+
+	return false
+}
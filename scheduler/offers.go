@@ -1,55 +1,18 @@
 package scheduler
 
 import (
-	"errors"
-	"github.com/Sirupsen/logrus"
 	"github.com/golang/protobuf/proto"
 	"net/http"
-	"time"
 
 	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
 	"github.com/Dataman-Cloud/swan/mesosproto/sched"
 )
 
-func (s *Scheduler) RequestOffers(resources []*mesos.Resource) ([]*mesos.Offer, error) {
-	logrus.Info("Requesting offers")
-
-	var event *sched.Event
-
-	select {
-	case event = <-s.GetEvent(sched.Event_OFFERS):
-	case <-time.After(time.Second * time.Duration(5)):
-		return nil, errors.New("Offer timeout")
-	}
-
-	if event == nil {
-		call := &sched.Call{
-			FrameworkId: s.framework.GetId(),
-			Type:        sched.Call_REQUEST.Enum(),
-			Request: &sched.Call_Request{
-				Requests: []*mesos.Request{
-					{
-						Resources: resources,
-					},
-				},
-			},
-		}
-
-		if _, err := s.send(call); err != nil {
-			logrus.Errorf("Request offer failed: %s", err.Error())
-			return nil, err
-		}
-		event = <-s.GetEvent(sched.Event_OFFERS)
-
-	}
-	logrus.Infof("Received %d offer(s).", len(event.Offers.Offers))
-
-	return event.Offers.Offers, nil
-}
-
 // DeclineResource is used to send DECLINE request to mesos to release offer. This
 // is very important, otherwise resource will be taked until framework exited.
 func (s *Scheduler) DeclineResource(offerId *string) (*http.Response, error) {
+	offersDeclined.Inc()
+
 	call := &sched.Call{
 		FrameworkId: s.framework.GetId(),
 		Type:        sched.Call_DECLINE.Enum(),
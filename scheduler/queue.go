@@ -0,0 +1,214 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/mesosproto/sched"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+// launchTimeout is how long a launch intent waits for a matching offer.
+const launchTimeout = 5 * time.Second
+
+// intent is a request to launch or kill a task, submitted to the scheduling
+// loop. The outcome is sent back on result.
+type intent struct {
+	kill     bool
+	task     *types.Task
+	deadline time.Time
+	result   chan *intentResult
+}
+
+type intentResult struct {
+	taskInfo *mesos.TaskInfo
+	err      error
+}
+
+// Launch submits task to the scheduling loop and blocks until it has been
+// launched with a matching offer. The returned TaskInfo carries the host
+// ports allocated for the task.
+func (s *Scheduler) Launch(task *types.Task) (*mesos.TaskInfo, error) {
+	in := &intent{
+		task:     task,
+		deadline: time.Now().Add(launchTimeout),
+		result:   make(chan *intentResult, 1),
+	}
+
+	select {
+	case s.intents <- in:
+	case <-s.doneChan:
+		return nil, errors.New("Scheduler stopped")
+	case <-time.After(launchTimeout):
+		return nil, errors.New("Offer timeout")
+	}
+
+	res := <-in.result
+	return res.taskInfo, res.err
+}
+
+// Kill submits a kill intent for task to the scheduling loop and blocks until
+// mesos accepted the kill call.
+func (s *Scheduler) Kill(task *types.Task) error {
+	in := &intent{
+		kill:   true,
+		task:   task,
+		result: make(chan *intentResult, 1),
+	}
+
+	select {
+	case s.intents <- in:
+	case <-s.doneChan:
+		return errors.New("Scheduler stopped")
+	case <-time.After(launchTimeout):
+		return errors.New("Scheduler busy")
+	}
+
+	return (<-in.result).err
+}
+
+// schedule is the scheduling loop. It is the only owner of offers and
+// pending launch intents, so concurrent operations never share an offer
+// or its ports.
+func (s *Scheduler) schedule() {
+	var pending []*intent
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case in := <-s.intents:
+			// The kill call does not touch offers, so it is sent outside
+			// the loop and a slow master does not hold up launches.
+			if in.kill {
+				go func(in *intent) {
+					in.result <- &intentResult{err: s.kill(in.task)}
+				}(in)
+				continue
+			}
+			pending = append(pending, in)
+
+		case event := <-s.GetEvent(sched.Event_OFFERS):
+			if event == nil {
+				continue
+			}
+			pending = s.dispatch(event.Offers.Offers, pending)
+
+		case event := <-s.GetEvent(sched.Event_RESCIND):
+			// Offers are used or declined as soon as they are received,
+			// so a rescinded offer never has pending work.
+			if event != nil {
+				logrus.Infof("Offer %s rescinded", event.GetRescind().GetOfferId().GetValue())
+			}
+
+		case now := <-ticker.C:
+			pending = expire(pending, now)
+
+		case <-s.doneChan:
+			for _, in := range pending {
+				in.result <- &intentResult{err: errors.New("Scheduler stopped")}
+			}
+			return
+		}
+	}
+}
+
+// dispatch launches as many pending intents as possible with offers and
+// declines the offers which are not used. It returns the intents which are
+// still waiting for resources.
+func (s *Scheduler) dispatch(offers []*mesos.Offer, pending []*intent) []*intent {
+	for _, offer := range offers {
+		if len(pending) == 0 {
+			s.DeclineResource(offer.GetId().Value)
+			continue
+		}
+
+		cpus, mem, disk := s.OfferedResources(offer)
+		ports := GetPorts(offer)
+
+		var (
+			taskInfos []*mesos.TaskInfo
+			launched  []*intent
+			waiting   []*intent
+		)
+
+		for _, in := range pending {
+			task := in.task
+			needPorts := 0
+			if task.Network == "BRIDGE" {
+				needPorts = len(task.PortMappings)
+			}
+
+			if cpus < task.Cpus || mem < task.Mem || disk < task.Disk || len(ports) < needPorts {
+				waiting = append(waiting, in)
+				continue
+			}
+
+			task.OfferId = offer.GetId().Value
+			task.AgentId = offer.AgentId.Value
+			task.AgentHostname = offer.Hostname
+
+			resources := s.BuildResources(task.Cpus, task.Mem, task.Disk)
+			taskInfos = append(taskInfos, s.buildTaskInfo(offer, resources, task, ports[:needPorts]))
+			launched = append(launched, in)
+
+			cpus -= task.Cpus
+			mem -= task.Mem
+			disk -= task.Disk
+			ports = ports[needPorts:]
+		}
+
+		pending = waiting
+
+		if len(taskInfos) == 0 {
+			s.DeclineResource(offer.GetId().Value)
+			continue
+		}
+
+		resp, err := s.LaunchTasks(offer, taskInfos)
+		if err == nil && resp.StatusCode != http.StatusAccepted {
+			err = fmt.Errorf("status code %d received", resp.StatusCode)
+		}
+
+		for i, in := range launched {
+			taskLaunches.Inc(in.task.AppId, result(err))
+			if err != nil {
+				in.result <- &intentResult{err: fmt.Errorf("Launch task failed: %s", err.Error())}
+				continue
+			}
+			in.result <- &intentResult{taskInfo: taskInfos[i]}
+		}
+	}
+
+	return pending
+}
+
+// expire fails the intents which waited for an offer too long.
+func expire(pending []*intent, now time.Time) []*intent {
+	var waiting []*intent
+	for _, in := range pending {
+		if now.After(in.deadline) {
+			in.result <- &intentResult{err: errors.New("Offer timeout")}
+			continue
+		}
+		waiting = append(waiting, in)
+	}
+
+	return waiting
+}
+
+// kill sends the kill call for task to mesos.
+func (s *Scheduler) kill(task *types.Task) error {
+	resp, err := s.KillTask(task)
+	if err == nil && resp.StatusCode != http.StatusAccepted {
+		err = fmt.Errorf("status code %d received", resp.StatusCode)
+	}
+
+	taskKills.Inc(task.AppId, result(err))
+	return err
+}
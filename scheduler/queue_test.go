@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/mesosproto/sched"
+	"github.com/Dataman-Cloud/swan/store/memory"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestOffer() *mesos.Offer {
+	return &mesos.Offer{
+		Id: &mesos.OfferID{
+			Value: proto.String("abcdefghigklmn"),
+		},
+		AgentId: &mesos.AgentID{
+			Value: proto.String("xxxxxx"),
+		},
+		Hostname: proto.String("x.x.x.x"),
+		Resources: []*mesos.Resource{
+			createScalarResource("cpus", 1),
+			createScalarResource("mem", 64),
+			createRangeResource("ports", 1000, 1001),
+		},
+	}
+}
+
+func newTestTask(name string) *types.Task {
+	return &types.Task{
+		ID:      "xxxxx-" + name,
+		Name:    name,
+		Cpus:    0.1,
+		Mem:     16,
+		Image:   proto.String("nginx:1.10"),
+		Network: "BRIDGE",
+		PortMappings: []*types.PortMappings{
+			{
+				Port:     80,
+				Protocol: "tcp",
+			},
+		},
+	}
+}
+
+func newTestMaster() *httptest.Server {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	m := mux.NewRouter()
+	m.HandleFunc("/api/v1/scheduler", f)
+	return httptest.NewServer(m)
+}
+
+func TestLaunch(t *testing.T) {
+	srv := newTestMaster()
+	defer srv.Close()
+
+	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), &mesos.FrameworkInfo{}, memory.NewMemoryStore(), "xxxx", nil, nil)
+	go s.schedule()
+	defer close(s.doneChan)
+
+	// The offer may be declined if it arrives before the intent, so keep
+	// offering until the task has been launched.
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				s.AddEvent(sched.Event_OFFERS, &sched.Event{
+					Offers: &sched.Event_Offers{
+						Offers: []*mesos.Offer{newTestOffer()},
+					},
+				})
+			}
+		}
+	}()
+	defer close(done)
+
+	task := newTestTask("0.a.b.c")
+	taskInfo, err := s.Launch(task)
+	assert.Nil(t, err)
+	assert.Equal(t, *task.AgentHostname, "x.x.x.x")
+	assert.Equal(t, *taskInfo.Container.Docker.PortMappings[0].HostPort, uint32(1000))
+
+	assert.Nil(t, s.Kill(task))
+}
+
+func TestDispatchSharesOfferPorts(t *testing.T) {
+	srv := newTestMaster()
+	defer srv.Close()
+
+	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), &mesos.FrameworkInfo{}, memory.NewMemoryStore(), "xxxx", nil, nil)
+
+	var pending []*intent
+	for _, name := range []string{"0.a.b.c", "1.a.b.c", "2.a.b.c"} {
+		pending = append(pending, &intent{
+			task:   newTestTask(name),
+			result: make(chan *intentResult, 1),
+		})
+	}
+
+	waiting := s.dispatch([]*mesos.Offer{newTestOffer()}, pending)
+
+	// Only two ports are offered, so the third task keeps waiting.
+	assert.Equal(t, len(waiting), 1)
+	assert.Equal(t, waiting[0].task.Name, "2.a.b.c")
+
+	first, second := <-pending[0].result, <-pending[1].result
+	assert.Nil(t, first.err)
+	assert.Nil(t, second.err)
+	assert.Equal(t, *first.taskInfo.Container.Docker.PortMappings[0].HostPort, uint32(1000))
+	assert.Equal(t, *second.taskInfo.Container.Docker.PortMappings[0].HostPort, uint32(1001))
+	assert.Equal(t, []uint32{1001}, pending[1].task.Ports)
+}
+
+func TestDispatchWithoutResources(t *testing.T) {
+	s := NewScheduler("x.x.x.x:yyyy", &mesos.FrameworkInfo{}, memory.NewMemoryStore(), "xxxx", nil, nil)
+
+	offer := &mesos.Offer{
+		Id: &mesos.OfferID{
+			Value: proto.String("abcdefghigklmn"),
+		},
+		AgentId: &mesos.AgentID{
+			Value: proto.String("xxxxxx"),
+		},
+		Hostname: proto.String("x.x.x.x"),
+		Resources: []*mesos.Resource{
+			createScalarResource("cpus", 0.1),
+		},
+	}
+
+	in := &intent{
+		task: &types.Task{
+			Name: "0.a.b.c",
+			Cpus: 1,
+		},
+	}
+
+	pending := s.dispatch([]*mesos.Offer{offer}, []*intent{in})
+	assert.Equal(t, len(pending), 1)
+	assert.Nil(t, in.task.AgentHostname)
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/mesosproto/sched"
+	"github.com/Sirupsen/logrus"
+	"github.com/golang/protobuf/proto"
+)
+
+// Reconcile sends an explicit reconciliation request for every task known by
+// swan and waits until mesos reported the latest state of each of them, or
+// until timeout expires.
+func (s *Scheduler) Reconcile(timeout time.Duration) error {
+	apps, err := s.store.ListApplications()
+	if err != nil {
+		return err
+	}
+
+	var tasks []*sched.Call_Reconcile_Task
+	pending := make(map[string]bool)
+	for _, app := range apps {
+		appTasks, err := s.store.ListTasks(app.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, task := range appTasks {
+			tasks = append(tasks, &sched.Call_Reconcile_Task{
+				TaskId: &mesos.TaskID{
+					Value: proto.String(task.ID),
+				},
+				AgentId: &mesos.AgentID{
+					Value: task.AgentId,
+				},
+			})
+			pending[task.ID] = true
+		}
+	}
+
+	if len(tasks) == 0 {
+		logrus.Info("No tasks to reconcile")
+		return nil
+	}
+
+	done := make(chan struct{})
+
+	s.reconcileMu.Lock()
+	s.reconciling = pending
+	s.reconciled = done
+	s.reconcileMu.Unlock()
+
+	defer func() {
+		s.reconcileMu.Lock()
+		s.reconciling = nil
+		s.reconciled = nil
+		s.reconcileMu.Unlock()
+	}()
+
+	logrus.Infof("Reconcile %d tasks with mesos", len(tasks))
+	if err := s.sendReconcile(tasks); err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+		logrus.Info("Reconciliation finished")
+		return nil
+	case <-time.After(timeout):
+		return errors.New("Reconciliation timeout")
+	}
+}
+
+// markReconciled records that mesos has reported the state of a task which is
+// waiting for reconciliation.
+func (s *Scheduler) markReconciled(taskId string) {
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	if s.reconciling == nil || !s.reconciling[taskId] {
+		return
+	}
+
+	delete(s.reconciling, taskId)
+	if len(s.reconciling) == 0 {
+		close(s.reconciled)
+		s.reconciling = nil
+	}
+}
+
+// ReconcileImplicit asks mesos to report the state of all tasks of the
+// framework, including those swan does not know about. The reports are
+// collected and can be fetched with ObservedTasks.
+func (s *Scheduler) ReconcileImplicit() error {
+	return s.sendReconcile(nil)
+}
+
+// ObservedTasks returns the tasks reported not terminated by reconciliation
+// since the last call, keyed by task id.
+func (s *Scheduler) ObservedTasks() map[string]*mesos.TaskStatus {
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	observed := s.observed
+	s.observed = make(map[string]*mesos.TaskStatus)
+
+	return observed
+}
+
+// observe records the state of a task reported by reconciliation.
+func (s *Scheduler) observe(status *mesos.TaskStatus) {
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	if s.observed == nil {
+		s.observed = make(map[string]*mesos.TaskStatus)
+	}
+
+	switch status.GetState() {
+	case mesos.TaskState_TASK_FINISHED,
+		mesos.TaskState_TASK_FAILED,
+		mesos.TaskState_TASK_KILLED,
+		mesos.TaskState_TASK_LOST,
+		mesos.TaskState_TASK_ERROR:
+		delete(s.observed, status.GetTaskId().GetValue())
+	default:
+		s.observed[status.GetTaskId().GetValue()] = status
+	}
+}
+
+func (s *Scheduler) sendReconcile(tasks []*sched.Call_Reconcile_Task) error {
+	call := &sched.Call{
+		FrameworkId: s.framework.GetId(),
+		Type:        sched.Call_RECONCILE.Enum(),
+		Reconcile: &sched.Call_Reconcile{
+			Tasks: tasks,
+		},
+	}
+
+	resp, err := s.send(call)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusAccepted {
+		return errors.New("Reconcile call returned unexpected status")
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/store/memory"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReconcileWithoutTasks(t *testing.T) {
+	s := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
+	err := s.Reconcile(time.Second)
+	assert.Nil(t, err)
+}
+
+func TestMarkReconciled(t *testing.T) {
+	s := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
+
+	// no reconciliation in progress
+	s.markReconciled("xxxxx")
+
+	done := make(chan struct{})
+	s.reconciling = map[string]bool{"xxxxx": true, "yyyyy": true}
+	s.reconciled = done
+
+	s.markReconciled("zzzzz")
+	s.markReconciled("xxxxx")
+	select {
+	case <-done:
+		t.Fatal("reconciliation finished with pending tasks")
+	default:
+	}
+
+	s.markReconciled("yyyyy")
+	_, open := <-done
+	assert.False(t, open)
+}
+
+func TestObservedTasks(t *testing.T) {
+	s := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
+
+	status := func(id string, state mesos.TaskState) *mesos.TaskStatus {
+		return &mesos.TaskStatus{
+			TaskId: &mesos.TaskID{Value: proto.String(id)},
+			State:  state.Enum(),
+		}
+	}
+
+	s.observe(status("xxxxx", mesos.TaskState_TASK_RUNNING))
+	s.observe(status("yyyyy", mesos.TaskState_TASK_RUNNING))
+	s.observe(status("yyyyy", mesos.TaskState_TASK_KILLED))
+	s.observe(status("zzzzz", mesos.TaskState_TASK_FAILED))
+
+	observed := s.ObservedTasks()
+	assert.Equal(t, 1, len(observed))
+	assert.NotNil(t, observed["xxxxx"])
+
+	assert.Equal(t, 0, len(s.ObservedTasks()))
+}
@@ -53,21 +53,4 @@ type Registry interface {
 
 	// DeleteCheck delete task health check from db.
 	DeleteCheck(string) error
-
-	// UpdateTask updated task status by task id.
-	UpdateTaskStatus(string, string, string) error
-
-	// IncreaseApplicationInstances reduce instances count for application.
-	IncreaseApplicationInstances(string) error
-
-	// IncreaseApplicationUpdatedInstances increase updated instances count.
-	IncreaseApplicationUpdatedInstances(string) error
-
-	ResetApplicationUpdatedInstances(string) error
-
-	UpdateApplicationStatus(string, string) error
-
-	IncreaseApplicationRunningInstances(string) error
-
-	ReduceApplicationInstances(string) error
 }
@@ -1,7 +1,7 @@
 package scheduler
 
 import (
-	"github.com/Dataman-Cloud/swan/scheduler/mock"
+	"github.com/Dataman-Cloud/swan/store/memory"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -18,7 +18,7 @@ func TestCreateRangeResource(t *testing.T) {
 }
 
 func TestBuildResource(t *testing.T) {
-	sched := NewScheduler("x.x.x.x:yyyy", nil, &mock.Store{}, "xxxx", nil, nil)
+	sched := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
 	resources := sched.BuildResources(0.1, 16, 10)
 	assert.Equal(t, *resources[0].Name, "cpus")
 	assert.Equal(t, *resources[1].Name, "mem")
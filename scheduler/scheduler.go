@@ -5,7 +5,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/Dataman-Cloud/swan/event"
 	"github.com/Dataman-Cloud/swan/health"
 	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
 	sched "github.com/Dataman-Cloud/swan/mesosproto/sched"
@@ -13,6 +18,7 @@ import (
 	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
 	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/megos"
 	"github.com/golang/protobuf/proto"
 )
 
@@ -27,14 +33,31 @@ type Scheduler struct {
 	events       Events
 	tasks        []*types.Task
 
-	TaskLaunched int
+	// intents carries launch and kill intents to the scheduling loop.
+	intents chan *intent
 
-	// Status indicated scheduler's state is idle or busy.
-	Status string
+	works *workQueue
 
 	ClusterId string
 
 	HealthCheckManager *health.HealthCheckManager
+
+	// Mesos is used to query the state of the mesos cluster.
+	Mesos *megos.Client
+
+	// EventBus publishes the changes of tasks and applications, nil drops
+	// them.
+	EventBus *event.Bus
+
+	// Failover bounds the replacements of tasks whose health checks
+	// failed.
+	Failover  FailoverConfig
+	failovers *failovers
+
+	reconcileMu sync.Mutex
+	reconciling map[string]bool
+	reconciled  chan struct{}
+	observed    map[string]*mesos.TaskStatus
 }
 
 // NewScheduler returns a pointer to new Scheduler
@@ -56,10 +79,12 @@ func NewScheduler(master string, fw *mesos.FrameworkInfo, store store.Store, clu
 			sched.Event_ERROR:      make(chan *sched.Event, 64),
 			sched.Event_HEARTBEAT:  make(chan *sched.Event, 64),
 		},
-		Status:             "idle",
+		intents:            make(chan *intent),
+		works:              newWorkQueue(),
 		ClusterId:          clusterId,
 		HealthCheckManager: health,
 		ReschedQueue:       queue,
+		failovers:          newFailovers(),
 	}
 }
 
@@ -69,7 +94,11 @@ func (s *Scheduler) Start() <-chan struct{} {
 	if err := s.subscribe(); err != nil {
 		logrus.Error(err)
 		close(s.doneChan)
+		return s.doneChan
 	}
+
+	go s.schedule()
+
 	return s.doneChan
 }
 
@@ -84,7 +113,19 @@ func (s *Scheduler) send(call *sched.Call) (*http.Response, error) {
 	if err != nil {
 		return nil, err
 	}
-	return s.client.Send(payload)
+
+	name := strings.ToLower(call.GetType().String())
+	start := time.Now()
+
+	resp, err := s.client.Send(payload)
+	mesosCallDuration.Since(start, name)
+	if err != nil {
+		mesosCallResponses.Inc(name, "error")
+		return nil, err
+	}
+
+	mesosCallResponses.Inc(name, strconv.Itoa(resp.StatusCode))
+	return resp, nil
 }
 
 // Subscribe subscribes the scheduler to the Mesos cluster.
@@ -162,18 +203,15 @@ func (s *Scheduler) handleEvents(resp *http.Response) {
 			go func() {
 				s.ReschedulerTask()
 			}()
+
+			go s.resumeUnreachable()
 		case sched.Event_OFFERS:
-			if s.Status == "idle" {
-				// Refused all offers when scheduler is idle.
-				for _, offer := range event.Offers.Offers {
-					s.DeclineResource(offer.GetId().Value)
-				}
-			} else {
-				// Accept all offers when scheduler is busy.
-				s.AddEvent(sched.Event_OFFERS, event)
-			}
+			offersReceived.Add(float64(len(event.GetOffers().GetOffers())))
+			// The scheduling loop uses or declines every offer.
+			s.AddEvent(sched.Event_OFFERS, event)
 		case sched.Event_RESCIND:
 			logrus.Info("Received rescind offers")
+			offersRescinded.Inc()
 			s.AddEvent(sched.Event_RESCIND, event)
 
 		case sched.Event_UPDATE:
@@ -182,10 +220,8 @@ func (s *Scheduler) handleEvents(resp *http.Response) {
 				s.status(status)
 			}()
 
-			s.AddEvent(sched.Event_UPDATE, event)
 		case sched.Event_MESSAGE:
 			logrus.Info("Received message event")
-			s.AddEvent(sched.Event_MESSAGE, event)
 
 		case sched.Event_FAILURE:
 			logrus.Error("Received failure event")
@@ -199,17 +235,16 @@ func (s *Scheduler) handleEvents(resp *http.Response) {
 			} else {
 				if fail.GetAgentId() != nil {
 					logrus.Error("Agent ", fail.GetAgentId().GetValue(), " failed ")
+					go s.agentFailed(fail.GetAgentId().GetValue())
 				}
 			}
 
-			s.AddEvent(sched.Event_FAILURE, event)
 		case sched.Event_ERROR:
 			err := event.GetError().GetMessage()
 			logrus.Error(err)
-			s.AddEvent(sched.Event_ERROR, event)
 
 		case sched.Event_HEARTBEAT:
-			s.AddEvent(sched.Event_HEARTBEAT, event)
+			logrus.Debug("Received heartbeat event")
 		}
 
 	}
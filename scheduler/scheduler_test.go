@@ -3,14 +3,14 @@ package scheduler
 import (
 	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
 	"github.com/Dataman-Cloud/swan/mesosproto/sched"
-	"github.com/Dataman-Cloud/swan/scheduler/mock"
+	"github.com/Dataman-Cloud/swan/store/memory"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestSchedulerSend(t *testing.T) {
-	s := NewScheduler("x.x.x.x:yyyy", nil, &mock.Store{}, "xxxx", nil, nil)
+	s := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
 	call := &sched.Call{
 		Type: sched.Call_SUBSCRIBE.Enum(),
 		Subscribe: &sched.Call_Subscribe{
@@ -23,7 +23,7 @@ func TestSchedulerSend(t *testing.T) {
 }
 
 func TestSchedulerStop(t *testing.T) {
-	s := NewScheduler("x.x.x.x:yyyy", nil, &mock.Store{}, "xxxx", nil, nil)
+	s := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
 	s.stop()
 }
 
@@ -38,6 +38,6 @@ func TestSchedulerStart(t *testing.T) {
 		},
 	}
 
-	s := NewScheduler("x.x.x.x:yyyy", fw, &mock.Store{}, "xxxx", nil, nil)
+	s := NewScheduler("x.x.x.x:yyyy", fw, memory.NewMemoryStore(), "xxxx", nil, nil)
 	s.Start()
 }
@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"errors"
+
+	"github.com/andygrunwald/megos"
+)
+
+// FrameworkTasks returns the tasks of the framework which the mesos master
+// reports as not terminated, keyed by task id.
+func (s *Scheduler) FrameworkTasks() (map[string]megos.Task, error) {
+	if s.Mesos == nil {
+		return nil, errors.New("Mesos client not configured")
+	}
+
+	frameworkId := s.framework.GetId().GetValue()
+	if frameworkId == "" {
+		return nil, errors.New("Framework not registered")
+	}
+
+	if _, err := s.Mesos.DetermineLeader(); err != nil {
+		return nil, err
+	}
+
+	state, err := s.Mesos.GetStateFromLeader()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make(map[string]megos.Task)
+	for _, framework := range state.Frameworks {
+		if framework.ID != frameworkId {
+			continue
+		}
+
+		for _, task := range framework.Tasks {
+			tasks[task.ID] = task
+		}
+	}
+
+	return tasks, nil
+}
@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/store/memory"
+	"github.com/andygrunwald/megos"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameworkTasks(t *testing.T) {
+	m := mux.NewRouter()
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	m.HandleFunc("/master/state", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, `{
+			"leader": "master@%s",
+			"frameworks": [
+				{"id": "xxxx", "tasks": [{"id": "1-0.app.user.cluster", "state": "TASK_RUNNING"}]},
+				{"id": "yyyy", "tasks": [{"id": "2-0.other.user.cluster", "state": "TASK_RUNNING"}]}
+			]
+		}`, u.Host)
+	})
+
+	s := NewScheduler(u.Host, &mesos.FrameworkInfo{}, memory.NewMemoryStore(), "xxxx", nil, nil)
+
+	_, err := s.FrameworkTasks()
+	assert.NotNil(t, err)
+
+	s.Mesos = megos.NewClient([]*url.URL{u}, nil)
+
+	_, err = s.FrameworkTasks()
+	assert.NotNil(t, err)
+
+	s.framework.Id = &mesos.FrameworkID{Value: proto.String("xxxx")}
+
+	tasks, err := s.FrameworkTasks()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, "TASK_RUNNING", tasks["1-0.app.user.cluster"].State)
+}
@@ -8,29 +8,37 @@ import (
 
 	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
 	"github.com/Dataman-Cloud/swan/mesosproto/sched"
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/golang/protobuf/proto"
 )
 
-func (s *Scheduler) BuildTask(offer *mesos.Offer, version *types.Version, name string) (*types.Task, error) {
+// BuildTask builds a task of version. The offer related fields are filled in by
+// the scheduling loop when the task is launched.
+func (s *Scheduler) BuildTask(version *types.Version, name string) (*types.Task, error) {
 	var task types.Task
 
 	task.Name = name
 	if task.Name == "" {
-		app, err := s.store.FetchApplication(version.ID)
-		if err != nil {
-			return nil, err
-		}
+		// The name takes the next instance index, so both happen at once.
+		err := s.store.Update(func(tx store.Tx) error {
+			app, err := tx.FetchApplication(version.ID)
+			if err != nil {
+				return err
+			}
 
-		if app == nil {
-			return nil, fmt.Errorf("Application %s not found.", version.ID)
-		}
+			if app == nil {
+				return fmt.Errorf("Application %s not found.", version.ID)
+			}
 
-		task.Name = fmt.Sprintf("%d.%s.%s.%s", app.Instances, app.ID, app.UserId, app.ClusterId)
+			task.Name = fmt.Sprintf("%d.%s.%s.%s", app.Instances, app.ID, app.UserId, app.ClusterId)
+			app.Instances += 1
 
-		if err := s.store.IncreaseApplicationInstances(app.ID); err != nil {
+			return tx.SaveApplication(app)
+		})
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -79,10 +87,6 @@ func (s *Scheduler) BuildTask(offer *mesos.Offer, version *types.Version, name s
 	task.Mem = version.Mem
 	task.Disk = version.Disk
 
-	task.OfferId = offer.GetId().Value
-	task.AgentId = offer.AgentId.Value
-	task.AgentHostname = offer.Hostname
-
 	if version.KillPolicy != nil {
 		task.KillPolicy = version.KillPolicy
 	}
@@ -91,10 +95,24 @@ func (s *Scheduler) BuildTask(offer *mesos.Offer, version *types.Version, name s
 		task.HealthChecks = version.HealthChecks
 	}
 
+	if version.ReadinessChecks != nil {
+		task.ReadinessChecks = version.ReadinessChecks
+	}
+
+	if version.UnreachablePolicy != nil {
+		task.UnreachablePolicy = version.UnreachablePolicy
+	}
+
 	return &task, nil
 }
 
 func (s *Scheduler) BuildTaskInfo(offer *mesos.Offer, resources []*mesos.Resource, task *types.Task) *mesos.TaskInfo {
+	return s.buildTaskInfo(offer, resources, task, GetPorts(offer))
+}
+
+// buildTaskInfo builds the TaskInfo of task, mapping its ports to host ports
+// in order.
+func (s *Scheduler) buildTaskInfo(offer *mesos.Offer, resources []*mesos.Resource, task *types.Task, ports []uint64) *mesos.TaskInfo {
 	logrus.Infof("Prepared task for launch with offer %s", *offer.GetId().Value)
 	taskInfo := mesos.TaskInfo{
 		Name: proto.String(task.Name),
@@ -168,19 +186,29 @@ func (s *Scheduler) BuildTaskInfo(offer *mesos.Offer, resources []*mesos.Resourc
 		}
 	}
 
+	// The ports and address of a former instance are not those of this one.
+	task.Ports = nil
+	task.IPAddress = ""
+
+	task.Ready = false
+	task.Health, task.HealthSince, task.HealthMessage = "", 0, ""
+	if len(task.HealthChecks) != 0 {
+		task.Health, task.HealthSince = "unknown", time.Now().Unix()
+	}
+
 	switch task.Network {
 	case "NONE":
 		taskInfo.Container.Docker.Network = mesos.ContainerInfo_DockerInfo_NONE.Enum()
 	case "HOST":
 		taskInfo.Container.Docker.Network = mesos.ContainerInfo_DockerInfo_HOST.Enum()
 	case "BRIDGE":
-		ports := GetPorts(offer)
-		if len(ports) == 0 {
+		if len(ports) < len(task.PortMappings) {
 			logrus.Errorf("No ports resource defined")
 			break
 		}
-		for _, m := range task.PortMappings {
-			hostPort := ports[s.TaskLaunched]
+		for i, m := range task.PortMappings {
+			hostPort := ports[i]
+			task.Ports = append(task.Ports, uint32(hostPort))
 			taskInfo.Container.Docker.PortMappings = append(taskInfo.Container.Docker.PortMappings,
 				&mesos.ContainerInfo_DockerInfo_PortMapping{
 					HostPort:      proto.Uint32(uint32(hostPort)),
@@ -262,104 +290,103 @@ func (s *Scheduler) KillTask(task *types.Task) (*http.Response, error) {
 	return s.send(call)
 }
 
-// ReschedulerTask process task re-scheduler if needed.
+// ReschedulerTask replaces the tasks whose health checks failed within the
+// failover limits of their applications. Failovers beyond the limits, or
+// of applications whose failover is paused, are deferred and tried again
+// until the task is replaced or gone.
 func (s *Scheduler) ReschedulerTask() {
+	ticker := time.NewTicker(failoverRetryInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case msg := <-s.ReschedQueue:
-			task, err := s.store.FetchTask(msg.TaskID)
-			if err != nil {
-				msg.Err <- fmt.Errorf("Rescheduling task failed: %s", err.Error())
-				return
+			if msg.Recovered {
+				s.recovered(msg.AppID, msg.TaskID)
+				continue
 			}
 
-			if task == nil {
-				msg.Err <- fmt.Errorf("Task %s does not exists", msg.TaskID)
-				return
-			}
+			go func(msg types.ReschedulerMsg, done <-chan error) {
+				msg.Err <- <-done
+			}(msg, s.failover(msg.AppID, msg.TaskID))
+		case <-ticker.C:
+			s.retryFailovers()
+		case <-s.doneChan:
+			return
+		}
+	}
+}
 
-			if _, err := s.KillTask(task); err != nil {
-				msg.Err <- fmt.Errorf("Kill task failed: %s for rescheduling", err.Error())
-				return
-			}
+// reschedule kills the task of msg and launches it again.
+func (s *Scheduler) reschedule(msg types.ReschedulerMsg) error {
+	task, err := s.store.FetchTask(msg.TaskID)
+	if err != nil {
+		return fmt.Errorf("Rescheduling task failed: %s", err.Error())
+	}
 
-			s.Status = "busy"
+	if task == nil {
+		return fmt.Errorf("Task %s does not exists", msg.TaskID)
+	}
 
-			resources := s.BuildResources(task.Cpus, task.Mem, task.Disk)
-			offers, err := s.RequestOffers(resources)
-			if err != nil {
-				msg.Err <- fmt.Errorf("Request offers failed: %s for rescheduling", err.Error())
-				return
-			}
+	if err := s.Kill(task); err != nil {
+		return fmt.Errorf("Kill task failed: %s for rescheduling", err.Error())
+	}
 
-			var choosedOffer *mesos.Offer
-			for _, offer := range offers {
-				cpus, mem, disk := s.OfferedResources(offer)
-				if cpus >= task.Cpus && mem >= task.Mem && disk >= task.Disk {
-					choosedOffer = offer
-					break
-				}
-			}
+	// The checks of the task ran until it was killed, its replacement
+	// is checked afresh.
+	if s.HealthCheckManager != nil {
+		s.HealthCheckManager.StopCheck(task.Name)
+	}
 
-			var taskInfos []*mesos.TaskInfo
-			taskInfo := s.BuildTaskInfo(choosedOffer, resources, task)
-			taskInfos = append(taskInfos, taskInfo)
+	s.EventBus.Publish(&types.Event{
+		Type:    types.EventTaskFailover,
+		AppID:   task.AppId,
+		TaskID:  task.Name,
+		Message: "Health check failed",
+	})
 
-			resp, err := s.LaunchTasks(choosedOffer, taskInfos)
-			if err != nil {
-				msg.Err <- fmt.Errorf("Launchs task failed: %s for rescheduling", err.Error())
-				return
-			}
+	return s.relaunch(types.ActorHealth, task)
+}
 
-			if resp != nil && resp.StatusCode != http.StatusAccepted {
-				msg.Err <- fmt.Errorf("Launchs task failed: status code %d for rescheduling", resp.StatusCode)
-				return
-			}
+// relaunch launches task again with a new task id and moves its health
+// checks to the new instance. actor is recorded as the one who moved it.
+func (s *Scheduler) relaunch(actor string, task *types.Task) error {
+	before := store.Summarize(task)
 
-			logrus.Infof("Remove health check for task %s", msg.TaskID)
-			if err := s.store.DeleteCheck(msg.TaskID); err != nil {
-				msg.Err <- fmt.Errorf("Remove health check for %s failed: %s", msg.TaskID, err.Error())
-				return
-			}
+	task.ID = fmt.Sprintf("%d-%s", time.Now().UnixNano(), task.Name)
 
-			if len(task.HealthChecks) != 0 {
-				if err := s.store.SaveCheck(task,
-					*taskInfo.Container.Docker.PortMappings[0].HostPort,
-					msg.AppID); err != nil {
-				}
-				for _, healthCheck := range task.HealthChecks {
-					check := types.Check{
-						ID:       task.Name,
-						Address:  *task.AgentHostname,
-						Port:     int(*taskInfo.Container.Docker.PortMappings[0].HostPort),
-						TaskID:   task.Name,
-						AppID:    msg.AppID,
-						Protocol: healthCheck.Protocol,
-						Interval: int(healthCheck.IntervalSeconds),
-						Timeout:  int(healthCheck.TimeoutSeconds),
-					}
-					if healthCheck.Command != nil {
-						check.Command = healthCheck.Command
-					}
-
-					if healthCheck.Path != nil {
-						check.Path = *healthCheck.Path
-					}
-
-					if healthCheck.MaxConsecutiveFailures != nil {
-						check.MaxFailures = *healthCheck.MaxConsecutiveFailures
-					}
-
-					s.HealthCheckManager.Add(&check)
-				}
-			}
+	_, err := s.Launch(task)
+	if err != nil {
+		return fmt.Errorf("Launchs task failed: %s for rescheduling", err.Error())
+	}
 
-			msg.Err <- nil
+	logrus.Infof("Remove health check for task %s", task.Name)
+	err = s.store.Update(func(tx store.Tx) error {
+		if err := tx.SaveTask(task); err != nil {
+			return fmt.Errorf("Save task %s failed: %s for rescheduling", task.Name, err.Error())
+		}
 
-			s.Status = "idle"
+		if err := store.Audit(tx, actor, "task.relaunch", task.AppId, task.Name, before, task); err != nil {
+			return err
+		}
 
-		case <-s.doneChan:
-			return
+		if err := tx.DeleteCheck(task.Name); err != nil {
+			return fmt.Errorf("Remove health check for %s failed: %s", task.Name, err.Error())
 		}
+
+		if store.HasChecks(task) {
+			return tx.SaveCheck(task)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+
+	for _, check := range store.TaskChecks(task) {
+		s.HealthCheckManager.Add(check)
+	}
+
+	return nil
 }
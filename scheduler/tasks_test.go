@@ -2,7 +2,7 @@ package scheduler
 
 import (
 	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
-	"github.com/Dataman-Cloud/swan/scheduler/mock"
+	"github.com/Dataman-Cloud/swan/store/memory"
 	"github.com/Dataman-Cloud/swan/types"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
@@ -10,17 +10,6 @@ import (
 )
 
 func TestBuildTask(t *testing.T) {
-	offer := &mesos.Offer{
-		Id: &mesos.OfferID{
-			Value: proto.String("abcdefghigklmn"),
-		},
-		FrameworkId: nil,
-		AgentId: &mesos.AgentID{
-			Value: proto.String("xxxxxx"),
-		},
-		Hostname: proto.String("x.x.x.x"),
-	}
-
 	version := &types.Version{
 		ID:        "test",
 		Command:   nil,
@@ -78,8 +67,8 @@ func TestBuildTask(t *testing.T) {
 		UpdatePolicy: nil,
 	}
 
-	sched := NewScheduler("x.x.x.x:yyyy", nil, &mock.Store{}, "xxxx", nil, nil)
-	task, _ := sched.BuildTask(offer, version, "a.b.c.d")
+	sched := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
+	task, _ := sched.BuildTask(version, "a.b.c.d")
 	assert.Equal(t, task.Name, "a.b.c.d")
 }
 
@@ -192,7 +181,7 @@ func TestBuildTaskInfo(t *testing.T) {
 		AppId:         "testapp",
 	}
 
-	s := NewScheduler("x.x.x.x:yyyy", nil, &mock.Store{}, "xxxx", nil, nil)
+	s := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
 	taskInfo := s.BuildTaskInfo(offer, resources, task)
 	assert.Equal(t, *taskInfo.Container.Docker.Image, "nginx:1.10")
 
@@ -314,7 +303,7 @@ func TestLaunchTasks(t *testing.T) {
 		AppId:         "testapp",
 	}
 
-	s := NewScheduler("x.x.x.x:yyyy", nil, &mock.Store{}, "xxxx", nil, nil)
+	s := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
 	taskInfo := s.BuildTaskInfo(offer, resources, task)
 
 	var tasks []*mesos.TaskInfo
@@ -376,7 +365,7 @@ func TestKillTask(t *testing.T) {
 		Status:        "RUNNING",
 		AppId:         "testapp",
 	}
-	s := NewScheduler("x.x.x.x:yyyy", nil, &mock.Store{}, "xxxx", nil, nil)
+	s := NewScheduler("x.x.x.x:yyyy", nil, memory.NewMemoryStore(), "xxxx", nil, nil)
 
 	_, err := s.KillTask(task)
 	assert.NotNil(t, err)
@@ -394,7 +383,7 @@ func TestReschedulerTask(t *testing.T) {
 		},
 	}
 
-	s := NewScheduler("x.x.x.x:yyyy", fw, &mock.Store{}, "xxxxx", nil, msgQueue)
+	s := NewScheduler("x.x.x.x:yyyy", fw, memory.NewMemoryStore(), "xxxxx", nil, msgQueue)
 	go func() {
 		s.ReschedulerTask()
 	}()
@@ -408,4 +397,10 @@ func TestReschedulerTask(t *testing.T) {
 	s.ReschedQueue <- msg
 	err := <-msg.Err
 	assert.NotNil(t, err)
+
+	// Errors do not stop the rescheduler.
+	msg.Err = make(chan error)
+	s.ReschedQueue <- msg
+	assert.NotNil(t, <-msg.Err)
+	close(s.doneChan)
 }
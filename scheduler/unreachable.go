@@ -0,0 +1,263 @@
+package scheduler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+// defaultRescheduleAfter is how long tasks without an unreachable policy
+// wait for their agent to come back.
+const defaultRescheduleAfter = 5 * time.Minute
+
+// agentFailed marks every task running on the failed agent unreachable.
+func (s *Scheduler) agentFailed(agentId string) {
+	apps, err := s.store.ListApplications()
+	if err != nil {
+		logrus.Errorf("List applications failed: %s", err.Error())
+		return
+	}
+
+	for _, app := range apps {
+		tasks, err := s.store.ListTasks(app.ID)
+		if err != nil {
+			logrus.Errorf("List tasks of application %s failed: %s", app.ID, err.Error())
+			continue
+		}
+
+		for _, task := range tasks {
+			if task.AgentId == nil || *task.AgentId != agentId {
+				continue
+			}
+
+			s.markUnreachable(task)
+		}
+	}
+}
+
+// markUnreachable records that the agent of task is gone and applies the
+// unreachable policy of the task. Health checks are suspended so they do not
+// reschedule the task behind the back of the policy.
+func (s *Scheduler) markUnreachable(task *types.Task) {
+	if task.Status == "UNREACHABLE" || task.Status == "RESCHEDULING" {
+		return
+	}
+
+	logrus.Warnf("Task %s is unreachable", task.Name)
+
+	if s.HealthCheckManager != nil {
+		s.HealthCheckManager.StopCheck(task.Name)
+	}
+
+	wasRunning := task.Status == "RUNNING"
+	before := store.Summarize(task)
+
+	task.Status = "UNREACHABLE"
+	task.UnreachableSince = time.Now().Unix()
+	err := s.store.Update(func(tx store.Tx) error {
+		if err := tx.SaveTask(task); err != nil {
+			return err
+		}
+
+		if err := store.Audit(tx, types.ActorScheduler, "task.unreachable", task.AppId, task.Name, before, task); err != nil {
+			return err
+		}
+
+		if !wasRunning {
+			return nil
+		}
+
+		app, err := tx.FetchApplication(task.AppId)
+		if err != nil || app == nil {
+			return err
+		}
+
+		app.RunningInstances -= 1
+
+		return tx.SaveApplication(app)
+	})
+	if err != nil {
+		logrus.Errorf("Save task %s failed: %s", task.Name, err.Error())
+		return
+	}
+
+	s.scheduleUnreachable(task)
+}
+
+// scheduleUnreachable arranges for the unreachable task to be replaced once
+// its policy gives up on the agent.
+func (s *Scheduler) scheduleUnreachable(task *types.Task) {
+	after := defaultRescheduleAfter
+	if policy := task.UnreachablePolicy; policy != nil {
+		if policy.WaitForever {
+			logrus.Infof("Wait for unreachable task %s forever", task.Name)
+			return
+		}
+
+		after = time.Duration(policy.RescheduleAfterSeconds) * time.Second
+	}
+
+	delay := after - time.Since(time.Unix(task.UnreachableSince, 0))
+	logrus.Infof("Reschedule unreachable task %s in %s", task.Name, delay)
+
+	name, id, since := task.Name, task.ID, task.UnreachableSince
+	time.AfterFunc(delay, func() {
+		err := <-s.Submit(task.AppId, func() error {
+			return s.replaceUnreachable(name, id, since)
+		})
+		if err != nil {
+			logrus.Errorf("Reschedule unreachable task %s failed: %s", name, err.Error())
+		}
+	})
+}
+
+// replaceUnreachable launches a replacement of task unless its agent came
+// back or it has been replaced already.
+func (s *Scheduler) replaceUnreachable(name, id string, since int64) error {
+	task, err := s.store.FetchTask(name)
+	if err != nil {
+		return err
+	}
+
+	if task == nil || task.ID != id || task.Status != "UNREACHABLE" || task.UnreachableSince != since {
+		return nil
+	}
+
+	// The agent may still come back, kill the original so it does not
+	// run next to its replacement.
+	if err := s.Kill(task); err != nil {
+		logrus.Warnf("Kill unreachable task %s failed: %s", id, err.Error())
+	}
+
+	task.Status = "RESCHEDULING"
+	task.UnreachableSince = 0
+
+	s.EventBus.Publish(&types.Event{
+		Type:    types.EventTaskFailover,
+		AppID:   task.AppId,
+		TaskID:  task.Name,
+		Message: "Agent unreachable",
+	})
+
+	return s.relaunch(types.ActorScheduler, task)
+}
+
+// resumeUnreachable arranges the replacement of the tasks which were
+// unreachable when swan stopped.
+func (s *Scheduler) resumeUnreachable() {
+	apps, err := s.store.ListApplications()
+	if err != nil {
+		logrus.Errorf("List applications failed: %s", err.Error())
+		return
+	}
+
+	for _, app := range apps {
+		tasks, err := s.store.ListTasks(app.ID)
+		if err != nil {
+			logrus.Errorf("List tasks of application %s failed: %s", app.ID, err.Error())
+			continue
+		}
+
+		for _, task := range tasks {
+			if task.Status == "UNREACHABLE" {
+				s.scheduleUnreachable(task)
+			}
+		}
+	}
+}
+
+// reachable restores a task whose agent came back before it was replaced.
+func (s *Scheduler) reachable(task *types.Task) {
+	logrus.Infof("Task %s is reachable again", task.Name)
+
+	task.UnreachableSince = 0
+	err := s.store.Update(func(tx store.Tx) error {
+		if err := tx.SaveTask(task); err != nil {
+			return err
+		}
+
+		return store.Audit(tx, types.ActorScheduler, "task.reachable", task.AppId, task.Name, "status=UNREACHABLE", task)
+	})
+	if err != nil {
+		logrus.Errorf("Save task %s failed: %s", task.Name, err.Error())
+	}
+
+	if s.HealthCheckManager == nil || !store.HasChecks(task) {
+		return
+	}
+
+	checks, err := s.store.ListChecks()
+	if err != nil {
+		logrus.Errorf("List health checks failed: %s", err.Error())
+		return
+	}
+
+	for _, check := range checks {
+		if check.TaskID == task.Name {
+			s.HealthCheckManager.Add(check)
+		}
+	}
+}
+
+// superseded reports whether status belongs to an older instance of task,
+// one which has been replaced while its agent was unreachable or during
+// rescheduling.
+func superseded(task *types.Task, status *mesos.TaskStatus) bool {
+	id := status.GetTaskId().GetValue()
+	if task == nil || task.ID == id {
+		return false
+	}
+
+	current, err := strconv.ParseInt(strings.Split(task.ID, "-")[0], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	reported, err := strconv.ParseInt(strings.Split(id, "-")[0], 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return reported < current
+}
+
+// dedupe kills an older instance of a task which reappeared, usually when
+// its agent reregistered after the task had been replaced.
+func (s *Scheduler) dedupe(task *types.Task, status *mesos.TaskStatus) {
+	switch status.GetState() {
+	case mesos.TaskState_TASK_STAGING,
+		mesos.TaskState_TASK_STARTING,
+		mesos.TaskState_TASK_RUNNING:
+	default:
+		return
+	}
+
+	logrus.Warnf("Kill task %s which has been replaced by %s", status.GetTaskId().GetValue(), task.ID)
+
+	original := &types.Task{
+		ID:      status.GetTaskId().GetValue(),
+		Name:    task.Name,
+		AgentId: status.GetAgentId().Value,
+	}
+
+	if err := s.Kill(original); err != nil {
+		logrus.Errorf("Kill replaced task %s failed: %s", original.ID, err.Error())
+	}
+}
+
+// agentGone reports whether status says the agent of the task has been
+// disconnected or removed.
+func agentGone(status *mesos.TaskStatus) bool {
+	switch status.GetReason() {
+	case mesos.TaskStatus_REASON_AGENT_DISCONNECTED,
+		mesos.TaskStatus_REASON_AGENT_REMOVED:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
+	"github.com/Dataman-Cloud/swan/store/memory"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuperseded(t *testing.T) {
+	status := func(id string) *mesos.TaskStatus {
+		return &mesos.TaskStatus{
+			TaskId: &mesos.TaskID{Value: proto.String(id)},
+		}
+	}
+
+	task := &types.Task{ID: "200-0.app.user.cluster", Name: "0.app.user.cluster"}
+
+	assert.True(t, superseded(task, status("100-0.app.user.cluster")))
+	assert.False(t, superseded(task, status("200-0.app.user.cluster")))
+	assert.False(t, superseded(task, status("300-0.app.user.cluster")))
+	assert.False(t, superseded(task, status("xxx-0.app.user.cluster")))
+	assert.False(t, superseded(nil, status("100-0.app.user.cluster")))
+}
+
+func TestAgentFailed(t *testing.T) {
+	db := memory.NewMemoryStore()
+
+	db.SaveApplication(&types.Application{
+		ID:               "app",
+		Name:             "app",
+		Instances:        2,
+		RunningInstances: 2,
+	})
+
+	agent, other := "agent-1", "agent-2"
+	db.SaveTask(&types.Task{
+		ID:                "100-0.app.user.cluster",
+		Name:              "0.app.user.cluster",
+		AppId:             "app",
+		AgentId:           &agent,
+		Status:            "RUNNING",
+		UnreachablePolicy: &types.UnreachablePolicy{WaitForever: true},
+	})
+	db.SaveTask(&types.Task{
+		ID:      "100-1.app.user.cluster",
+		Name:    "1.app.user.cluster",
+		AppId:   "app",
+		AgentId: &other,
+		Status:  "RUNNING",
+	})
+
+	s := NewScheduler("x.x.x.x:yyyy", nil, db, "xxxx", nil, nil)
+	s.agentFailed(agent)
+
+	task, _ := db.FetchTask("0.app.user.cluster")
+	assert.Equal(t, "UNREACHABLE", task.Status)
+	assert.NotEqual(t, int64(0), task.UnreachableSince)
+
+	task, _ = db.FetchTask("1.app.user.cluster")
+	assert.Equal(t, "RUNNING", task.Status)
+
+	app, _ := db.FetchApplication("app")
+	assert.Equal(t, 1, app.RunningInstances)
+
+	// A task which came back is not replaced.
+	s.updateTaskStatus("0.app.user.cluster", "RUNNING")
+	task, _ = db.FetchTask("0.app.user.cluster")
+	assert.Nil(t, s.replaceUnreachable(task.Name, task.ID, task.UnreachableSince))
+
+	task, _ = db.FetchTask("0.app.user.cluster")
+	assert.Equal(t, "100-0.app.user.cluster", task.ID)
+}
+
+func TestReachable(t *testing.T) {
+	db := memory.NewMemoryStore()
+
+	db.SaveApplication(&types.Application{
+		ID:               "app",
+		Name:             "app",
+		Instances:        1,
+		RunningInstances: 0,
+	})
+
+	db.SaveTask(&types.Task{
+		ID:               "100-0.app.user.cluster",
+		Name:             "0.app.user.cluster",
+		AppId:            "app",
+		Status:           "UNREACHABLE",
+		UnreachableSince: 1,
+	})
+
+	s := NewScheduler("x.x.x.x:yyyy", nil, db, "xxxx", nil, nil)
+	s.status(&mesos.TaskStatus{
+		TaskId: &mesos.TaskID{Value: proto.String("100-0.app.user.cluster")},
+		State:  mesos.TaskState_TASK_RUNNING.Enum(),
+	})
+
+	// The task is saved as it was updated when its agent came back.
+	task, _ := db.FetchTask("0.app.user.cluster")
+	assert.Equal(t, "RUNNING", task.Status)
+	assert.True(t, task.Ready)
+	assert.Equal(t, int64(0), task.UnreachableSince)
+
+	app, _ := db.FetchApplication("app")
+	assert.Equal(t, 1, app.RunningInstances)
+}
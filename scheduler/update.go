@@ -1,11 +1,15 @@
 package scheduler
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
 	"github.com/Dataman-Cloud/swan/mesosproto/sched"
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
 	"github.com/Sirupsen/logrus"
 )
 
@@ -40,41 +44,129 @@ func (s *Scheduler) status(status *mesos.TaskStatus) {
 	taskId := strings.Split(ID, "-")[1]
 	appId := strings.Split(taskId, ".")[1]
 
+	stored, err := s.store.FetchTask(taskId)
+
+	if status.GetReason() == mesos.TaskStatus_REASON_RECONCILIATION {
+		s.markReconciled(ID)
+		s.observe(status)
+
+		// Tasks swan lost track of are left to orphan detection.
+		if err != nil || stored == nil {
+			return
+		}
+	}
+
+	if err == nil && superseded(stored, status) {
+		s.dedupe(stored, status)
+		return
+	}
+
+	if failed(state) {
+		taskFailures.Inc(appId, state.String())
+	}
+
+	s.EventBus.Publish(&types.Event{
+		Type:    types.EventTaskStatus,
+		AppID:   appId,
+		TaskID:  taskId,
+		Status:  state.String(),
+		Message: status.GetMessage(),
+	})
+
 	var STATUS string
 
 	switch state {
 	case mesos.TaskState_TASK_STAGING:
 		STATUS = "STAGING"
-		if err := s.store.UpdateTaskStatus(taskId, "STAGING"); err != nil {
+		if err := s.updateTaskStatus(taskId, "STAGING"); err != nil {
 			logrus.Errorf("updating task %s status to STAGING failed", taskId)
 		}
 	case mesos.TaskState_TASK_STARTING:
 		STATUS = "STARTING"
-		if err := s.store.UpdateTaskStatus(taskId, "STARTING"); err != nil {
+		if err := s.updateTaskStatus(taskId, "STARTING"); err != nil {
 			logrus.Errorf("updating task %s status to STARTING failed", taskId)
 		}
 	case mesos.TaskState_TASK_RUNNING:
 		STATUS = "RUNNING"
-		if err := s.store.IncreaseApplicationRunningInstances(appId); err != nil {
-			logrus.Errorf("Updating application got error: %s", err.Error())
-		}
+		appRunning := false
+		var addressed, reachable *types.Task
+		err := s.store.Update(func(tx store.Tx) error {
+			task, err := tx.FetchTask(taskId)
+			if err != nil {
+				return err
+			}
+
+			before := store.Summarize(task)
+
+			// Reconciliation replays RUNNING for tasks which are running
+			// already, those are counted once. Unreachable tasks were
+			// uncounted when their agent went away and count again.
+			wasRunning := task.Status == "RUNNING"
+			if task.Status == "UNREACHABLE" {
+				reachable = task
+			}
 
-		if err := s.store.UpdateTaskStatus(taskId, "RUNNING"); err != nil {
+			task.Status = "RUNNING"
+			if len(task.ReadinessChecks) == 0 {
+				task.Ready = true
+			}
+
+			// Checks of tasks on a USER network wait for their address.
+			if ip := containerIP(status); task.Network == "USER" && ip != "" && ip != task.IPAddress {
+				task.IPAddress = ip
+				if store.HasChecks(task) {
+					if err := tx.SaveCheck(task); err != nil {
+						return err
+					}
+					addressed = task
+				}
+			}
+
+			if err := tx.SaveTask(task); err != nil {
+				return err
+			}
+
+			if err := store.Audit(tx, types.ActorScheduler, "task.status", appId, taskId, before, task); err != nil {
+				return err
+			}
+
+			app, err := tx.FetchApplication(appId)
+			if err != nil || app == nil {
+				return err
+			}
+
+			if wasRunning {
+				return nil
+			}
+
+			app.RunningInstances += 1
+			if app.RunningInstances == app.Instances && app.Status != "UPDATING" && app.Status != "UNHEALTHY" {
+				appRunning = app.Status != "RUNNING"
+				app.Status = "RUNNING"
+			}
+
+			return tx.SaveApplication(app)
+		})
+		if err != nil {
 			logrus.Errorf("updating task %s status to RUNNING failed: %s", taskId, err.Error())
 			return
 		}
 
-		app, err := s.store.FetchApplication(appId)
-		if err != nil {
-			break
+		if appRunning {
+			s.EventBus.Publish(&types.Event{Type: types.EventAppStatus, AppID: appId, Status: "RUNNING"})
 		}
 
-		if app.RunningInstances == app.Instances && app.Status != "UPDATING" {
-			if err := s.store.UpdateApplicationStatus(appId, "RUNNING"); err != nil {
-				logrus.Errorf("Updating application got error: %s", err.Error())
+		if addressed != nil {
+			s.HealthCheckManager.StopCheck(addressed.Name)
+			for _, check := range store.TaskChecks(addressed) {
+				s.HealthCheckManager.Add(check)
 			}
 		}
 
+		if reachable != nil {
+			s.reachable(reachable)
+		}
+
 	case mesos.TaskState_TASK_FINISHED:
 		logrus.Infof("Task Finished, message: %s", status.GetMessage())
 		STATUS = "RESCHEDULING"
@@ -87,6 +179,12 @@ func (s *Scheduler) status(status *mesos.TaskStatus) {
 	case mesos.TaskState_TASK_LOST:
 		logrus.Infof("Task Lost, message: %s", status.GetMessage())
 		STATUS = "RESCHEDULING"
+
+		// The unreachable policy decides when tasks of a lost agent move.
+		if agentGone(status) && stored != nil {
+			s.markUnreachable(stored)
+			return
+		}
 	}
 
 	task, err := s.store.FetchTask(taskId)
@@ -106,39 +204,68 @@ func (s *Scheduler) status(status *mesos.TaskStatus) {
 		task.Status != "RESCHEDULING" &&
 		app.Status != "UPDATING" &&
 		app.Status != "ROLLINGBACK" {
-		if err := s.store.UpdateTaskStatus(taskId, "RESCHEDULING"); err != nil {
+		if err := s.updateTaskStatus(taskId, "RESCHEDULING"); err != nil {
 			logrus.Errorf("updating task status to RESCHEDULING failed: %s", taskId)
 		}
 
-		s.Status = "busy"
+		s.EventBus.Publish(&types.Event{
+			Type:    types.EventTaskFailover,
+			AppID:   appId,
+			TaskID:  taskId,
+			Message: fmt.Sprintf("Task %s", state.String()),
+		})
 
-		resources := s.BuildResources(task.Cpus, task.Mem, task.Disk)
-		offers, err := s.RequestOffers(resources)
+		// Launch the task again with a new task id.
+		task.ID = fmt.Sprintf("%d-%s", time.Now().UnixNano(), task.Name)
+		task.Status = "RESCHEDULING"
+		err := <-s.Submit(appId, func() error {
+			if _, err := s.Launch(task); err != nil {
+				return err
+			}
+
+			return s.store.Update(func(tx store.Tx) error {
+				if err := tx.SaveTask(task); err != nil {
+					return err
+				}
+
+				return store.Audit(tx, types.ActorScheduler, "task.reschedule", appId, taskId, nil, task)
+			})
+		})
 		if err != nil {
-			logrus.Errorf("Request offers failed: %s for rescheduling", err.Error())
-			return
+			logrus.Errorf("Launchs task failed: %s for rescheduling", err.Error())
 		}
+	}
+}
 
-		var choosedOffer *mesos.Offer
-		for _, offer := range offers {
-			cpus, mem, disk := s.OfferedResources(offer)
-			if cpus >= task.Cpus && mem >= task.Mem && disk >= task.Disk {
-				choosedOffer = offer
-				break
+// containerIP returns the first address of the container reported in
+// status, if any.
+func containerIP(status *mesos.TaskStatus) string {
+	for _, info := range status.GetContainerStatus().GetNetworkInfos() {
+		for _, address := range info.GetIpAddresses() {
+			if address.GetIpAddress() != "" {
+				return address.GetIpAddress()
 			}
 		}
+	}
 
-		var taskInfos []*mesos.TaskInfo
-		taskInfo := s.BuildTaskInfo(choosedOffer, resources, task)
-		taskInfos = append(taskInfos, taskInfo)
+	return ""
+}
 
-		resp, err := s.LaunchTasks(choosedOffer, taskInfos)
+// updateTaskStatus changes the status of the stored task.
+func (s *Scheduler) updateTaskStatus(taskId, status string) error {
+	return s.store.Update(func(tx store.Tx) error {
+		task, err := tx.FetchTask(taskId)
 		if err != nil {
-			logrus.Errorf("Launchs task failed: %s for rescheduling", err.Error())
+			return err
 		}
 
-		if resp != nil && resp.StatusCode != http.StatusAccepted {
-			logrus.Errorf("Launchs task failed: status code %d for rescheduling", resp.StatusCode)
+		before := store.Summarize(task)
+
+		task.Status = status
+		if err := tx.SaveTask(task); err != nil {
+			return err
 		}
-	}
+
+		return store.Audit(tx, types.ActorScheduler, "task.status", task.AppId, taskId, before, task)
+	})
 }
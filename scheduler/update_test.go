@@ -3,13 +3,13 @@ package scheduler
 import (
 	"github.com/Dataman-Cloud/swan/mesosproto/mesos"
 	"github.com/Dataman-Cloud/swan/mesosproto/sched"
-	. "github.com/Dataman-Cloud/swan/store/local"
+	"github.com/Dataman-Cloud/swan/store/memory"
 	"github.com/Dataman-Cloud/swan/types"
 	"github.com/golang/protobuf/proto"
 	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
 )
@@ -23,11 +23,7 @@ func TestStatusRunning(t *testing.T) {
 	srv := httptest.NewServer(m)
 	defer srv.Close()
 
-	bolt, _ := NewBoltStore("/tmp/boltdbtest")
-	defer func() {
-		bolt.Close()
-		os.Remove("/tmp/boltdbtest")
-	}()
+	db := memory.NewMemoryStore()
 
 	app := &types.Application{
 		ID:               "bb",
@@ -36,7 +32,7 @@ func TestStatusRunning(t *testing.T) {
 		Instances:        1,
 	}
 
-	bolt.SaveApplication(app)
+	db.SaveApplication(app)
 
 	task := &types.Task{
 		ID:     "aa.bb.cc.dd",
@@ -45,9 +41,9 @@ func TestStatusRunning(t *testing.T) {
 		Status: "",
 	}
 
-	bolt.SaveTask(task)
+	db.SaveTask(task)
 
-	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, bolt, "xxxxx", nil, nil)
+	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, db, "xxxxx", nil, nil)
 	ev := &sched.Event{
 		Type: sched.Event_UPDATE.Enum(),
 		Update: &sched.Event_Update{
@@ -66,6 +62,66 @@ func TestStatusRunning(t *testing.T) {
 	}
 	status := ev.GetUpdate().GetStatus()
 	s.status(status)
+
+	// The status change is recorded in the audit log.
+	entries, _ := db.ListAuditEntries(&types.AuditQuery{AppID: "bb"})
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, types.ActorScheduler, entries[0].Actor)
+	assert.Equal(t, "task.status", entries[0].Operation)
+	assert.Equal(t, "aa.bb.cc.dd", entries[0].Object)
+	assert.Equal(t, "id=aa.bb.cc.dd status= agent=", entries[0].Before)
+	assert.Equal(t, "id=aa.bb.cc.dd status=RUNNING agent=", entries[0].After)
+}
+
+func TestStatusRunningReconciled(t *testing.T) {
+	f := func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	m := mux.NewRouter()
+	m.HandleFunc("/api/v1/scheduler", f)
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+
+	db := memory.NewMemoryStore()
+
+	app := &types.Application{
+		ID:               "bb",
+		Name:             "bb",
+		Status:           "RUNNING",
+		RunningInstances: 1,
+		Instances:        1,
+	}
+
+	db.SaveApplication(app)
+
+	task := &types.Task{
+		ID:     "aa.bb.cc.dd",
+		Name:   "aa.bb.cc.dd",
+		AppId:  "bb",
+		Status: "RUNNING",
+	}
+
+	db.SaveTask(task)
+
+	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, db, "xxxxx", nil, nil)
+	status := &mesos.TaskStatus{
+		TaskId: &mesos.TaskID{
+			Value: proto.String("xxxxxx-aa.bb.cc.dd"),
+		},
+		State:  mesos.TaskState_TASK_RUNNING.Enum(),
+		Reason: mesos.TaskStatus_REASON_RECONCILIATION.Enum(),
+		AgentId: &mesos.AgentID{
+			Value: proto.String("yyyyyyyyy"),
+		},
+	}
+
+	// Replayed statuses of running tasks are not counted again.
+	s.status(status)
+	s.status(status)
+
+	app, _ = db.FetchApplication("bb")
+	assert.Equal(t, 1, app.RunningInstances)
+	assert.Equal(t, "RUNNING", app.Status)
 }
 
 func TestStatusSTAGING(t *testing.T) {
@@ -77,11 +133,7 @@ func TestStatusSTAGING(t *testing.T) {
 	srv := httptest.NewServer(m)
 	defer srv.Close()
 
-	bolt, _ := NewBoltStore("/tmp/boltdbtest")
-	defer func() {
-		bolt.Close()
-		os.Remove("/tmp/boltdbtest")
-	}()
+	db := memory.NewMemoryStore()
 
 	app := &types.Application{
 		ID:               "bb",
@@ -90,7 +142,7 @@ func TestStatusSTAGING(t *testing.T) {
 		Instances:        1,
 	}
 
-	bolt.SaveApplication(app)
+	db.SaveApplication(app)
 
 	task := &types.Task{
 		ID:     "aa.bb.cc.dd",
@@ -99,9 +151,9 @@ func TestStatusSTAGING(t *testing.T) {
 		Status: "",
 	}
 
-	bolt.SaveTask(task)
+	db.SaveTask(task)
 
-	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, bolt, "xxxxx", nil, nil)
+	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, db, "xxxxx", nil, nil)
 	ev := &sched.Event{
 		Type: sched.Event_UPDATE.Enum(),
 		Update: &sched.Event_Update{
@@ -131,11 +183,7 @@ func TestStatusSTARTING(t *testing.T) {
 	srv := httptest.NewServer(m)
 	defer srv.Close()
 
-	bolt, _ := NewBoltStore("/tmp/boltdbtest")
-	defer func() {
-		bolt.Close()
-		os.Remove("/tmp/boltdbtest")
-	}()
+	db := memory.NewMemoryStore()
 
 	app := &types.Application{
 		ID:               "bb",
@@ -144,7 +192,7 @@ func TestStatusSTARTING(t *testing.T) {
 		Instances:        1,
 	}
 
-	bolt.SaveApplication(app)
+	db.SaveApplication(app)
 
 	task := &types.Task{
 		ID:     "aa.bb.cc.dd",
@@ -153,9 +201,9 @@ func TestStatusSTARTING(t *testing.T) {
 		Status: "",
 	}
 
-	bolt.SaveTask(task)
+	db.SaveTask(task)
 
-	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, bolt, "xxxxx", nil, nil)
+	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, db, "xxxxx", nil, nil)
 	ev := &sched.Event{
 		Type: sched.Event_UPDATE.Enum(),
 		Update: &sched.Event_Update{
@@ -185,11 +233,7 @@ func TestStatusFINISHED(t *testing.T) {
 	srv := httptest.NewServer(m)
 	defer srv.Close()
 
-	bolt, _ := NewBoltStore("/tmp/boltdbtest")
-	defer func() {
-		bolt.Close()
-		os.Remove("/tmp/boltdbtest")
-	}()
+	db := memory.NewMemoryStore()
 
 	app := &types.Application{
 		ID:               "bb",
@@ -198,7 +242,7 @@ func TestStatusFINISHED(t *testing.T) {
 		Instances:        1,
 	}
 
-	bolt.SaveApplication(app)
+	db.SaveApplication(app)
 
 	task := &types.Task{
 		ID:     "aa.bb.cc.dd",
@@ -207,9 +251,9 @@ func TestStatusFINISHED(t *testing.T) {
 		Status: "",
 	}
 
-	bolt.SaveTask(task)
+	db.SaveTask(task)
 
-	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, bolt, "xxxxx", nil, nil)
+	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, db, "xxxxx", nil, nil)
 	ev := &sched.Event{
 		Type: sched.Event_UPDATE.Enum(),
 		Update: &sched.Event_Update{
@@ -239,11 +283,7 @@ func TestStatusFAILED(t *testing.T) {
 	srv := httptest.NewServer(m)
 	defer srv.Close()
 
-	bolt, _ := NewBoltStore("/tmp/boltdbtest")
-	defer func() {
-		bolt.Close()
-		os.Remove("/tmp/boltdbtest")
-	}()
+	db := memory.NewMemoryStore()
 
 	app := &types.Application{
 		ID:               "bb",
@@ -252,7 +292,7 @@ func TestStatusFAILED(t *testing.T) {
 		Instances:        1,
 	}
 
-	bolt.SaveApplication(app)
+	db.SaveApplication(app)
 
 	task := &types.Task{
 		ID:     "aa.bb.cc.dd",
@@ -261,9 +301,9 @@ func TestStatusFAILED(t *testing.T) {
 		Status: "",
 	}
 
-	bolt.SaveTask(task)
+	db.SaveTask(task)
 
-	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, bolt, "xxxxx", nil, nil)
+	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, db, "xxxxx", nil, nil)
 	ev := &sched.Event{
 		Type: sched.Event_UPDATE.Enum(),
 		Update: &sched.Event_Update{
@@ -280,8 +320,14 @@ func TestStatusFAILED(t *testing.T) {
 			},
 		},
 	}
+	failures := taskFailures.Value("bb", "TASK_FAILED")
+	acks := mesosCallResponses.Value("acknowledge", "202")
+
 	status := ev.GetUpdate().GetStatus()
 	s.status(status)
+
+	assert.Equal(t, failures+1, taskFailures.Value("bb", "TASK_FAILED"))
+	assert.Equal(t, acks+1, mesosCallResponses.Value("acknowledge", "202"))
 }
 
 func TestStatusKILLED(t *testing.T) {
@@ -293,11 +339,7 @@ func TestStatusKILLED(t *testing.T) {
 	srv := httptest.NewServer(m)
 	defer srv.Close()
 
-	bolt, _ := NewBoltStore("/tmp/boltdbtest")
-	defer func() {
-		bolt.Close()
-		os.Remove("/tmp/boltdbtest")
-	}()
+	db := memory.NewMemoryStore()
 
 	app := &types.Application{
 		ID:               "bb",
@@ -306,7 +348,7 @@ func TestStatusKILLED(t *testing.T) {
 		Instances:        1,
 	}
 
-	bolt.SaveApplication(app)
+	db.SaveApplication(app)
 
 	task := &types.Task{
 		ID:     "aa.bb.cc.dd",
@@ -315,9 +357,9 @@ func TestStatusKILLED(t *testing.T) {
 		Status: "",
 	}
 
-	bolt.SaveTask(task)
+	db.SaveTask(task)
 
-	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, bolt, "xxxxx", nil, nil)
+	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, db, "xxxxx", nil, nil)
 	ev := &sched.Event{
 		Type: sched.Event_UPDATE.Enum(),
 		Update: &sched.Event_Update{
@@ -347,11 +389,7 @@ func TestStatusLOST(t *testing.T) {
 	srv := httptest.NewServer(m)
 	defer srv.Close()
 
-	bolt, _ := NewBoltStore("/tmp/boltdbtest")
-	defer func() {
-		bolt.Close()
-		os.Remove("/tmp/boltdbtest")
-	}()
+	db := memory.NewMemoryStore()
 
 	app := &types.Application{
 		ID:               "bb",
@@ -360,7 +398,7 @@ func TestStatusLOST(t *testing.T) {
 		Instances:        1,
 	}
 
-	bolt.SaveApplication(app)
+	db.SaveApplication(app)
 
 	task := &types.Task{
 		ID:     "aa.bb.cc.dd",
@@ -369,9 +407,9 @@ func TestStatusLOST(t *testing.T) {
 		Status: "",
 	}
 
-	bolt.SaveTask(task)
+	db.SaveTask(task)
 
-	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, bolt, "xxxxx", nil, nil)
+	s := NewScheduler(strings.TrimPrefix(srv.URL, "http://"), nil, db, "xxxxx", nil, nil)
 	ev := &sched.Event{
 		Type: sched.Event_UPDATE.Enum(),
 		Update: &sched.Event_Update{
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"sync"
+)
+
+// workQueue runs the operations submitted for the same application one after
+// another, while operations of different applications run in parallel.
+type workQueue struct {
+	mu     sync.Mutex
+	queues map[string][]*work
+}
+
+type work struct {
+	fn   func() error
+	done chan error
+}
+
+func newWorkQueue() *workQueue {
+	return &workQueue{
+		queues: make(map[string][]*work),
+	}
+}
+
+// Submit queues fn for application appId. The returned channel receives
+// the error returned by fn once it has run.
+func (q *workQueue) Submit(appId string, fn func() error) <-chan error {
+	w := &work{
+		fn:   fn,
+		done: make(chan error, 1),
+	}
+
+	q.mu.Lock()
+	queue, running := q.queues[appId]
+	q.queues[appId] = append(queue, w)
+	q.mu.Unlock()
+
+	if !running {
+		go q.run(appId)
+	}
+
+	return w.done
+}
+
+// TrySubmit queues fn for application appId unless operations of appId are
+// queued or running already, it reports whether fn was queued.
+func (q *workQueue) TrySubmit(appId string, fn func() error) (<-chan error, bool) {
+	w := &work{
+		fn:   fn,
+		done: make(chan error, 1),
+	}
+
+	q.mu.Lock()
+	if _, running := q.queues[appId]; running {
+		q.mu.Unlock()
+		return nil, false
+	}
+	q.queues[appId] = []*work{w}
+	q.mu.Unlock()
+
+	go q.run(appId)
+
+	return w.done, true
+}
+
+func (q *workQueue) run(appId string) {
+	for {
+		q.mu.Lock()
+		queue := q.queues[appId]
+		if len(queue) == 0 {
+			delete(q.queues, appId)
+			q.mu.Unlock()
+			return
+		}
+		w := queue[0]
+		q.queues[appId] = queue[1:]
+		q.mu.Unlock()
+
+		w.done <- w.fn()
+	}
+}
+
+// Submit queues an operation on application appId. Operations on the same
+// application never run concurrently.
+func (s *Scheduler) Submit(appId string, fn func() error) <-chan error {
+	return s.works.Submit(appId, fn)
+}
+
+// TrySubmit queues an operation on application appId only if no other
+// operation on it is queued or running, it reports whether it was queued.
+func (s *Scheduler) TrySubmit(appId string, fn func() error) (<-chan error, bool) {
+	return s.works.TrySubmit(appId, fn)
+}
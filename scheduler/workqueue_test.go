@@ -0,0 +1,102 @@
+package scheduler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkQueueSerializesApplication(t *testing.T) {
+	q := newWorkQueue()
+
+	var (
+		mu      sync.Mutex
+		running int
+		overlap bool
+		order   []int
+	)
+
+	var dones []<-chan error
+	for i := 0; i < 5; i++ {
+		i := i
+		dones = append(dones, q.Submit("xxxxx", func() error {
+			mu.Lock()
+			running++
+			if running > 1 {
+				overlap = true
+			}
+			order = append(order, i)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		}))
+	}
+
+	for _, done := range dones {
+		assert.Nil(t, <-done)
+	}
+
+	assert.False(t, overlap)
+	assert.Equal(t, order, []int{0, 1, 2, 3, 4})
+}
+
+func TestWorkQueueRunsApplicationsInParallel(t *testing.T) {
+	q := newWorkQueue()
+
+	block := make(chan struct{})
+	first := q.Submit("xxxxx", func() error {
+		<-block
+		return nil
+	})
+
+	second := q.Submit("yyyyy", func() error {
+		return errors.New("done")
+	})
+
+	assert.NotNil(t, <-second)
+
+	close(block)
+	assert.Nil(t, <-first)
+}
+
+func TestWorkQueueTrySubmitSkipsBusyApplication(t *testing.T) {
+	q := newWorkQueue()
+
+	block := make(chan struct{})
+	first := q.Submit("xxxxx", func() error {
+		<-block
+		return nil
+	})
+
+	_, ok := q.TrySubmit("xxxxx", func() error {
+		t.Fatal("busy application must be skipped")
+		return nil
+	})
+	assert.False(t, ok)
+
+	other, ok := q.TrySubmit("yyyyy", func() error {
+		return errors.New("done")
+	})
+	assert.True(t, ok)
+	assert.NotNil(t, <-other)
+
+	close(block)
+	assert.Nil(t, <-first)
+
+	// the queue of xxxxx is removed once it is drained.
+	for {
+		done, ok := q.TrySubmit("xxxxx", func() error { return nil })
+		if ok {
+			assert.Nil(t, <-done)
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+// Audit appends an entry to the audit log of tx for a change of object made
+// by actor. before and after are summarized with Summarize.
+func Audit(tx Tx, actor, operation, appId, object string, before, after interface{}) error {
+	return tx.AppendAuditEntry(&types.AuditEntry{
+		Time:      time.Now().Unix(),
+		Actor:     actor,
+		Operation: operation,
+		AppID:     appId,
+		Object:    object,
+		Before:    Summarize(before),
+		After:     Summarize(after),
+	})
+}
+
+// NextID returns the id of a record appended after the record with id
+// last, such as an audit entry. Ids are creation times padded to sort in
+// order, the next one is taken if the clock did not move on.
+func NextID(last string) string {
+	id := time.Now().UnixNano()
+	if prev, err := strconv.ParseInt(last, 10, 64); err == nil && prev >= id {
+		id = prev + 1
+	}
+
+	return fmt.Sprintf("%020d", id)
+}
+
+// Summarize describes the state of v recorded by the audit log.
+func Summarize(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *types.Application:
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("status=%s instances=%d running=%d version=%s", v.Status, v.Instances, v.RunningInstances, v.CurrentVersion)
+	case *types.Task:
+		if v == nil {
+			return ""
+		}
+		agent := ""
+		if v.AgentHostname != nil {
+			agent = *v.AgentHostname
+		}
+		return fmt.Sprintf("id=%s status=%s agent=%s", v.ID, v.Status, agent)
+	case *types.Version:
+		if v == nil {
+			return ""
+		}
+		image := ""
+		if v.Container != nil && v.Container.Docker != nil && v.Container.Docker.Image != nil {
+			image = *v.Container.Docker.Image
+		}
+		return fmt.Sprintf("instances=%d image=%s", v.Instances, image)
+	case *types.Check:
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("protocol=%s address=%s:%d", v.Protocol, v.Address, v.Port)
+	case *types.Deployment:
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("type=%s status=%s completed=%d", v.Type, v.Status, v.Completed)
+	case *types.Subscription:
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("url=%s app=%s types=%s", v.URL, v.AppID, strings.Join(v.Types, ","))
+	}
+
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+// TaskChecks returns the checks of the health checks of task, one per
+// health check, followed by those of its readiness checks until the task
+// is ready. Checks over the network whose target is not known yet, a
+// USER network task before it reports its address, are left out.
+func TaskChecks(task *types.Task) []*types.Check {
+	var checks []*types.Check
+	for i, healthCheck := range task.HealthChecks {
+		address, port, err := CheckTarget(task, healthCheck)
+		if err != nil && !isCommand(healthCheck) {
+			logrus.Warnf("Skip %s health check of task %s: %s", healthCheck.Protocol, task.Name, err.Error())
+			continue
+		}
+
+		check := types.Check{
+			ID:          CheckID(task.Name, i),
+			Address:     address,
+			Port:        port,
+			TaskID:      task.Name,
+			AppID:       task.AppId,
+			Protocol:    healthCheck.Protocol,
+			Interval:    int(healthCheck.IntervalSeconds),
+			Timeout:     int(healthCheck.TimeoutSeconds),
+			GracePeriod: int(healthCheck.GracePeriodSeconds),
+			Launched:    launched(task),
+
+			Method:        healthCheck.Method,
+			Headers:       healthCheck.Headers,
+			StatusCodes:   healthCheck.StatusCodes,
+			ExpectedBody:  healthCheck.ExpectedBody,
+			IgnoreHttp1xx: healthCheck.IgnoreHttp1xx,
+		}
+
+		if healthCheck.Command != nil {
+			check.Command = healthCheck.Command
+		}
+
+		if healthCheck.Path != nil {
+			check.Path = *healthCheck.Path
+		}
+
+		if healthCheck.MaxConsecutiveFailures != nil {
+			check.MaxFailures = *healthCheck.MaxConsecutiveFailures
+		}
+
+		checks = append(checks, &check)
+	}
+
+	if task.Ready {
+		return checks
+	}
+
+	for i, readinessCheck := range task.ReadinessChecks {
+		index := readinessCheck.PortIndex
+		address, port, err := CheckTarget(task, &types.HealthCheck{PortIndex: &index})
+		if err != nil {
+			logrus.Warnf("Skip readiness check of task %s: %s", task.Name, err.Error())
+			continue
+		}
+
+		protocol := readinessCheck.Protocol
+		if protocol == "" {
+			protocol = "http"
+		}
+
+		checks = append(checks, &types.Check{
+			ID:          fmt.Sprintf("%s-ready-%d", task.Name, i),
+			Address:     address,
+			Port:        port,
+			TaskID:      task.Name,
+			AppID:       task.AppId,
+			Protocol:    protocol,
+			Path:        readinessCheck.Path,
+			StatusCodes: readinessCheck.StatusCodes,
+			Interval:    int(readinessCheck.IntervalSeconds),
+			Timeout:     int(readinessCheck.TimeoutSeconds),
+			Launched:    launched(task),
+			Readiness:   true,
+		})
+	}
+
+	return checks
+}
+
+// KeepStatus carries the status of previous, the stored check of the same
+// ID, over to check if both belong to the same launch of their task.
+func KeepStatus(check, previous *types.Check) {
+	if previous != nil && previous.Launched == check.Launched {
+		check.Status = previous.Status
+	}
+}
+
+// HasChecks reports whether task has checks to run, health checks or
+// readiness checks which have not passed yet.
+func HasChecks(task *types.Task) bool {
+	return len(task.HealthChecks) != 0 || (len(task.ReadinessChecks) != 0 && !task.Ready)
+}
+
+// CheckID returns the id of the check of the health check at index of the
+// task named taskName. Ids of the checks of a task start with its name.
+func CheckID(taskName string, index int) string {
+	return fmt.Sprintf("%s-%d", taskName, index)
+}
+
+// CheckTarget returns the address and port healthCheck connects to for
+// task. Tasks on a USER network are reached at their container address and
+// the container port of the mapping at portIndex, other tasks at their
+// agent and the host port allocated to that mapping. An explicit port is
+// used as is.
+func CheckTarget(task *types.Task, healthCheck *types.HealthCheck) (string, int, error) {
+	address := ""
+	if task.Network == "USER" {
+		address = task.IPAddress
+	} else if task.AgentHostname != nil {
+		address = *task.AgentHostname
+	}
+
+	if address == "" {
+		return "", 0, fmt.Errorf("Address of task %s unknown", task.Name)
+	}
+
+	if healthCheck.Port != nil && *healthCheck.Port != 0 {
+		return address, *healthCheck.Port, nil
+	}
+
+	index := 0
+	if healthCheck.PortIndex != nil {
+		index = *healthCheck.PortIndex
+	}
+
+	if task.Network == "USER" {
+		if index < 0 || index >= len(task.PortMappings) {
+			return "", 0, fmt.Errorf("Port index %d out of range of %d port mappings", index, len(task.PortMappings))
+		}
+
+		return address, int(task.PortMappings[index].Port), nil
+	}
+
+	if index < 0 || index >= len(task.Ports) {
+		return "", 0, fmt.Errorf("Port index %d out of range of %d host ports", index, len(task.Ports))
+	}
+
+	return address, int(task.Ports[index]), nil
+}
+
+func isCommand(healthCheck *types.HealthCheck) bool {
+	return strings.EqualFold(healthCheck.Protocol, "command")
+}
+
+// launched returns the launch time of task, which task ids start with.
+func launched(task *types.Task) int64 {
+	if nanos, err := strconv.ParseInt(strings.SplitN(task.ID, "-", 2)[0], 10, 64); err == nil {
+		return time.Unix(0, nanos).Unix()
+	}
+
+	return time.Now().Unix()
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTarget(t *testing.T) {
+	task := &types.Task{
+		Name:          "0.a.b.c",
+		Network:       "BRIDGE",
+		AgentHostname: proto.String("x.x.x.x"),
+		PortMappings:  []*types.PortMappings{{Port: 80}, {Port: 8080}},
+		Ports:         []uint32{31000, 31001},
+	}
+
+	index := 1
+	address, port, err := CheckTarget(task, &types.HealthCheck{PortIndex: &index})
+	assert.Nil(t, err)
+	assert.Equal(t, "x.x.x.x", address)
+	assert.Equal(t, 31001, port)
+
+	explicit := 9000
+	_, port, _ = CheckTarget(task, &types.HealthCheck{Port: &explicit, PortIndex: &index})
+	assert.Equal(t, 9000, port)
+
+	index = 2
+	_, _, err = CheckTarget(task, &types.HealthCheck{PortIndex: &index})
+	assert.NotNil(t, err)
+
+	task.Network = "USER"
+	index = 1
+	_, _, err = CheckTarget(task, &types.HealthCheck{PortIndex: &index})
+	assert.NotNil(t, err)
+
+	task.IPAddress = "10.0.0.2"
+	address, port, err = CheckTarget(task, &types.HealthCheck{PortIndex: &index})
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.2", address)
+	assert.Equal(t, 8080, port)
+}
+
+func TestTaskChecks(t *testing.T) {
+	index := 1
+	task := &types.Task{
+		ID:            "1500000000000000000-0.a.b.c",
+		Name:          "0.a.b.c",
+		AppId:         "a",
+		Network:       "USER",
+		AgentHostname: proto.String("x.x.x.x"),
+		PortMappings:  []*types.PortMappings{{Port: 80}, {Port: 8080}},
+		HealthChecks: []*types.HealthCheck{
+			{Protocol: "HTTP", PortIndex: &index, GracePeriodSeconds: 300},
+			{Protocol: "COMMAND", Command: &types.Command{Value: "true"}},
+		},
+	}
+
+	// Network checks wait for the address of a USER network task.
+	checks := TaskChecks(task)
+	assert.Len(t, checks, 1)
+	assert.Equal(t, "COMMAND", checks[0].Protocol)
+
+	task.IPAddress = "10.0.0.2"
+	checks = TaskChecks(task)
+	assert.Len(t, checks, 2)
+	assert.Equal(t, "10.0.0.2", checks[0].Address)
+	assert.Equal(t, 8080, checks[0].Port)
+	assert.Equal(t, 300, checks[0].GracePeriod)
+	assert.Equal(t, int64(1500000000), checks[0].Launched)
+}
+
+func TestTaskChecksReadiness(t *testing.T) {
+	task := &types.Task{
+		Name:          "0.a.b.c",
+		Network:       "BRIDGE",
+		AgentHostname: proto.String("x.x.x.x"),
+		PortMappings:  []*types.PortMappings{{Port: 80}},
+		Ports:         []uint32{31000},
+		ReadinessChecks: []*types.ReadinessCheck{
+			{Path: "/ready", StatusCodes: []string{"200"}},
+		},
+	}
+	assert.True(t, HasChecks(task))
+
+	checks := TaskChecks(task)
+	assert.Len(t, checks, 1)
+	assert.Equal(t, "0.a.b.c-ready-0", checks[0].ID)
+	assert.Equal(t, "http", checks[0].Protocol)
+	assert.Equal(t, 31000, checks[0].Port)
+	assert.True(t, checks[0].Readiness)
+
+	// Readiness checks stop once the task is ready.
+	task.Ready = true
+	assert.False(t, HasChecks(task))
+	assert.Len(t, TaskChecks(task), 0)
+}
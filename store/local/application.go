@@ -2,41 +2,63 @@ package boltdb
 
 import (
 	"encoding/json"
+
 	"github.com/Dataman-Cloud/swan/types"
 	"github.com/Sirupsen/logrus"
 )
 
 func (b *BoltStore) SaveApplication(application *types.Application) error {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
+	return b.update(func(tx *boltTx) error {
+		return tx.SaveApplication(application)
+	})
+}
+
+func (b *BoltStore) FetchApplication(appId string) (app *types.Application, err error) {
+	err = b.view(func(tx *boltTx) error {
+		app, err = tx.FetchApplication(appId)
 		return err
-	}
-	defer tx.Rollback()
+	})
 
-	bucket := tx.Bucket([]byte("applications"))
+	return app, err
+}
 
-	data, err := json.Marshal(application)
+func (b *BoltStore) ListApplications() (apps []*types.Application, err error) {
+	err = b.view(func(tx *boltTx) error {
+		apps, err = tx.ListApplications()
+		return err
+	})
+
+	return apps, err
+}
+
+func (b *BoltStore) DeleteApplication(appId string) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.DeleteApplication(appId)
+	})
+}
+
+func (tx *boltTx) SaveApplication(application *types.Application) error {
+	bucket, err := tx.createAppBucket(application.ID)
 	if err != nil {
-		logrus.Errorf("Marshal application failed: %s", err.Error())
 		return err
 	}
 
-	if err := bucket.Put([]byte(application.ID), data); err != nil {
+	data, err := json.Marshal(application)
+	if err != nil {
+		logrus.Errorf("Marshal application failed: %s", err.Error())
 		return err
 	}
 
-	return tx.Commit()
+	return bucket.Put([]byte("app"), data)
 }
 
-func (b *BoltStore) FetchApplication(appId string) (*types.Application, error) {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
-		return nil, err
+func (tx *boltTx) FetchApplication(appId string) (*types.Application, error) {
+	bucket := tx.appBucket(appId)
+	if bucket == nil {
+		return nil, nil
 	}
-	defer tx.Rollback()
 
-	bucket := tx.Bucket([]byte("applications"))
-	data := bucket.Get([]byte(appId))
+	data := bucket.Get([]byte("app"))
 	if data == nil {
 		return nil, nil
 	}
@@ -50,20 +72,20 @@ func (b *BoltStore) FetchApplication(appId string) (*types.Application, error) {
 	return &application, nil
 }
 
-func (b *BoltStore) ListApplications() ([]*types.Application, error) {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	bucket := tx.Bucket([]byte("applications"))
+func (tx *boltTx) ListApplications() ([]*types.Application, error) {
+	bucket := tx.tx.Bucket([]byte("applications"))
 
 	appList := make([]*types.Application, 0)
 
 	if err := bucket.ForEach(func(k, v []byte) error {
+		// Tasks or versions may be saved before their application.
+		data := bucket.Bucket(k).Get([]byte("app"))
+		if data == nil {
+			return nil
+		}
+
 		var app types.Application
-		if err := json.Unmarshal(v, &app); err != nil {
+		if err := json.Unmarshal(data, &app); err != nil {
 			return err
 		}
 		appList = append(appList, &app)
@@ -76,129 +98,27 @@ func (b *BoltStore) ListApplications() ([]*types.Application, error) {
 	return appList, nil
 }
 
-func (b *BoltStore) DeleteApplication(appId string) error {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	bucket := tx.Bucket([]byte("applications"))
-
-	if err := bucket.Delete([]byte(appId)); err != nil {
-		return err
-	}
-
-	return tx.Commit()
-}
-
-func (b *BoltStore) IncreaseApplicationUpdatedInstances(appId string) error {
-	app, err := b.FetchApplication(appId)
-	if err != nil {
-		return err
-	}
-
-	app.UpdatedInstances += 1
-
-	err = b.SaveApplication(app)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (b *BoltStore) IncreaseApplicationInstances(appId string) error {
-	app, err := b.FetchApplication(appId)
-	if err != nil {
-		return err
-	}
-
-	app.Instances += 1
-
-	err = b.SaveApplication(app)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (b *BoltStore) ResetApplicationUpdatedInstances(appId string) error {
-	app, err := b.FetchApplication(appId)
-	if err != nil {
-		return err
-	}
-
-	app.UpdatedInstances = 0
-
-	err = b.SaveApplication(app)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (b *BoltStore) UpdateApplicationStatus(appId, status string) error {
-	app, err := b.FetchApplication(appId)
-	if err != nil {
-		return err
-	}
-
-	app.Status = status
-
-	err = b.SaveApplication(app)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (b *BoltStore) IncreaseApplicationRunningInstances(appId string) error {
-	app, err := b.FetchApplication(appId)
-	if err != nil {
-		return err
-	}
-
-	app.RunningInstances += 1
-
-	err = b.SaveApplication(app)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (b *BoltStore) ReduceApplicationRunningInstances(appId string) error {
-	app, err := b.FetchApplication(appId)
-	if err != nil {
-		return err
-	}
-
-	app.RunningInstances -= 1
-
-	err = b.SaveApplication(app)
-	if err != nil {
-		return err
+// DeleteApplication deletes application together with the tasks and
+// versions left in its bucket.
+func (tx *boltTx) DeleteApplication(appId string) error {
+	bucket := tx.appBucket(appId)
+	if bucket == nil {
+		return nil
 	}
 
-	return nil
-}
-
-func (b *BoltStore) ReduceApplicationInstances(appId string) error {
-	app, err := b.FetchApplication(appId)
-	if err != nil {
+	taskIndex := tx.tx.Bucket([]byte("task_index"))
+	if err := bucket.Bucket([]byte("tasks")).ForEach(func(k, v []byte) error {
+		return taskIndex.Delete(k)
+	}); err != nil {
 		return err
 	}
 
-	app.Instances -= 1
-
-	err = b.SaveApplication(app)
-	if err != nil {
+	versionIndex := tx.tx.Bucket([]byte("version_index"))
+	if err := bucket.Bucket([]byte("versions")).ForEach(func(k, v []byte) error {
+		return versionIndex.Delete(k)
+	}); err != nil {
 		return err
 	}
 
-	return nil
+	return tx.tx.Bucket([]byte("applications")).DeleteBucket([]byte(appId))
 }
@@ -0,0 +1,104 @@
+package boltdb
+
+import (
+	"encoding/json"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+// ListAuditEntries walks the log from the newest entry back, so limited
+// queries stop early.
+func (b *BoltStore) ListAuditEntries(query *types.AuditQuery) ([]*types.AuditEntry, error) {
+	tx, err := b.conn.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var entries []*types.AuditEntry
+
+	c := tx.Bucket([]byte("audit")).Cursor()
+	for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		var entry types.AuditEntry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return nil, err
+		}
+
+		if entry.Time < query.Since {
+			break
+		}
+
+		if !query.Match(&entry) {
+			continue
+		}
+
+		entries = append(entries, &entry)
+		if query.Limit > 0 && len(entries) == query.Limit {
+			break
+		}
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+func (b *BoltStore) PruneAuditEntries(before int64, keep int) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.PruneAuditEntries(before, keep)
+	})
+}
+
+func (tx *boltTx) AppendAuditEntry(entry *types.AuditEntry) error {
+	bucket := tx.tx.Bucket([]byte("audit"))
+
+	if entry.ID == "" {
+		last, _ := bucket.Cursor().Last()
+		entry.ID = store.NextID(string(last))
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(entry.ID), data)
+}
+
+// PruneAuditEntries deletes the entries older than before, and the oldest
+// ones beyond the newest keep entries unless keep is zero.
+func (tx *boltTx) PruneAuditEntries(before int64, keep int) error {
+	bucket := tx.tx.Bucket([]byte("audit"))
+
+	excess := 0
+	if keep > 0 {
+		bucket.ForEach(func(k, v []byte) error {
+			excess++
+			return nil
+		})
+		excess -= keep
+	}
+
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.First() {
+		var entry types.AuditEntry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return err
+		}
+
+		if entry.Time >= before && excess <= 0 {
+			break
+		}
+
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+
+		excess--
+	}
+
+	return nil
+}
@@ -1,14 +1,27 @@
 package boltdb
 
 import (
+	"fmt"
+	"io"
+
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/boltdb/bolt"
 )
 
+// schemaVersion is the layout of the database written by this version, see
+// migrations for the changes between schemas.
+const schemaVersion = 3
+
 type BoltStore struct {
 	conn *bolt.DB
 	path string
 }
 
+// boltTx implements store.Tx on top of a bolt transaction.
+type boltTx struct {
+	tx *bolt.Tx
+}
+
 func NewBoltStore(path string) (*BoltStore, error) {
 	handle, err := bolt.Open(path, 0600, nil)
 	if err != nil {
@@ -35,6 +48,11 @@ func (b *BoltStore) initialize() error {
 	}
 	defer tx.Rollback()
 
+	version, err := readSchemaVersion(tx)
+	if err != nil {
+		return err
+	}
+
 	// Create all the buckets
 	if _, err := tx.CreateBucketIfNotExists([]byte("swan")); err != nil {
 		return err
@@ -44,11 +62,11 @@ func (b *BoltStore) initialize() error {
 		return err
 	}
 
-	if _, err := tx.CreateBucketIfNotExists([]byte("tasks")); err != nil {
+	if _, err := tx.CreateBucketIfNotExists([]byte("task_index")); err != nil {
 		return err
 	}
 
-	if _, err := tx.CreateBucketIfNotExists([]byte("versions")); err != nil {
+	if _, err := tx.CreateBucketIfNotExists([]byte("version_index")); err != nil {
 		return err
 	}
 
@@ -56,9 +74,114 @@ func (b *BoltStore) initialize() error {
 		return err
 	}
 
+	if _, err := tx.CreateBucketIfNotExists([]byte("deployments")); err != nil {
+		return err
+	}
+
+	if _, err := tx.CreateBucketIfNotExists([]byte("audit")); err != nil {
+		return err
+	}
+
+	if _, err := tx.CreateBucketIfNotExists([]byte("subscriptions")); err != nil {
+		return err
+	}
+
+	if _, err := tx.CreateBucketIfNotExists([]byte("dead_letters")); err != nil {
+		return err
+	}
+
+	if err := migrate(tx, version); err != nil {
+		return err
+	}
+
+	if err := writeSchemaVersion(tx, schemaVersion); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
 func (b *BoltStore) Close() error {
 	return b.conn.Close()
 }
+
+// Update runs fn in a read-write transaction which is committed if fn
+// returns nil and rolled back otherwise.
+func (b *BoltStore) Update(fn func(tx store.Tx) error) error {
+	return b.update(func(tx *boltTx) error {
+		return fn(tx)
+	})
+}
+
+// View runs fn in a read-only transaction, writes made through tx fail.
+func (b *BoltStore) View(fn func(tx store.Tx) error) error {
+	return b.view(func(tx *boltTx) error {
+		return fn(tx)
+	})
+}
+
+// DryRun runs fn in a read-write transaction which is always rolled back.
+func (b *BoltStore) DryRun(fn func(tx store.Tx) error) error {
+	tx, err := b.conn.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return fn(&boltTx{tx: tx})
+}
+
+func (b *BoltStore) update(fn func(tx *boltTx) error) error {
+	return b.conn.Update(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+func (b *BoltStore) view(fn func(tx *boltTx) error) error {
+	return b.conn.View(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+// WriteTo writes a consistent copy of the whole database to w.
+func (b *BoltStore) WriteTo(w io.Writer) (int64, error) {
+	tx, err := b.conn.Begin(false)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	return tx.WriteTo(w)
+}
+
+// appBucket returns the bucket of application, nil if there is none.
+func (tx *boltTx) appBucket(appId string) *bolt.Bucket {
+	if appId == "" {
+		return nil
+	}
+
+	return tx.tx.Bucket([]byte("applications")).Bucket([]byte(appId))
+}
+
+// createAppBucket returns the bucket of application and creates it if
+// needed.
+func (tx *boltTx) createAppBucket(appId string) (*bolt.Bucket, error) {
+	if appId == "" {
+		return nil, fmt.Errorf("Application id is required")
+	}
+
+	bucket, err := tx.tx.Bucket([]byte("applications")).CreateBucketIfNotExists([]byte(appId))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := bucket.CreateBucketIfNotExists([]byte("tasks")); err != nil {
+		return nil, err
+	}
+
+	if _, err := bucket.CreateBucketIfNotExists([]byte("versions")); err != nil {
+		return nil, err
+	}
+
+	return bucket, nil
+}
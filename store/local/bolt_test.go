@@ -1,6 +1,8 @@
 package boltdb
 
 import (
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
 	"github.com/stretchr/testify/assert"
 	"os"
 	"testing"
@@ -16,3 +18,26 @@ func TestNewBoltStore(t *testing.T) {
 		os.Remove("/tmp/xxxx")
 	}()
 }
+
+func TestDryRun(t *testing.T) {
+	bolt, _ := NewBoltStore("/tmp/boltdbtest")
+	defer func() {
+		bolt.Close()
+		os.Remove("/tmp/boltdbtest")
+	}()
+
+	err := bolt.DryRun(func(tx store.Tx) error {
+		if err := tx.SaveApplication(&types.Application{ID: "test"}); err != nil {
+			return err
+		}
+
+		app, _ := tx.FetchApplication("test")
+		assert.NotNil(t, app)
+
+		return nil
+	})
+	assert.Nil(t, err)
+
+	app, _ := bolt.FetchApplication("test")
+	assert.Nil(t, app)
+}
@@ -1,45 +1,68 @@
 package boltdb
 
 import (
+	"bytes"
 	"encoding/json"
+
+	"github.com/Dataman-Cloud/swan/store"
 	"github.com/Dataman-Cloud/swan/types"
 )
 
-func (b *BoltStore) SaveCheck(task *types.Task, port uint32, appId string) error {
-	tx, err := b.conn.Begin(true)
+func (b *BoltStore) SaveCheck(task *types.Task) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.SaveCheck(task)
+	})
+}
+
+func (b *BoltStore) ListChecks() ([]*types.Check, error) {
+	tx, err := b.conn.Begin(false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback()
 
 	bucket := tx.Bucket([]byte("checks"))
 
-	for _, healthCheck := range task.HealthChecks {
-
-		check := types.Check{
-			ID:       task.Name,
-			Address:  *task.AgentHostname,
-			Port:     int(port),
-			TaskID:   task.Name,
-			AppID:    appId,
-			Protocol: healthCheck.Protocol,
-			Interval: int(healthCheck.IntervalSeconds),
-			Timeout:  int(healthCheck.TimeoutSeconds),
+	var checks []*types.Check
+	if err := bucket.ForEach(func(k, v []byte) error {
+		var check types.Check
+		if err := json.Unmarshal(v, &check); err != nil {
+			return err
 		}
+		checks = append(checks, &check)
 
-		if healthCheck.Command != nil {
-			check.Command = healthCheck.Command
-		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
 
-		if healthCheck.Path != nil {
-			check.Path = *healthCheck.Path
-		}
+	return checks, nil
+}
 
-		if healthCheck.MaxConsecutiveFailures != nil {
-			check.MaxFailures = *healthCheck.MaxConsecutiveFailures
-		}
+func (b *BoltStore) DeleteCheck(taskId string) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.DeleteCheck(taskId)
+	})
+}
+
+// SaveCheck replaces the checks of task with those of its health checks.
+// Checks of the same launch keep their statuses.
+func (tx *boltTx) SaveCheck(task *types.Task) error {
+	previous, err := tx.taskChecks(task.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.DeleteCheck(task.Name); err != nil {
+		return err
+	}
+
+	bucket := tx.tx.Bucket([]byte("checks"))
+
+	for _, check := range store.TaskChecks(task) {
+		store.KeepStatus(check, previous[check.ID])
 
-		data, err := json.Marshal(&check)
+		data, err := json.Marshal(check)
 		if err != nil {
 			return err
 		}
@@ -49,46 +72,72 @@ func (b *BoltStore) SaveCheck(task *types.Task, port uint32, appId string) error
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
 
-func (b *BoltStore) ListChecks() ([]*types.Check, error) {
-	tx, err := b.conn.Begin(true)
+// DeleteCheck deletes the checks of the task named taskId.
+func (tx *boltTx) DeleteCheck(taskId string) error {
+	checks, err := tx.taskChecks(taskId)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer tx.Rollback()
 
-	bucket := tx.Bucket([]byte("checks"))
+	bucket := tx.tx.Bucket([]byte("checks"))
+	for key := range checks {
+		if err := bucket.Delete([]byte(key)); err != nil {
+			return err
+		}
+	}
 
-	var checks []*types.Check
-	if err := bucket.ForEach(func(k, v []byte) error {
+	return nil
+}
+
+// taskChecks returns the checks of the task named taskId by key, the keys
+// start with its name.
+func (tx *boltTx) taskChecks(taskId string) (map[string]*types.Check, error) {
+	bucket := tx.tx.Bucket([]byte("checks"))
+
+	checks := make(map[string]*types.Check)
+	c := bucket.Cursor()
+	for k, v := c.Seek([]byte(taskId)); k != nil && bytes.HasPrefix(k, []byte(taskId)); k, v = c.Next() {
 		var check types.Check
 		if err := json.Unmarshal(v, &check); err != nil {
-			return err
+			return nil, err
 		}
-		checks = append(checks, &check)
 
-		return nil
-	}); err != nil {
-		return nil, err
+		if check.TaskID == taskId || string(k) == taskId {
+			checks[string(k)] = &check
+		}
 	}
 
 	return checks, nil
 }
 
-func (b *BoltStore) DeleteCheck(checkId string) error {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
+// SaveCheckStatus saves the status of check. Checks which are gone or were
+// saved again for a new launch of their task are left alone.
+func (tx *boltTx) SaveCheckStatus(check *types.Check) error {
+	bucket := tx.tx.Bucket([]byte("checks"))
+
+	data := bucket.Get([]byte(check.ID))
+	if data == nil {
+		return nil
+	}
+
+	var stored types.Check
+	if err := json.Unmarshal(data, &stored); err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	bucket := tx.Bucket([]byte("checks"))
+	if stored.Launched != check.Launched {
+		return nil
+	}
+
+	stored.Status = check.Status
 
-	if err := bucket.Delete([]byte(checkId)); err != nil {
+	data, err := json.Marshal(&stored)
+	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	return bucket.Put([]byte(check.ID), data)
 }
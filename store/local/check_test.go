@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/Dataman-Cloud/swan/types"
+	"github.com/boltdb/bolt"
 )
 
 func TestSaveCheck(t *testing.T) {
@@ -18,7 +19,9 @@ func TestSaveCheck(t *testing.T) {
 
 	task := &types.Task{
 		Name:          "xxxxxx",
+		AppId:         "abc",
 		AgentHostname: proto.String("x.x.x.x"),
+		Ports:         []uint32{8080},
 		HealthChecks: []*types.HealthCheck{
 			&types.HealthCheck{
 				Protocol:        "http",
@@ -33,13 +36,33 @@ func TestSaveCheck(t *testing.T) {
 		},
 	}
 
-	bolt.SaveCheck(task, 8080, "abc")
+	bolt.SaveCheck(task)
 
 	checks, _ := bolt.ListChecks()
 	assert.Equal(t, checks[0].Port, 8080)
-	assert.Equal(t, checks[0].ID, "xxxxxx")
+	assert.Equal(t, checks[0].ID, "xxxxxx-0")
 
 	bolt.DeleteCheck("xxxxxx")
 	checks, _ = bolt.ListChecks()
 	assert.Equal(t, len(checks), 0)
 }
+
+func TestDeleteLegacyCheck(t *testing.T) {
+	store, _ := NewBoltStore("/tmp/boltdbtest")
+	defer func() {
+		store.Close()
+		os.Remove("/tmp/boltdbtest")
+	}()
+
+	// Checks were once keyed by the name of their task.
+	store.conn.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("checks")).Put([]byte("xxxxxx"), []byte(`{"id":"xxxxxx","task_id":"xxxxxx"}`))
+	})
+
+	checks, _ := store.ListChecks()
+	assert.Equal(t, len(checks), 1)
+
+	store.DeleteCheck("xxxxxx")
+	checks, _ = store.ListChecks()
+	assert.Equal(t, len(checks), 0)
+}
@@ -0,0 +1,89 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+func (b *BoltStore) SaveDeployment(deployment *types.Deployment) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.SaveDeployment(deployment)
+	})
+}
+
+func (b *BoltStore) FetchDeployment(deploymentId string) (deployment *types.Deployment, err error) {
+	err = b.view(func(tx *boltTx) error {
+		deployment, err = tx.FetchDeployment(deploymentId)
+		return err
+	})
+
+	return deployment, err
+}
+
+func (b *BoltStore) ListDeployments(appId string) ([]*types.Deployment, error) {
+	tx, err := b.conn.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	bucket := tx.Bucket([]byte("deployments"))
+
+	var deployments []*types.Deployment
+	if err := bucket.ForEach(func(k, v []byte) error {
+		var deployment types.Deployment
+		if err := json.Unmarshal(v, &deployment); err != nil {
+			return err
+		}
+		if deployment.AppID == appId {
+			deployments = append(deployments, &deployment)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return deployments, nil
+}
+
+func (b *BoltStore) DeleteDeployment(deploymentId string) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.DeleteDeployment(deploymentId)
+	})
+}
+
+func (tx *boltTx) SaveDeployment(deployment *types.Deployment) error {
+	bucket := tx.tx.Bucket([]byte("deployments"))
+
+	data, err := json.Marshal(deployment)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(deployment.ID), data)
+}
+
+func (tx *boltTx) FetchDeployment(deploymentId string) (*types.Deployment, error) {
+	bucket := tx.tx.Bucket([]byte("deployments"))
+
+	data := bucket.Get([]byte(deploymentId))
+	if data == nil {
+		return nil, errors.New("Not Found")
+	}
+
+	var deployment types.Deployment
+	if err := json.Unmarshal(data, &deployment); err != nil {
+		return nil, err
+	}
+
+	return &deployment, nil
+}
+
+func (tx *boltTx) DeleteDeployment(deploymentId string) error {
+	bucket := tx.tx.Bucket([]byte("deployments"))
+
+	return bucket.Delete([]byte(deploymentId))
+}
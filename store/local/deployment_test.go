@@ -0,0 +1,56 @@
+package boltdb
+
+import (
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestSaveDeployment(t *testing.T) {
+	bolt, _ := NewBoltStore("/tmp/boltdbtest")
+	defer func() {
+		bolt.Close()
+		os.Remove("/tmp/boltdbtest")
+	}()
+
+	deployment := &types.Deployment{
+		ID:     "1",
+		AppID:  "xxxxxx",
+		Type:   "UPDATE",
+		Status: "RUNNING",
+	}
+
+	bolt.SaveDeployment(deployment)
+
+	deployment.Completed = 2
+	bolt.SaveDeployment(deployment)
+
+	deployment, _ = bolt.FetchDeployment("1")
+	assert.Equal(t, deployment.Completed, 2)
+
+	deployment, err := bolt.FetchDeployment("2")
+	assert.Nil(t, deployment)
+	assert.NotNil(t, err)
+}
+
+func TestListDeployments(t *testing.T) {
+	bolt, _ := NewBoltStore("/tmp/boltdbtest")
+	defer func() {
+		bolt.Close()
+		os.Remove("/tmp/boltdbtest")
+	}()
+
+	bolt.SaveDeployment(&types.Deployment{ID: "1", AppID: "xxxxxx"})
+	bolt.SaveDeployment(&types.Deployment{ID: "2", AppID: "xxxxxx"})
+	bolt.SaveDeployment(&types.Deployment{ID: "3", AppID: "yyyyyy"})
+
+	deployments, _ := bolt.ListDeployments("xxxxxx")
+	assert.Equal(t, len(deployments), 2)
+
+	bolt.DeleteDeployment("1")
+
+	deployments, _ = bolt.ListDeployments("xxxxxx")
+	assert.Equal(t, len(deployments), 1)
+	assert.Equal(t, deployments[0].ID, "2")
+}
@@ -0,0 +1,219 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+	"github.com/boltdb/bolt"
+)
+
+// migration upgrades a database from the previous schema to version.
+// Migrations must be idempotent, running one on data it already upgraded
+// changes nothing.
+type migration struct {
+	version     int
+	description string
+	migrate     func(tx *bolt.Tx) error
+}
+
+// migrations is the ordered list of schema changes, the version of the last
+// one is schemaVersion. New migrations are appended.
+var migrations = []migration{
+	{2, "move applications, tasks and versions into per-application buckets", migrateAppBuckets},
+	{3, "store the agent id of tasks as a plain string", migrateAgentIds},
+}
+
+// migrate brings a database of schema version up to schemaVersion. Every
+// step is recorded in the swan bucket, the caller commits all of them
+// together.
+func migrate(tx *bolt.Tx, version int) error {
+	if version > schemaVersion {
+		return fmt.Errorf("Database schema %d is newer than the supported schema %d", version, schemaVersion)
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+
+		logrus.Infof("Migrate database to schema %d: %s", m.version, m.description)
+		if err := m.migrate(tx); err != nil {
+			return fmt.Errorf("Migrate database to schema %d failed: %s", m.version, err.Error())
+		}
+
+		if err := writeSchemaVersion(tx, m.version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readSchemaVersion returns the schema the database was written with. A new
+// database has the current schema, one written before schemas were recorded
+// has schema 1.
+func readSchemaVersion(tx *bolt.Tx) (int, error) {
+	bucket := tx.Bucket([]byte("swan"))
+	if bucket == nil {
+		return schemaVersion, nil
+	}
+
+	val := bucket.Get([]byte("schemaVersion"))
+	if val == nil {
+		return 1, nil
+	}
+
+	return strconv.Atoi(string(val))
+}
+
+func writeSchemaVersion(tx *bolt.Tx, version int) error {
+	return tx.Bucket([]byte("swan")).Put([]byte("schemaVersion"), []byte(strconv.Itoa(version)))
+}
+
+// record is a key and value copied out of a bucket, so the bucket can be
+// changed while the records are processed.
+type record struct {
+	key   []byte
+	value []byte
+}
+
+// records copies the values of bucket, nested buckets are skipped.
+func records(bucket *bolt.Bucket) ([]record, error) {
+	var list []record
+	err := bucket.ForEach(func(k, v []byte) error {
+		if v != nil {
+			list = append(list, record{
+				key:   append([]byte(nil), k...),
+				value: append([]byte(nil), v...),
+			})
+		}
+
+		return nil
+	})
+
+	return list, err
+}
+
+// migrateAppBuckets moves the flat applications, tasks and versions buckets
+// of schema 1 into one bucket per application.
+func migrateAppBuckets(tx *bolt.Tx) error {
+	btx := &boltTx{tx: tx}
+
+	apps := tx.Bucket([]byte("applications"))
+	appRecords, err := records(apps)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range appRecords {
+		if err := apps.Delete(r.key); err != nil {
+			return err
+		}
+
+		bucket, err := btx.createAppBucket(string(r.key))
+		if err != nil {
+			return err
+		}
+
+		if err := bucket.Put([]byte("app"), r.value); err != nil {
+			return err
+		}
+	}
+
+	if tasks := tx.Bucket([]byte("tasks")); tasks != nil {
+		taskRecords, err := records(tasks)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range taskRecords {
+			var task types.Task
+			if err := json.Unmarshal(r.value, &task); err != nil {
+				return err
+			}
+
+			if task.AppId == "" {
+				logrus.Warnf("Drop task %s without application", string(r.key))
+				continue
+			}
+
+			if err := btx.SaveTask(&task); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.DeleteBucket([]byte("tasks")); err != nil {
+			return err
+		}
+	}
+
+	if versions := tx.Bucket([]byte("versions")); versions != nil {
+		versionRecords, err := records(versions)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range versionRecords {
+			var version types.Version
+			if err := json.Unmarshal(r.value, &version); err != nil {
+				return err
+			}
+
+			if version.ID == "" {
+				logrus.Warnf("Drop version %s without application", string(r.key))
+				continue
+			}
+
+			if err := btx.PutVersion(string(r.key), &version); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.DeleteBucket([]byte("versions")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateAgentIds rewrites the agent id of tasks, which used to be encoded
+// twice because of a ",string" option on a string field.
+func migrateAgentIds(tx *bolt.Tx) error {
+	btx := &boltTx{tx: tx}
+
+	var appIds []string
+	tx.Bucket([]byte("applications")).ForEach(func(k, v []byte) error {
+		appIds = append(appIds, string(k))
+		return nil
+	})
+
+	for _, appId := range appIds {
+		tasks, err := btx.ListTasks(appId)
+		if err != nil {
+			return err
+		}
+
+		for _, task := range tasks {
+			if task.AgentId == nil || !strings.HasPrefix(*task.AgentId, `"`) {
+				continue
+			}
+
+			agentId, err := strconv.Unquote(*task.AgentId)
+			if err != nil {
+				return err
+			}
+
+			task.AgentId = &agentId
+			if err := btx.SaveTask(task); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFlatLayout writes a database the way swan did before schema versions
+// were recorded.
+func writeFlatLayout(t *testing.T, path string) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	put := func(tx *bolt.Tx, bucket, key string, value interface{}) {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := json.Marshal(value)
+		if err := b.Put([]byte(key), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{"swan", "checks", "deployments"} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+
+		tx.Bucket([]byte("swan")).Put([]byte("frameworkId"), []byte("xxx-yyy-zzz"))
+
+		put(tx, "applications", "test", &types.Application{ID: "test", Name: "testapp", Instances: 2})
+		put(tx, "tasks", "0.test.default.cluster", &types.Task{ID: "1-0.test.default.cluster", Name: "0.test.default.cluster", AppId: "test"})
+		// Agent ids used to be encoded twice.
+		put(tx, "tasks", "1.test.default.cluster", json.RawMessage(`{"id":"1-1.test.default.cluster","name":"1.test.default.cluster","app_id":"test","agent_id":"\"agent-1\""}`))
+		put(tx, "versions", "1000", &types.Version{ID: "test", Instances: 2})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateFlatLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swan-bolt")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "bolt.db")
+	writeFlatLayout(t, path)
+
+	bolt, err := NewBoltStore(path)
+	assert.Nil(t, err)
+	defer bolt.Close()
+
+	id, _ := bolt.FetchFrameworkID()
+	assert.Equal(t, "xxx-yyy-zzz", id)
+
+	apps, _ := bolt.ListApplications()
+	assert.Equal(t, 1, len(apps))
+	assert.Equal(t, 2, apps[0].Instances)
+
+	tasks, _ := bolt.ListTasks("test")
+	assert.Equal(t, 2, len(tasks))
+
+	task, _ := bolt.FetchTask("1.test.default.cluster")
+	assert.Equal(t, "1-1.test.default.cluster", task.ID)
+	assert.Equal(t, "agent-1", *task.AgentId)
+
+	versions, _ := bolt.ListVersions("test")
+	assert.Equal(t, []string{"1000"}, versions)
+
+	version, _ := bolt.FetchVersion("1000")
+	assert.Equal(t, 2, version.Instances)
+}
+
+func TestReopenKeepsLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swan-bolt")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "bolt.db")
+
+	bolt, err := NewBoltStore(path)
+	assert.Nil(t, err)
+	bolt.SaveTask(&types.Task{ID: "xxxyyy", Name: "x.y.z", AppId: "y"})
+	bolt.Close()
+
+	bolt, err = NewBoltStore(path)
+	assert.Nil(t, err)
+	defer bolt.Close()
+
+	task, _ := bolt.FetchTask("x.y.z")
+	assert.Equal(t, "xxxyyy", task.ID)
+}
+
+func TestMigrationsOrdered(t *testing.T) {
+	version := 1
+	for _, m := range migrations {
+		assert.Equal(t, version+1, m.version)
+		version = m.version
+	}
+
+	assert.Equal(t, schemaVersion, version)
+}
+
+func TestMigrateIdempotent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swan-bolt")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "bolt.db")
+	writeFlatLayout(t, path)
+
+	store, err := NewBoltStore(path)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	// Run every migration again on the migrated data.
+	assert.Nil(t, store.conn.Update(func(tx *bolt.Tx) error {
+		return migrate(tx, 1)
+	}))
+
+	tasks, _ := store.ListTasks("test")
+	assert.Equal(t, 2, len(tasks))
+
+	task, _ := store.FetchTask("1.test.default.cluster")
+	assert.Equal(t, "agent-1", *task.AgentId)
+
+	versions, _ := store.ListVersions("test")
+	assert.Equal(t, []string{"1000"}, versions)
+}
+
+func TestRefuseNewerSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swan-bolt")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "bolt.db")
+
+	store, err := NewBoltStore(path)
+	assert.Nil(t, err)
+	store.conn.Update(func(tx *bolt.Tx) error {
+		return writeSchemaVersion(tx, schemaVersion+1)
+	})
+	store.Close()
+
+	_, err = NewBoltStore(path)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,57 @@
+package boltdb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Restore replaces the database at path with the backup read from r. It
+// must run while swan is stopped, a database in use is refused. The
+// replaced database is kept next to it with a .bak suffix.
+func Restore(path string, r io.Reader) error {
+	if _, err := os.Stat(path); err == nil {
+		db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			return fmt.Errorf("Database %s is in use: %s", path, err.Error())
+		}
+		db.Close()
+	}
+
+	tmp := path + ".restore"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Opening the backup checks it and migrates an older schema.
+	store, err := NewBoltStore(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("Invalid backup: %s", err.Error())
+	}
+	store.Close()
+
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, path+".bak"); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,51 @@
+package boltdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swan-bolt")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	source, err := NewBoltStore(filepath.Join(dir, "source.db"))
+	assert.Nil(t, err)
+	source.SaveApplication(&types.Application{ID: "test", Name: "testapp"})
+
+	var backup bytes.Buffer
+	_, err = source.WriteTo(&backup)
+	assert.Nil(t, err)
+
+	path := filepath.Join(dir, "bolt.db")
+	target, err := NewBoltStore(path)
+	assert.Nil(t, err)
+	target.SaveApplication(&types.Application{ID: "other", Name: "otherapp"})
+
+	// The database is refused while it is in use.
+	assert.NotNil(t, Restore(path, bytes.NewReader(backup.Bytes())))
+	target.Close()
+
+	assert.NotNil(t, Restore(path, bytes.NewReader([]byte("garbage"))))
+
+	assert.Nil(t, Restore(path, bytes.NewReader(backup.Bytes())))
+	_, err = os.Stat(path + ".bak")
+	assert.Nil(t, err)
+
+	restored, err := NewBoltStore(path)
+	assert.Nil(t, err)
+	defer restored.Close()
+
+	apps, _ := restored.ListApplications()
+	assert.Equal(t, 1, len(apps))
+	assert.Equal(t, "test", apps[0].ID)
+
+	source.Close()
+}
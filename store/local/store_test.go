@@ -0,0 +1,30 @@
+package boltdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) (store.Store, func()) {
+		dir, err := ioutil.TempDir("", "swan-bolt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bolt, err := NewBoltStore(filepath.Join(dir, "bolt.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return bolt, func() {
+			bolt.Close()
+			os.RemoveAll(dir)
+		}
+	})
+}
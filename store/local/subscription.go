@@ -0,0 +1,153 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+func (b *BoltStore) SaveSubscription(subscription *types.Subscription) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.SaveSubscription(subscription)
+	})
+}
+
+func (b *BoltStore) FetchSubscription(subscriptionId string) (*types.Subscription, error) {
+	tx, err := b.conn.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	data := tx.Bucket([]byte("subscriptions")).Get([]byte(subscriptionId))
+	if data == nil {
+		return nil, errors.New("Not Found")
+	}
+
+	var subscription types.Subscription
+	if err := json.Unmarshal(data, &subscription); err != nil {
+		return nil, err
+	}
+
+	return &subscription, nil
+}
+
+func (b *BoltStore) ListSubscriptions() ([]*types.Subscription, error) {
+	tx, err := b.conn.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	subscriptions := make([]*types.Subscription, 0)
+	if err := tx.Bucket([]byte("subscriptions")).ForEach(func(k, v []byte) error {
+		var subscription types.Subscription
+		if err := json.Unmarshal(v, &subscription); err != nil {
+			return err
+		}
+
+		subscriptions = append(subscriptions, &subscription)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+func (b *BoltStore) DeleteSubscription(subscriptionId string) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.DeleteSubscription(subscriptionId)
+	})
+}
+
+func (b *BoltStore) SaveDeadLetter(letter *types.DeadLetter) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.SaveDeadLetter(letter)
+	})
+}
+
+func (b *BoltStore) ListDeadLetters(subscriptionId string) ([]*types.DeadLetter, error) {
+	tx, err := b.conn.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	letters := make([]*types.DeadLetter, 0)
+	if err := tx.Bucket([]byte("dead_letters")).ForEach(func(k, v []byte) error {
+		var letter types.DeadLetter
+		if err := json.Unmarshal(v, &letter); err != nil {
+			return err
+		}
+
+		if letter.SubscriptionID == subscriptionId {
+			letters = append(letters, &letter)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return letters, nil
+}
+
+func (b *BoltStore) DeleteDeadLetter(letterId string) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.DeleteDeadLetter(letterId)
+	})
+}
+
+func (tx *boltTx) SaveSubscription(subscription *types.Subscription) error {
+	data, err := json.Marshal(subscription)
+	if err != nil {
+		return err
+	}
+
+	return tx.tx.Bucket([]byte("subscriptions")).Put([]byte(subscription.ID), data)
+}
+
+// DeleteSubscription deletes subscription together with its dead letters.
+func (tx *boltTx) DeleteSubscription(subscriptionId string) error {
+	letters := tx.tx.Bucket([]byte("dead_letters"))
+
+	var keys [][]byte
+	if err := letters.ForEach(func(k, v []byte) error {
+		var letter types.DeadLetter
+		if err := json.Unmarshal(v, &letter); err != nil {
+			return err
+		}
+
+		if letter.SubscriptionID == subscriptionId {
+			keys = append(keys, k)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := letters.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return tx.tx.Bucket([]byte("subscriptions")).Delete([]byte(subscriptionId))
+}
+
+func (tx *boltTx) SaveDeadLetter(letter *types.DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	return tx.tx.Bucket([]byte("dead_letters")).Put([]byte(letter.ID), data)
+}
+
+func (tx *boltTx) DeleteDeadLetter(letterId string) error {
+	return tx.tx.Bucket([]byte("dead_letters")).Delete([]byte(letterId))
+}
@@ -9,13 +9,40 @@ import (
 )
 
 func (b *BoltStore) SaveTask(task *types.Task) error {
-	tx, err := b.conn.Begin(true)
+	return b.update(func(tx *boltTx) error {
+		return tx.SaveTask(task)
+	})
+}
+
+func (b *BoltStore) ListTasks(applicationId string) (tasks []*types.Task, err error) {
+	err = b.view(func(tx *boltTx) error {
+		tasks, err = tx.ListTasks(applicationId)
+		return err
+	})
+
+	return tasks, err
+}
+
+func (b *BoltStore) DeleteTask(taskId string) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.DeleteTask(taskId)
+	})
+}
+
+func (b *BoltStore) FetchTask(taskId string) (task *types.Task, err error) {
+	err = b.view(func(tx *boltTx) error {
+		task, err = tx.FetchTask(taskId)
+		return err
+	})
+
+	return task, err
+}
+
+func (tx *boltTx) SaveTask(task *types.Task) error {
+	bucket, err := tx.createAppBucket(task.AppId)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
-
-	bucket := tx.Bucket([]byte("tasks"))
 
 	data, err := json.Marshal(task)
 	if err != nil {
@@ -23,92 +50,65 @@ func (b *BoltStore) SaveTask(task *types.Task) error {
 		return err
 	}
 
-	if err := bucket.Put([]byte(task.Name), data); err != nil {
+	if err := bucket.Bucket([]byte("tasks")).Put([]byte(task.Name), data); err != nil {
 		return err
 	}
 
-	return tx.Commit()
-
+	return tx.tx.Bucket([]byte("task_index")).Put([]byte(task.Name), []byte(task.AppId))
 }
 
-func (b *BoltStore) ListTasks(applicationId string) ([]*types.Task, error) {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
-		return nil, err
+func (tx *boltTx) ListTasks(applicationId string) ([]*types.Task, error) {
+	bucket := tx.appBucket(applicationId)
+	if bucket == nil {
+		return nil, nil
 	}
-	defer tx.Rollback()
-
-	bucket := tx.Bucket([]byte("tasks"))
 
 	var tasksList []*types.Task
 
-	bucket.ForEach(func(k, v []byte) error {
+	if err := bucket.Bucket([]byte("tasks")).ForEach(func(k, v []byte) error {
 		var task types.Task
 		if err := json.Unmarshal(v, &task); err != nil {
 			return err
 		}
-		if task.AppId == applicationId {
-			tasksList = append(tasksList, &task)
-		}
+		tasksList = append(tasksList, &task)
 
 		return nil
-	})
+	}); err != nil {
+		return nil, err
+	}
 
 	return tasksList, nil
 }
 
-func (b *BoltStore) DeleteApplicationTasks(appId string) error {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	bucket := tx.Bucket([]byte("tasks"))
-
-	bucket.ForEach(func(k, v []byte) error {
-		var task types.Task
-		if err := json.Unmarshal(v, &task); err != nil {
-			return err
-		}
-		if task.AppId == appId {
-			if err := bucket.Delete([]byte(task.Name)); err != nil {
-				return err
-			}
-		}
+func (tx *boltTx) DeleteTask(taskId string) error {
+	index := tx.tx.Bucket([]byte("task_index"))
 
+	appId := index.Get([]byte(taskId))
+	if appId == nil {
 		return nil
-	})
-
-	return tx.Commit()
-}
-
-func (b *BoltStore) DeleteTask(taskId string) error {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
-		return err
 	}
-	defer tx.Rollback()
-
-	bucket := tx.Bucket([]byte("tasks"))
 
-	if err := bucket.Delete([]byte(taskId)); err != nil {
-		return err
+	if bucket := tx.appBucket(string(appId)); bucket != nil {
+		if err := bucket.Bucket([]byte("tasks")).Delete([]byte(taskId)); err != nil {
+			return err
+		}
 	}
 
-	return tx.Commit()
+	return index.Delete([]byte(taskId))
 }
 
-func (b *BoltStore) FetchTask(taskId string) (*types.Task, error) {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
-		return nil, err
+func (tx *boltTx) FetchTask(taskId string) (*types.Task, error) {
+	appId := tx.tx.Bucket([]byte("task_index")).Get([]byte(taskId))
+	if appId == nil {
+		return nil, errors.New("Not Found")
 	}
-	defer tx.Rollback()
 
-	bucket := tx.Bucket([]byte("tasks"))
+	bucket := tx.appBucket(string(appId))
+	if bucket == nil {
+		return nil, errors.New("Not Found")
+	}
 
-	data := bucket.Get([]byte(taskId))
+	data := bucket.Bucket([]byte("tasks")).Get([]byte(taskId))
 	if data == nil {
 		return nil, errors.New("Not Found")
 	}
@@ -120,22 +120,3 @@ func (b *BoltStore) FetchTask(taskId string) (*types.Task, error) {
 
 	return &task, nil
 }
-
-func (b *BoltStore) UpdateTaskStatus(taskId string, status string) error {
-	task, err := b.FetchTask(taskId)
-	if err != nil {
-		return err
-	}
-
-	if task == nil {
-		return errors.New("task not found")
-	}
-
-	task.Status = status
-
-	if err := b.SaveTask(task); err != nil {
-		return err
-	}
-
-	return nil
-}
@@ -15,8 +15,9 @@ func TestSaveTask(t *testing.T) {
 	}()
 
 	task := &types.Task{
-		ID:   "xxxyyy",
-		Name: "x.y.z",
+		ID:    "xxxyyy",
+		Name:  "x.y.z",
+		AppId: "y",
 	}
 
 	bolt.SaveTask(task)
@@ -84,50 +85,3 @@ func TestDeleteTask(t *testing.T) {
 	task, _ := bolt.FetchTask("xxxyyy")
 	assert.Nil(t, task)
 }
-
-func TestUpdateTaskStatus(t *testing.T) {
-	bolt, _ := NewBoltStore("/tmp/boltdbtest")
-	defer func() {
-		bolt.Close()
-		os.Remove("/tmp/boltdbtest")
-	}()
-
-	task1 := &types.Task{
-		ID:     "xxxyyy",
-		Name:   "x.y.z",
-		Status: "RUNNING",
-	}
-
-	bolt.SaveTask(task1)
-
-	bolt.UpdateTaskStatus("x.y.z", "FAILED")
-
-	task, _ := bolt.FetchTask("x.y.z")
-	assert.Equal(t, task.Status, "FAILED")
-
-	err := bolt.UpdateTaskStatus("x.y.z.m.n", "RUNNING")
-	assert.NotNil(t, err)
-}
-
-func TestDeleteApplicationTasks(t *testing.T) {
-	bolt, _ := NewBoltStore("/tmp/boltdbtest")
-	defer func() {
-		bolt.Close()
-		os.Remove("/tmp/boltdbtest")
-	}()
-
-	task := &types.Task{
-		ID:     "xxxyyy",
-		Name:   "x.y.z",
-		AppId:  "testapp",
-		Status: "RUNNING",
-	}
-
-	bolt.SaveTask(task)
-
-	bolt.DeleteApplicationTasks("testapp")
-
-	tasks, _ := bolt.ListTasks("testapp")
-
-	assert.Equal(t, len(tasks), 0)
-}
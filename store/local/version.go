@@ -10,62 +10,102 @@ import (
 )
 
 func (b *BoltStore) SaveVersion(version *types.Version) error {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
+	return b.update(func(tx *boltTx) error {
+		return tx.SaveVersion(version)
+	})
+}
+
+// PutVersion saves version under the given version id.
+func (b *BoltStore) PutVersion(versionId string, version *types.Version) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.PutVersion(versionId, version)
+	})
+}
+
+func (b *BoltStore) ListVersions(appId string) (versions []string, err error) {
+	err = b.view(func(tx *boltTx) error {
+		versions, err = tx.ListVersions(appId)
+		return err
+	})
+
+	return versions, err
+}
+
+func (b *BoltStore) FetchVersion(versionId string) (version *types.Version, err error) {
+	err = b.view(func(tx *boltTx) error {
+		version, err = tx.FetchVersion(versionId)
 		return err
+	})
+
+	return version, err
+}
+
+func (b *BoltStore) DeleteVersion(versionId string) error {
+	return b.update(func(tx *boltTx) error {
+		return tx.DeleteVersion(versionId)
+	})
+}
+
+// SaveVersion saves version under a new version id. Ids are creation times,
+// the next free one is taken if versions are saved within a nanosecond.
+func (tx *boltTx) SaveVersion(version *types.Version) error {
+	index := tx.tx.Bucket([]byte("version_index"))
+
+	id := time.Now().UnixNano()
+	for index.Get([]byte(fmt.Sprintf("%d", id))) != nil {
+		id++
 	}
-	defer tx.Rollback()
 
-	bucket := tx.Bucket([]byte("versions"))
+	return tx.PutVersion(fmt.Sprintf("%d", id), version)
+}
+
+// PutVersion saves version under the given version id.
+func (tx *boltTx) PutVersion(versionId string, version *types.Version) error {
+	bucket, err := tx.createAppBucket(version.ID)
+	if err != nil {
+		return err
+	}
 
 	data, err := json.Marshal(version)
 	if err != nil {
 		return err
 	}
 
-	if err := bucket.Put([]byte(fmt.Sprintf("%d", time.Now().UnixNano())), data); err != nil {
+	if err := bucket.Bucket([]byte("versions")).Put([]byte(versionId), data); err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	return tx.tx.Bucket([]byte("version_index")).Put([]byte(versionId), []byte(version.ID))
 }
 
-func (b *BoltStore) ListVersions(appId string) ([]string, error) {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
-		return nil, err
+func (tx *boltTx) ListVersions(appId string) ([]string, error) {
+	bucket := tx.appBucket(appId)
+	if bucket == nil {
+		return nil, nil
 	}
-	defer tx.Rollback()
-
-	bucket := tx.Bucket([]byte("versions"))
 
 	var versionList []string
 
-	bucket.ForEach(func(k, v []byte) error {
-		var version types.Version
-		if err := json.Unmarshal(v, &version); err != nil {
-			return err
-		}
-		if version.ID == appId {
-			versionList = append(versionList, string(k[:]))
-		}
-
+	bucket.Bucket([]byte("versions")).ForEach(func(k, v []byte) error {
+		versionList = append(versionList, string(k[:]))
 		return nil
 	})
 
 	return versionList, nil
 }
 
-func (b *BoltStore) FetchVersion(versionId string) (*types.Version, error) {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
-		return nil, err
+func (tx *boltTx) FetchVersion(versionId string) (*types.Version, error) {
+	appId := tx.tx.Bucket([]byte("version_index")).Get([]byte(versionId))
+	if appId == nil {
+		return nil, errors.New("Not Found")
 	}
-	defer tx.Rollback()
 
-	bucket := tx.Bucket([]byte("versions"))
+	bucket := tx.appBucket(string(appId))
+	if bucket == nil {
+		return nil, errors.New("Not Found")
+	}
 
-	data := bucket.Get([]byte(versionId))
+	data := bucket.Bucket([]byte("versions")).Get([]byte(versionId))
 	if data == nil {
 		return nil, errors.New("Not Found")
 	}
@@ -78,18 +118,19 @@ func (b *BoltStore) FetchVersion(versionId string) (*types.Version, error) {
 	return &version, nil
 }
 
-func (b *BoltStore) DeleteVersion(versionId string) error {
-	tx, err := b.conn.Begin(true)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+func (tx *boltTx) DeleteVersion(versionId string) error {
+	index := tx.tx.Bucket([]byte("version_index"))
 
-	bucket := tx.Bucket([]byte("versions"))
+	appId := index.Get([]byte(versionId))
+	if appId == nil {
+		return nil
+	}
 
-	if err := bucket.Delete([]byte(versionId)); err != nil {
-		return err
+	if bucket := tx.appBucket(string(appId)); bucket != nil {
+		if err := bucket.Bucket([]byte("versions")).Delete([]byte(versionId)); err != nil {
+			return err
+		}
 	}
 
-	return tx.Commit()
+	return index.Delete([]byte(versionId))
 }
@@ -0,0 +1,812 @@
+// Package memory implements store.Store in memory, for tests and ephemeral
+// clusters whose state does not have to survive a restart.
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
+)
+
+// errReadOnly is returned by writes in a View transaction.
+var errReadOnly = errors.New("Transaction is read-only")
+
+// state holds every record encoded as json, like the bolt store does, so
+// callers never share memory with the store.
+type state struct {
+	frameworkId string
+
+	applications map[string][]byte
+	tasks        map[string][]byte
+	versions     map[string][]byte
+	checks       map[string][]byte
+	deployments  map[string][]byte
+
+	subscriptions map[string][]byte
+	deadLetters   map[string][]byte
+
+	// audit is only appended to or replaced, oldest entry first. Clones
+	// share it, entries a discarded transaction appended lie beyond the
+	// length of the original.
+	audit []auditRecord
+}
+
+type auditRecord struct {
+	id   string
+	data []byte
+}
+
+func newState() *state {
+	return &state{
+		applications: make(map[string][]byte),
+		tasks:        make(map[string][]byte),
+		versions:     make(map[string][]byte),
+		checks:       make(map[string][]byte),
+		deployments:  make(map[string][]byte),
+
+		subscriptions: make(map[string][]byte),
+		deadLetters:   make(map[string][]byte),
+	}
+}
+
+// clone copies the maps of s, the records themselves are never changed in
+// place.
+func (s *state) clone() *state {
+	c := &state{
+		frameworkId:  s.frameworkId,
+		applications: make(map[string][]byte, len(s.applications)),
+		tasks:        make(map[string][]byte, len(s.tasks)),
+		versions:     make(map[string][]byte, len(s.versions)),
+		checks:       make(map[string][]byte, len(s.checks)),
+		deployments:  make(map[string][]byte, len(s.deployments)),
+		audit:        s.audit,
+
+		subscriptions: make(map[string][]byte, len(s.subscriptions)),
+		deadLetters:   make(map[string][]byte, len(s.deadLetters)),
+	}
+
+	for k, v := range s.applications {
+		c.applications[k] = v
+	}
+	for k, v := range s.tasks {
+		c.tasks[k] = v
+	}
+	for k, v := range s.versions {
+		c.versions[k] = v
+	}
+	for k, v := range s.checks {
+		c.checks[k] = v
+	}
+	for k, v := range s.deployments {
+		c.deployments[k] = v
+	}
+	for k, v := range s.subscriptions {
+		c.subscriptions[k] = v
+	}
+	for k, v := range s.deadLetters {
+		c.deadLetters[k] = v
+	}
+
+	return c
+}
+
+// MemoryStore is a thread-safe store.Store kept in memory.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	state *state
+}
+
+// NewMemoryStore returns an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		state: newState(),
+	}
+}
+
+// Update runs fn against a copy of the state which replaces the state if fn
+// returns nil.
+func (m *MemoryStore) Update(fn func(tx store.Tx) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &memoryTx{state: m.state.clone(), writable: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	m.state = tx.state
+
+	return nil
+}
+
+// View runs fn against the current state, writes through tx fail.
+func (m *MemoryStore) View(fn func(tx store.Tx) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return fn(&memoryTx{state: m.state})
+}
+
+func (m *MemoryStore) update(fn func(tx *memoryTx) error) error {
+	return m.Update(func(tx store.Tx) error {
+		return fn(tx.(*memoryTx))
+	})
+}
+
+func (m *MemoryStore) view(fn func(tx *memoryTx) error) error {
+	return m.View(func(tx store.Tx) error {
+		return fn(tx.(*memoryTx))
+	})
+}
+
+func (m *MemoryStore) SaveFrameworkID(frameworkId string) error {
+	return m.update(func(tx *memoryTx) error {
+		tx.state.frameworkId = frameworkId
+		return nil
+	})
+}
+
+func (m *MemoryStore) FetchFrameworkID() (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.state.frameworkId, nil
+}
+
+func (m *MemoryStore) HasFrameworkID() (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.state.frameworkId != "", nil
+}
+
+func (m *MemoryStore) SaveApplication(application *types.Application) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.SaveApplication(application)
+	})
+}
+
+func (m *MemoryStore) FetchApplication(appId string) (app *types.Application, err error) {
+	err = m.view(func(tx *memoryTx) error {
+		app, err = tx.FetchApplication(appId)
+		return err
+	})
+
+	return app, err
+}
+
+func (m *MemoryStore) ListApplications() (apps []*types.Application, err error) {
+	err = m.view(func(tx *memoryTx) error {
+		apps, err = tx.ListApplications()
+		return err
+	})
+
+	return apps, err
+}
+
+func (m *MemoryStore) DeleteApplication(appId string) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.DeleteApplication(appId)
+	})
+}
+
+func (m *MemoryStore) SaveTask(task *types.Task) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.SaveTask(task)
+	})
+}
+
+func (m *MemoryStore) ListTasks(appId string) (tasks []*types.Task, err error) {
+	err = m.view(func(tx *memoryTx) error {
+		tasks, err = tx.ListTasks(appId)
+		return err
+	})
+
+	return tasks, err
+}
+
+func (m *MemoryStore) FetchTask(taskId string) (task *types.Task, err error) {
+	err = m.view(func(tx *memoryTx) error {
+		task, err = tx.FetchTask(taskId)
+		return err
+	})
+
+	return task, err
+}
+
+func (m *MemoryStore) DeleteTask(taskId string) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.DeleteTask(taskId)
+	})
+}
+
+func (m *MemoryStore) SaveVersion(version *types.Version) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.SaveVersion(version)
+	})
+}
+
+func (m *MemoryStore) ListVersions(appId string) (versions []string, err error) {
+	err = m.view(func(tx *memoryTx) error {
+		versions, err = tx.ListVersions(appId)
+		return err
+	})
+
+	return versions, err
+}
+
+func (m *MemoryStore) FetchVersion(versionId string) (version *types.Version, err error) {
+	err = m.view(func(tx *memoryTx) error {
+		version, err = tx.FetchVersion(versionId)
+		return err
+	})
+
+	return version, err
+}
+
+func (m *MemoryStore) DeleteVersion(versionId string) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.DeleteVersion(versionId)
+	})
+}
+
+func (m *MemoryStore) SaveCheck(task *types.Task) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.SaveCheck(task)
+	})
+}
+
+func (m *MemoryStore) ListChecks() ([]*types.Check, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var checks []*types.Check
+	for _, key := range sortedKeys(m.state.checks) {
+		var check types.Check
+		if err := json.Unmarshal(m.state.checks[key], &check); err != nil {
+			return nil, err
+		}
+		checks = append(checks, &check)
+	}
+
+	return checks, nil
+}
+
+func (m *MemoryStore) DeleteCheck(taskId string) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.DeleteCheck(taskId)
+	})
+}
+
+func (m *MemoryStore) SaveDeployment(deployment *types.Deployment) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.SaveDeployment(deployment)
+	})
+}
+
+func (m *MemoryStore) FetchDeployment(deploymentId string) (deployment *types.Deployment, err error) {
+	err = m.view(func(tx *memoryTx) error {
+		deployment, err = tx.FetchDeployment(deploymentId)
+		return err
+	})
+
+	return deployment, err
+}
+
+func (m *MemoryStore) ListDeployments(appId string) ([]*types.Deployment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var deployments []*types.Deployment
+	for _, key := range sortedKeys(m.state.deployments) {
+		var deployment types.Deployment
+		if err := json.Unmarshal(m.state.deployments[key], &deployment); err != nil {
+			return nil, err
+		}
+		if deployment.AppID == appId {
+			deployments = append(deployments, &deployment)
+		}
+	}
+
+	return deployments, nil
+}
+
+func (m *MemoryStore) DeleteDeployment(deploymentId string) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.DeleteDeployment(deploymentId)
+	})
+}
+
+func (m *MemoryStore) ListAuditEntries(query *types.AuditQuery) ([]*types.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []*types.AuditEntry
+	for i := len(m.state.audit) - 1; i >= 0; i-- {
+		var entry types.AuditEntry
+		if err := json.Unmarshal(m.state.audit[i].data, &entry); err != nil {
+			return nil, err
+		}
+
+		if entry.Time < query.Since {
+			break
+		}
+
+		if !query.Match(&entry) {
+			continue
+		}
+
+		entries = append(entries, &entry)
+		if query.Limit > 0 && len(entries) == query.Limit {
+			break
+		}
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+func (m *MemoryStore) PruneAuditEntries(before int64, keep int) error {
+	return m.update(func(tx *memoryTx) error {
+		audit := tx.state.audit
+
+		excess := 0
+		if keep > 0 {
+			excess = len(audit) - keep
+		}
+
+		for len(audit) > 0 {
+			var entry types.AuditEntry
+			if err := json.Unmarshal(audit[0].data, &entry); err != nil {
+				return err
+			}
+
+			if entry.Time >= before && excess <= 0 {
+				break
+			}
+
+			audit = audit[1:]
+			excess--
+		}
+
+		tx.state.audit = append([]auditRecord(nil), audit...)
+
+		return nil
+	})
+}
+
+func (m *MemoryStore) SaveSubscription(subscription *types.Subscription) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.SaveSubscription(subscription)
+	})
+}
+
+func (m *MemoryStore) FetchSubscription(subscriptionId string) (*types.Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, ok := m.state.subscriptions[subscriptionId]
+	if !ok {
+		return nil, errors.New("Not Found")
+	}
+
+	var subscription types.Subscription
+	if err := json.Unmarshal(data, &subscription); err != nil {
+		return nil, err
+	}
+
+	return &subscription, nil
+}
+
+func (m *MemoryStore) ListSubscriptions() ([]*types.Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	subscriptions := make([]*types.Subscription, 0)
+	for _, key := range sortedKeys(m.state.subscriptions) {
+		var subscription types.Subscription
+		if err := json.Unmarshal(m.state.subscriptions[key], &subscription); err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, &subscription)
+	}
+
+	return subscriptions, nil
+}
+
+func (m *MemoryStore) DeleteSubscription(subscriptionId string) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.DeleteSubscription(subscriptionId)
+	})
+}
+
+func (m *MemoryStore) SaveDeadLetter(letter *types.DeadLetter) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.SaveDeadLetter(letter)
+	})
+}
+
+func (m *MemoryStore) ListDeadLetters(subscriptionId string) ([]*types.DeadLetter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	letters := make([]*types.DeadLetter, 0)
+	for _, key := range sortedKeys(m.state.deadLetters) {
+		var letter types.DeadLetter
+		if err := json.Unmarshal(m.state.deadLetters[key], &letter); err != nil {
+			return nil, err
+		}
+		if letter.SubscriptionID == subscriptionId {
+			letters = append(letters, &letter)
+		}
+	}
+
+	return letters, nil
+}
+
+func (m *MemoryStore) DeleteDeadLetter(letterId string) error {
+	return m.update(func(tx *memoryTx) error {
+		return tx.DeleteDeadLetter(letterId)
+	})
+}
+
+// memoryTx implements store.Tx on a state.
+type memoryTx struct {
+	state    *state
+	writable bool
+}
+
+func (tx *memoryTx) put(records map[string][]byte, key string, value interface{}) error {
+	if !tx.writable {
+		return errReadOnly
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	records[key] = data
+
+	return nil
+}
+
+func (tx *memoryTx) delete(records map[string][]byte, key string) error {
+	if !tx.writable {
+		return errReadOnly
+	}
+
+	delete(records, key)
+
+	return nil
+}
+
+func (tx *memoryTx) SaveApplication(application *types.Application) error {
+	if application.ID == "" {
+		return fmt.Errorf("Application id is required")
+	}
+
+	return tx.put(tx.state.applications, application.ID, application)
+}
+
+func (tx *memoryTx) FetchApplication(appId string) (*types.Application, error) {
+	data, ok := tx.state.applications[appId]
+	if !ok {
+		return nil, nil
+	}
+
+	var application types.Application
+	if err := json.Unmarshal(data, &application); err != nil {
+		return nil, err
+	}
+
+	return &application, nil
+}
+
+func (tx *memoryTx) ListApplications() ([]*types.Application, error) {
+	appList := make([]*types.Application, 0)
+	for _, key := range sortedKeys(tx.state.applications) {
+		var app types.Application
+		if err := json.Unmarshal(tx.state.applications[key], &app); err != nil {
+			return nil, err
+		}
+		appList = append(appList, &app)
+	}
+
+	return appList, nil
+}
+
+// DeleteApplication deletes application together with the tasks and
+// versions left behind.
+func (tx *memoryTx) DeleteApplication(appId string) error {
+	if err := tx.delete(tx.state.applications, appId); err != nil {
+		return err
+	}
+
+	tasks, err := tx.ListTasks(appId)
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		delete(tx.state.tasks, task.Name)
+	}
+
+	versions, err := tx.ListVersions(appId)
+	if err != nil {
+		return err
+	}
+
+	for _, versionId := range versions {
+		delete(tx.state.versions, versionId)
+	}
+
+	return nil
+}
+
+func (tx *memoryTx) SaveTask(task *types.Task) error {
+	if task.AppId == "" {
+		return fmt.Errorf("Application id is required")
+	}
+
+	return tx.put(tx.state.tasks, task.Name, task)
+}
+
+func (tx *memoryTx) ListTasks(appId string) ([]*types.Task, error) {
+	var tasksList []*types.Task
+	for _, key := range sortedKeys(tx.state.tasks) {
+		var task types.Task
+		if err := json.Unmarshal(tx.state.tasks[key], &task); err != nil {
+			return nil, err
+		}
+		if task.AppId == appId {
+			tasksList = append(tasksList, &task)
+		}
+	}
+
+	return tasksList, nil
+}
+
+func (tx *memoryTx) FetchTask(taskId string) (*types.Task, error) {
+	data, ok := tx.state.tasks[taskId]
+	if !ok {
+		return nil, errors.New("Not Found")
+	}
+
+	var task types.Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, err
+	}
+
+	return &task, nil
+}
+
+func (tx *memoryTx) DeleteTask(taskId string) error {
+	return tx.delete(tx.state.tasks, taskId)
+}
+
+// SaveVersion saves version under a new version id. Ids are creation times,
+// the next free one is taken if versions are saved within a nanosecond.
+func (tx *memoryTx) SaveVersion(version *types.Version) error {
+	if version.ID == "" {
+		return fmt.Errorf("Application id is required")
+	}
+
+	id := time.Now().UnixNano()
+	for {
+		if _, ok := tx.state.versions[fmt.Sprintf("%d", id)]; !ok {
+			break
+		}
+		id++
+	}
+
+	return tx.put(tx.state.versions, fmt.Sprintf("%d", id), version)
+}
+
+func (tx *memoryTx) ListVersions(appId string) ([]string, error) {
+	var versionList []string
+	for _, key := range sortedKeys(tx.state.versions) {
+		var version types.Version
+		if err := json.Unmarshal(tx.state.versions[key], &version); err != nil {
+			return nil, err
+		}
+		if version.ID == appId {
+			versionList = append(versionList, key)
+		}
+	}
+
+	return versionList, nil
+}
+
+func (tx *memoryTx) FetchVersion(versionId string) (*types.Version, error) {
+	data, ok := tx.state.versions[versionId]
+	if !ok {
+		return nil, errors.New("Not Found")
+	}
+
+	var version types.Version
+	if err := json.Unmarshal(data, &version); err != nil {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+func (tx *memoryTx) DeleteVersion(versionId string) error {
+	return tx.delete(tx.state.versions, versionId)
+}
+
+// SaveCheck replaces the checks of task with those of its health checks.
+// Checks of the same launch keep their statuses.
+func (tx *memoryTx) SaveCheck(task *types.Task) error {
+	previous, err := tx.taskChecks(task.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.DeleteCheck(task.Name); err != nil {
+		return err
+	}
+
+	for _, check := range store.TaskChecks(task) {
+		store.KeepStatus(check, previous[check.ID])
+
+		if err := tx.put(tx.state.checks, check.ID, check); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveCheckStatus saves the status of check. Checks which are gone or were
+// saved again for a new launch of their task are left alone.
+func (tx *memoryTx) SaveCheckStatus(check *types.Check) error {
+	data, ok := tx.state.checks[check.ID]
+	if !ok {
+		return nil
+	}
+
+	var stored types.Check
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	if stored.Launched != check.Launched {
+		return nil
+	}
+
+	stored.Status = check.Status
+
+	return tx.put(tx.state.checks, check.ID, &stored)
+}
+
+// DeleteCheck deletes the checks of the task named taskId.
+func (tx *memoryTx) DeleteCheck(taskId string) error {
+	checks, err := tx.taskChecks(taskId)
+	if err != nil {
+		return err
+	}
+
+	for key := range checks {
+		if err := tx.delete(tx.state.checks, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// taskChecks returns the checks of the task named taskId by key.
+func (tx *memoryTx) taskChecks(taskId string) (map[string]*types.Check, error) {
+	checks := make(map[string]*types.Check)
+	for key, data := range tx.state.checks {
+		var check types.Check
+		if err := json.Unmarshal(data, &check); err != nil {
+			return nil, err
+		}
+
+		if check.TaskID == taskId || key == taskId {
+			checks[key] = &check
+		}
+	}
+
+	return checks, nil
+}
+
+func (tx *memoryTx) SaveDeployment(deployment *types.Deployment) error {
+	return tx.put(tx.state.deployments, deployment.ID, deployment)
+}
+
+func (tx *memoryTx) FetchDeployment(deploymentId string) (*types.Deployment, error) {
+	data, ok := tx.state.deployments[deploymentId]
+	if !ok {
+		return nil, errors.New("Not Found")
+	}
+
+	var deployment types.Deployment
+	if err := json.Unmarshal(data, &deployment); err != nil {
+		return nil, err
+	}
+
+	return &deployment, nil
+}
+
+func (tx *memoryTx) DeleteDeployment(deploymentId string) error {
+	return tx.delete(tx.state.deployments, deploymentId)
+}
+
+func (tx *memoryTx) AppendAuditEntry(entry *types.AuditEntry) error {
+	if !tx.writable {
+		return errReadOnly
+	}
+
+	if entry.ID == "" {
+		last := ""
+		if n := len(tx.state.audit); n > 0 {
+			last = tx.state.audit[n-1].id
+		}
+		entry.ID = store.NextID(last)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tx.state.audit = append(tx.state.audit, auditRecord{id: entry.ID, data: data})
+
+	return nil
+}
+
+func (tx *memoryTx) SaveSubscription(subscription *types.Subscription) error {
+	return tx.put(tx.state.subscriptions, subscription.ID, subscription)
+}
+
+// DeleteSubscription deletes subscription together with its dead letters.
+func (tx *memoryTx) DeleteSubscription(subscriptionId string) error {
+	if err := tx.delete(tx.state.subscriptions, subscriptionId); err != nil {
+		return err
+	}
+
+	for key, data := range tx.state.deadLetters {
+		var letter types.DeadLetter
+		if err := json.Unmarshal(data, &letter); err != nil {
+			return err
+		}
+		if letter.SubscriptionID == subscriptionId {
+			delete(tx.state.deadLetters, key)
+		}
+	}
+
+	return nil
+}
+
+func (tx *memoryTx) SaveDeadLetter(letter *types.DeadLetter) error {
+	return tx.put(tx.state.deadLetters, letter.ID, letter)
+}
+
+func (tx *memoryTx) DeleteDeadLetter(letterId string) error {
+	return tx.delete(tx.state.deadLetters, letterId)
+}
+
+// sortedKeys returns the keys of records in the order bolt iterates them.
+func sortedKeys(records map[string][]byte) []string {
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
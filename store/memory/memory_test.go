@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) (store.Store, func()) {
+		return NewMemoryStore(), func() {}
+	})
+}
@@ -0,0 +1,260 @@
+package raftstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Dataman-Cloud/swan/store"
+	. "github.com/Dataman-Cloud/swan/store/local"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/hashicorp/raft"
+)
+
+const (
+	opSaveFrameworkID    = "SaveFrameworkID"
+	opUpdate             = "Update"
+	opSaveApplication    = "SaveApplication"
+	opDeleteApplication  = "DeleteApplication"
+	opSaveTask           = "SaveTask"
+	opDeleteTask         = "DeleteTask"
+	opSaveVersion        = "SaveVersion"
+	opDeleteVersion      = "DeleteVersion"
+	opSaveCheck          = "SaveCheck"
+	opDeleteCheck        = "DeleteCheck"
+	opSaveCheckStatus    = "SaveCheckStatus"
+	opSaveDeployment     = "SaveDeployment"
+	opDeleteDeployment   = "DeleteDeployment"
+	opAppendAuditEntry   = "AppendAuditEntry"
+	opPruneAuditEntries  = "PruneAuditEntries"
+	opSaveSubscription   = "SaveSubscription"
+	opDeleteSubscription = "DeleteSubscription"
+	opSaveDeadLetter     = "SaveDeadLetter"
+	opDeleteDeadLetter   = "DeleteDeadLetter"
+)
+
+// command is a write replicated through the raft log. Everything which is
+// not deterministic, like the id of a version, is decided before the
+// command is applied so every node ends up with the same state.
+type command struct {
+	Op           string              `json:"op"`
+	ID           string              `json:"id,omitempty"`
+	Application  *types.Application  `json:"application,omitempty"`
+	Task         *types.Task         `json:"task,omitempty"`
+	Check        *types.Check        `json:"check,omitempty"`
+	Version      *types.Version      `json:"version,omitempty"`
+	Deployment   *types.Deployment   `json:"deployment,omitempty"`
+	Entry        *types.AuditEntry   `json:"entry,omitempty"`
+	Subscription *types.Subscription `json:"subscription,omitempty"`
+	DeadLetter   *types.DeadLetter   `json:"deadLetter,omitempty"`
+	Time         int64               `json:"time,omitempty"`
+	Keep         int                 `json:"keep,omitempty"`
+	Ops          []json.RawMessage   `json:"ops,omitempty"`
+}
+
+// FSM applies the raft log to a local bolt database.
+type FSM struct {
+	mu    sync.RWMutex
+	local *BoltStore
+	path  string
+}
+
+// NewFSM opens the local bolt database of the state machine.
+func NewFSM(path string) (*FSM, error) {
+	local, err := NewBoltStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FSM{
+		local: local,
+		path:  path,
+	}, nil
+}
+
+// read runs fn against the local database.
+func (f *FSM) read(fn func(*BoltStore) error) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return fn(f.local)
+}
+
+// Apply applies a command of the raft log, the result is the error of the
+// write, if any.
+func (f *FSM) Apply(log *raft.Log) interface{} {
+	var cmd command
+	if err := json.Unmarshal(log.Data, &cmd); err != nil {
+		return err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.apply(&cmd)
+}
+
+func (f *FSM) apply(cmd *command) error {
+	switch cmd.Op {
+	case opSaveFrameworkID:
+		return f.local.SaveFrameworkID(cmd.ID)
+	case opPruneAuditEntries:
+		return f.local.PruneAuditEntries(cmd.Time, cmd.Keep)
+	case opUpdate:
+		return f.local.Update(func(tx store.Tx) error {
+			for _, data := range cmd.Ops {
+				var op command
+				if err := json.Unmarshal(data, &op); err != nil {
+					return err
+				}
+
+				if err := applyTx(tx, &op); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return f.local.Update(func(tx store.Tx) error {
+		return applyTx(tx, cmd)
+	})
+}
+
+// applyTx applies a single write of an update to tx.
+func applyTx(tx store.Tx, cmd *command) error {
+	switch cmd.Op {
+	case opSaveApplication:
+		return tx.SaveApplication(cmd.Application)
+	case opDeleteApplication:
+		return tx.DeleteApplication(cmd.ID)
+	case opSaveTask:
+		return tx.SaveTask(cmd.Task)
+	case opDeleteTask:
+		return tx.DeleteTask(cmd.ID)
+	case opSaveVersion:
+		return putVersion(tx, cmd.ID, cmd.Version)
+	case opDeleteVersion:
+		return tx.DeleteVersion(cmd.ID)
+	case opSaveCheck:
+		return tx.SaveCheck(cmd.Task)
+	case opDeleteCheck:
+		return tx.DeleteCheck(cmd.ID)
+	case opSaveCheckStatus:
+		return tx.SaveCheckStatus(cmd.Check)
+	case opSaveDeployment:
+		return tx.SaveDeployment(cmd.Deployment)
+	case opDeleteDeployment:
+		return tx.DeleteDeployment(cmd.ID)
+	case opAppendAuditEntry:
+		return tx.AppendAuditEntry(cmd.Entry)
+	case opSaveSubscription:
+		return tx.SaveSubscription(cmd.Subscription)
+	case opDeleteSubscription:
+		return tx.DeleteSubscription(cmd.ID)
+	case opSaveDeadLetter:
+		return tx.SaveDeadLetter(cmd.DeadLetter)
+	case opDeleteDeadLetter:
+		return tx.DeleteDeadLetter(cmd.ID)
+	}
+
+	return fmt.Errorf("Unknown store command %s", cmd.Op)
+}
+
+// versionPutter is implemented by the transactions of the local store,
+// which can save a version under a given id.
+type versionPutter interface {
+	PutVersion(string, *types.Version) error
+}
+
+func putVersion(tx store.Tx, versionId string, version *types.Version) error {
+	putter, ok := tx.(versionPutter)
+	if !ok {
+		return fmt.Errorf("Transaction can not put version %s", versionId)
+	}
+
+	return putter.PutVersion(versionId, version)
+}
+
+// Snapshot copies the local database. The copy is taken right away because
+// the log keeps being applied while the snapshot is persisted.
+func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var buf bytes.Buffer
+	if _, err := f.local.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+
+	return &snapshot{data: buf.Bytes()}, nil
+}
+
+// Restore replaces the local database with a snapshot.
+func (f *FSM) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	tmp := f.path + ".restore"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, rc); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.local.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, f.path); err != nil {
+		return err
+	}
+
+	local, err := NewBoltStore(f.path)
+	if err != nil {
+		return err
+	}
+
+	f.local = local
+
+	return nil
+}
+
+// Close closes the local database.
+func (f *FSM) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.local.Close()
+}
+
+type snapshot struct {
+	data []byte
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if _, err := sink.Write(s.data); err != nil {
+		sink.Cancel()
+		return err
+	}
+
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {}
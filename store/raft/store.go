@@ -0,0 +1,410 @@
+package raftstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/store"
+	. "github.com/Dataman-Cloud/swan/store/local"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/hashicorp/raft"
+)
+
+// applyTimeout is how long a write waits to be committed.
+const applyTimeout = 10 * time.Second
+
+// RaftStore is a store whose writes are replicated through raft to the local
+// bolt database of every node. Writes only succeed on the leader, reads are
+// served from the local database.
+type RaftStore struct {
+	// mu serializes the writes of this node, so an update always reads the
+	// state left by the previous one.
+	mu sync.Mutex
+
+	raft *raft.Raft
+	fsm  *FSM
+}
+
+// NewRaftStore returns a store which replicates writes through r, fsm must
+// be the state machine of r.
+func NewRaftStore(r *raft.Raft, fsm *FSM) *RaftStore {
+	return &RaftStore{
+		raft: r,
+		fsm:  fsm,
+	}
+}
+
+func (s *RaftStore) apply(cmd *command) error {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	future := s.raft.Apply(data, applyTimeout)
+	if err := future.Error(); err != nil {
+		return err
+	}
+
+	if err, ok := future.Response().(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// Update runs fn against a copy of the local state which is discarded
+// afterwards. The writes fn made are replicated as a single command, which
+// every node applies in one transaction.
+func (s *RaftStore) Update(fn func(tx store.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := &recorder{}
+	if err := s.fsm.read(func(local *BoltStore) error {
+		return local.DryRun(func(tx store.Tx) error {
+			rec.Tx = tx
+			return fn(rec)
+		})
+	}); err != nil {
+		return err
+	}
+
+	if len(rec.ops) == 0 {
+		return nil
+	}
+
+	return s.apply(&command{Op: opUpdate, Ops: rec.ops})
+}
+
+// View runs fn in a read-only transaction of the local database.
+func (s *RaftStore) View(fn func(tx store.Tx) error) error {
+	return s.fsm.read(func(local *BoltStore) error {
+		return local.View(fn)
+	})
+}
+
+// WriteTo writes a consistent copy of the local database to w.
+func (s *RaftStore) WriteTo(w io.Writer) (n int64, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		n, err = local.WriteTo(w)
+		return err
+	})
+
+	return n, err
+}
+
+// write replicates a single write.
+func (s *RaftStore) write(cmd *command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.apply(cmd)
+}
+
+func (s *RaftStore) SaveFrameworkID(frameworkId string) error {
+	return s.write(&command{Op: opSaveFrameworkID, ID: frameworkId})
+}
+
+func (s *RaftStore) FetchFrameworkID() (frameworkId string, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		frameworkId, err = local.FetchFrameworkID()
+		return err
+	})
+
+	return frameworkId, err
+}
+
+func (s *RaftStore) HasFrameworkID() (exists bool, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		exists, err = local.HasFrameworkID()
+		return err
+	})
+
+	return exists, err
+}
+
+func (s *RaftStore) SaveApplication(application *types.Application) error {
+	return s.write(&command{Op: opSaveApplication, Application: application})
+}
+
+func (s *RaftStore) FetchApplication(appId string) (app *types.Application, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		app, err = local.FetchApplication(appId)
+		return err
+	})
+
+	return app, err
+}
+
+func (s *RaftStore) ListApplications() (apps []*types.Application, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		apps, err = local.ListApplications()
+		return err
+	})
+
+	return apps, err
+}
+
+func (s *RaftStore) DeleteApplication(appId string) error {
+	return s.write(&command{Op: opDeleteApplication, ID: appId})
+}
+
+func (s *RaftStore) SaveTask(task *types.Task) error {
+	return s.write(&command{Op: opSaveTask, Task: task})
+}
+
+func (s *RaftStore) ListTasks(appId string) (tasks []*types.Task, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		tasks, err = local.ListTasks(appId)
+		return err
+	})
+
+	return tasks, err
+}
+
+func (s *RaftStore) FetchTask(taskId string) (task *types.Task, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		task, err = local.FetchTask(taskId)
+		return err
+	})
+
+	return task, err
+}
+
+func (s *RaftStore) DeleteTask(taskId string) error {
+	return s.write(&command{Op: opDeleteTask, ID: taskId})
+}
+
+func (s *RaftStore) SaveVersion(version *types.Version) error {
+	return s.Update(func(tx store.Tx) error {
+		return tx.SaveVersion(version)
+	})
+}
+
+func (s *RaftStore) ListVersions(appId string) (versions []string, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		versions, err = local.ListVersions(appId)
+		return err
+	})
+
+	return versions, err
+}
+
+func (s *RaftStore) FetchVersion(versionId string) (version *types.Version, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		version, err = local.FetchVersion(versionId)
+		return err
+	})
+
+	return version, err
+}
+
+func (s *RaftStore) DeleteVersion(versionId string) error {
+	return s.write(&command{Op: opDeleteVersion, ID: versionId})
+}
+
+func (s *RaftStore) SaveCheck(task *types.Task) error {
+	return s.write(&command{Op: opSaveCheck, ID: task.AppId, Task: task})
+}
+
+func (s *RaftStore) ListChecks() (checks []*types.Check, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		checks, err = local.ListChecks()
+		return err
+	})
+
+	return checks, err
+}
+
+func (s *RaftStore) DeleteCheck(taskId string) error {
+	return s.write(&command{Op: opDeleteCheck, ID: taskId})
+}
+
+func (s *RaftStore) SaveDeployment(deployment *types.Deployment) error {
+	return s.write(&command{Op: opSaveDeployment, Deployment: deployment})
+}
+
+func (s *RaftStore) FetchDeployment(deploymentId string) (deployment *types.Deployment, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		deployment, err = local.FetchDeployment(deploymentId)
+		return err
+	})
+
+	return deployment, err
+}
+
+func (s *RaftStore) ListDeployments(appId string) (deployments []*types.Deployment, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		deployments, err = local.ListDeployments(appId)
+		return err
+	})
+
+	return deployments, err
+}
+
+func (s *RaftStore) DeleteDeployment(deploymentId string) error {
+	return s.write(&command{Op: opDeleteDeployment, ID: deploymentId})
+}
+
+func (s *RaftStore) ListAuditEntries(query *types.AuditQuery) (entries []*types.AuditEntry, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		entries, err = local.ListAuditEntries(query)
+		return err
+	})
+
+	return entries, err
+}
+
+// PruneAuditEntries replicates the limits rather than the entries to delete,
+// every node applies them to the same log.
+func (s *RaftStore) PruneAuditEntries(before int64, keep int) error {
+	return s.write(&command{Op: opPruneAuditEntries, Time: before, Keep: keep})
+}
+
+func (s *RaftStore) SaveSubscription(subscription *types.Subscription) error {
+	return s.write(&command{Op: opSaveSubscription, Subscription: subscription})
+}
+
+func (s *RaftStore) FetchSubscription(subscriptionId string) (subscription *types.Subscription, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		subscription, err = local.FetchSubscription(subscriptionId)
+		return err
+	})
+
+	return subscription, err
+}
+
+func (s *RaftStore) ListSubscriptions() (subscriptions []*types.Subscription, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		subscriptions, err = local.ListSubscriptions()
+		return err
+	})
+
+	return subscriptions, err
+}
+
+func (s *RaftStore) DeleteSubscription(subscriptionId string) error {
+	return s.write(&command{Op: opDeleteSubscription, ID: subscriptionId})
+}
+
+func (s *RaftStore) SaveDeadLetter(letter *types.DeadLetter) error {
+	return s.write(&command{Op: opSaveDeadLetter, DeadLetter: letter})
+}
+
+func (s *RaftStore) ListDeadLetters(subscriptionId string) (letters []*types.DeadLetter, err error) {
+	err = s.fsm.read(func(local *BoltStore) error {
+		letters, err = local.ListDeadLetters(subscriptionId)
+		return err
+	})
+
+	return letters, err
+}
+
+func (s *RaftStore) DeleteDeadLetter(letterId string) error {
+	return s.write(&command{Op: opDeleteDeadLetter, ID: letterId})
+}
+
+// recorder is the transaction handed to the function of an update. Writes
+// go to the discarded local transaction, so later reads of the function see
+// them, and are recorded to be replicated.
+type recorder struct {
+	store.Tx
+
+	ops []json.RawMessage
+}
+
+// record keeps cmd if the write succeeded. It is encoded right away so
+// changes the caller makes after the write are not replicated.
+func (r *recorder) record(cmd *command, err error) error {
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	r.ops = append(r.ops, data)
+
+	return nil
+}
+
+func (r *recorder) SaveApplication(application *types.Application) error {
+	return r.record(&command{Op: opSaveApplication, Application: application}, r.Tx.SaveApplication(application))
+}
+
+func (r *recorder) DeleteApplication(appId string) error {
+	return r.record(&command{Op: opDeleteApplication, ID: appId}, r.Tx.DeleteApplication(appId))
+}
+
+func (r *recorder) SaveTask(task *types.Task) error {
+	return r.record(&command{Op: opSaveTask, Task: task}, r.Tx.SaveTask(task))
+}
+
+func (r *recorder) DeleteTask(taskId string) error {
+	return r.record(&command{Op: opDeleteTask, ID: taskId}, r.Tx.DeleteTask(taskId))
+}
+
+func (r *recorder) SaveVersion(version *types.Version) error {
+	id := time.Now().UnixNano()
+	for {
+		if _, err := r.Tx.FetchVersion(fmt.Sprintf("%d", id)); err != nil {
+			break
+		}
+		id++
+	}
+
+	versionId := fmt.Sprintf("%d", id)
+
+	return r.record(&command{Op: opSaveVersion, ID: versionId, Version: version}, putVersion(r.Tx, versionId, version))
+}
+
+func (r *recorder) DeleteVersion(versionId string) error {
+	return r.record(&command{Op: opDeleteVersion, ID: versionId}, r.Tx.DeleteVersion(versionId))
+}
+
+func (r *recorder) SaveCheck(task *types.Task) error {
+	return r.record(&command{Op: opSaveCheck, ID: task.AppId, Task: task}, r.Tx.SaveCheck(task))
+}
+
+func (r *recorder) SaveCheckStatus(check *types.Check) error {
+	return r.record(&command{Op: opSaveCheckStatus, ID: check.ID, Check: check}, r.Tx.SaveCheckStatus(check))
+}
+
+func (r *recorder) DeleteCheck(taskId string) error {
+	return r.record(&command{Op: opDeleteCheck, ID: taskId}, r.Tx.DeleteCheck(taskId))
+}
+
+func (r *recorder) SaveDeployment(deployment *types.Deployment) error {
+	return r.record(&command{Op: opSaveDeployment, Deployment: deployment}, r.Tx.SaveDeployment(deployment))
+}
+
+func (r *recorder) DeleteDeployment(deploymentId string) error {
+	return r.record(&command{Op: opDeleteDeployment, ID: deploymentId}, r.Tx.DeleteDeployment(deploymentId))
+}
+
+// AppendAuditEntry fills in the id before the entry is recorded, so it is
+// the same on every node.
+func (r *recorder) AppendAuditEntry(entry *types.AuditEntry) error {
+	return r.record(&command{Op: opAppendAuditEntry, Entry: entry}, r.Tx.AppendAuditEntry(entry))
+}
+
+func (r *recorder) SaveSubscription(subscription *types.Subscription) error {
+	return r.record(&command{Op: opSaveSubscription, Subscription: subscription}, r.Tx.SaveSubscription(subscription))
+}
+
+func (r *recorder) DeleteSubscription(subscriptionId string) error {
+	return r.record(&command{Op: opDeleteSubscription, ID: subscriptionId}, r.Tx.DeleteSubscription(subscriptionId))
+}
+
+func (r *recorder) SaveDeadLetter(letter *types.DeadLetter) error {
+	return r.record(&command{Op: opSaveDeadLetter, DeadLetter: letter}, r.Tx.SaveDeadLetter(letter))
+}
+
+func (r *recorder) DeleteDeadLetter(letterId string) error {
+	return r.record(&command{Op: opDeleteDeadLetter, ID: letterId}, r.Tx.DeleteDeadLetter(letterId))
+}
@@ -0,0 +1,219 @@
+package raftstore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/store/storetest"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/assert"
+)
+
+type testNode struct {
+	raft *raft.Raft
+	fsm  *FSM
+}
+
+// newTestCluster starts a cluster of n in-memory raft nodes, each with its
+// own bolt state machine in dir.
+func newTestCluster(t *testing.T, dir string, n int) []*testNode {
+	var servers []raft.Server
+	var transports []*raft.InmemTransport
+	for i := 0; i < n; i++ {
+		addr, transport := raft.NewInmemTransport("")
+		servers = append(servers, raft.Server{ID: raft.ServerID(addr), Address: addr})
+		transports = append(transports, transport)
+	}
+
+	for _, a := range transports {
+		for _, b := range transports {
+			a.Connect(b.LocalAddr(), b)
+		}
+	}
+
+	var nodes []*testNode
+	for i, transport := range transports {
+		conf := raft.DefaultConfig()
+		conf.LocalID = servers[i].ID
+		conf.HeartbeatTimeout = 50 * time.Millisecond
+		conf.ElectionTimeout = 50 * time.Millisecond
+		conf.LeaderLeaseTimeout = 50 * time.Millisecond
+		conf.CommitTimeout = 5 * time.Millisecond
+		conf.LogOutput = ioutil.Discard
+
+		fsm, err := NewFSM(filepath.Join(dir, fmt.Sprintf("state-%d.db", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logs := raft.NewInmemStore()
+		snapshots := raft.NewInmemSnapshotStore()
+		if err := raft.BootstrapCluster(conf, logs, logs, snapshots, transport,
+			raft.Configuration{Servers: servers}); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := raft.NewRaft(conf, fsm, logs, logs, snapshots, transport)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nodes = append(nodes, &testNode{raft: r, fsm: fsm})
+	}
+
+	return nodes
+}
+
+func waitLeader(t *testing.T, nodes []*testNode) *testNode {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, node := range nodes {
+			if node.raft.State() == raft.Leader {
+				return node
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("no leader elected")
+	return nil
+}
+
+func shutdown(nodes []*testNode) {
+	for _, node := range nodes {
+		node.raft.Shutdown().Error()
+		node.fsm.Close()
+	}
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) (store.Store, func()) {
+		dir, err := ioutil.TempDir("", "swan-raft")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nodes := newTestCluster(t, dir, 1)
+		leader := waitLeader(t, nodes)
+
+		return NewRaftStore(leader.raft, leader.fsm), func() {
+			shutdown(nodes)
+			os.RemoveAll(dir)
+		}
+	})
+}
+
+func TestReplication(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swan-raft")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	nodes := newTestCluster(t, dir, 3)
+	defer shutdown(nodes)
+
+	leader := waitLeader(t, nodes)
+	s := NewRaftStore(leader.raft, leader.fsm)
+	assert.Nil(t, s.SaveApplication(&types.Application{ID: "test", Name: "testapp"}))
+	assert.Nil(t, s.Update(func(tx store.Tx) error {
+		app, err := tx.FetchApplication("test")
+		if err != nil {
+			return err
+		}
+
+		app.Status = "RUNNING"
+		if err := tx.SaveApplication(app); err != nil {
+			return err
+		}
+
+		return tx.SaveVersion(&types.Version{ID: "test"})
+	}))
+
+	leaderVersions, _ := s.ListVersions("test")
+	assert.Equal(t, 1, len(leaderVersions))
+
+	for _, node := range nodes {
+		if node == leader {
+			continue
+		}
+
+		// Writes are only accepted by the leader.
+		follower := NewRaftStore(node.raft, node.fsm)
+		assert.NotNil(t, follower.SaveApplication(&types.Application{ID: "other"}))
+
+		var app *types.Application
+		for i := 0; i < 100 && (app == nil || app.Status != "RUNNING"); i++ {
+			app, _ = follower.FetchApplication("test")
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// The application and the version of an update arrive together.
+		if assert.NotNil(t, app) {
+			assert.Equal(t, "RUNNING", app.Status)
+		}
+
+		// Every node holds the version under the same id.
+		versions, _ := follower.ListVersions("test")
+		assert.Equal(t, leaderVersions, versions)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swan-raft")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fsm, err := NewFSM(filepath.Join(dir, "state.db"))
+	assert.Nil(t, err)
+	defer fsm.Close()
+
+	assert.Nil(t, fsm.apply(&command{Op: opSaveApplication, Application: &types.Application{ID: "test"}}))
+
+	snap, err := fsm.Snapshot()
+	assert.Nil(t, err)
+
+	sink := &testSink{dir: dir}
+	assert.Nil(t, snap.Persist(sink))
+
+	restored, err := NewFSM(filepath.Join(dir, "restored.db"))
+	assert.Nil(t, err)
+	defer restored.Close()
+
+	file, err := os.Open(sink.path())
+	assert.Nil(t, err)
+	assert.Nil(t, restored.Restore(file))
+
+	s := &RaftStore{fsm: restored}
+	app, _ := s.FetchApplication("test")
+	assert.NotNil(t, app)
+}
+
+type testSink struct {
+	dir  string
+	file *os.File
+}
+
+func (s *testSink) path() string {
+	return filepath.Join(s.dir, "snapshot")
+}
+
+func (s *testSink) Write(p []byte) (int, error) {
+	if s.file == nil {
+		file, err := os.Create(s.path())
+		if err != nil {
+			return 0, err
+		}
+		s.file = file
+	}
+
+	return s.file.Write(p)
+}
+
+func (s *testSink) Close() error  { return s.file.Close() }
+func (s *testSink) ID() string    { return "test" }
+func (s *testSink) Cancel() error { return s.file.Close() }
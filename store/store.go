@@ -4,8 +4,104 @@ import (
 	"github.com/Dataman-Cloud/swan/types"
 )
 
+// Tx is a transaction over the store. Changes made through it are applied
+// atomically once the function passed to Store.Update returns nil.
+type Tx interface {
+
+	// application
+
+	// fetch application, nil if it does not exist
+	FetchApplication(string) (*types.Application, error)
+
+	// list all applications
+	ListApplications() ([]*types.Application, error)
+
+	// save application
+	SaveApplication(*types.Application) error
+
+	// delete application
+	DeleteApplication(string) error
+
+	// task
+
+	// list all tasks belong to a application
+	ListTasks(string) ([]*types.Task, error)
+
+	// fetch task by name
+	FetchTask(string) (*types.Task, error)
+
+	// save task
+	SaveTask(*types.Task) error
+
+	// delete task by name
+	DeleteTask(string) error
+
+	// version
+
+	// list all versions of a application
+	ListVersions(string) ([]string, error)
+
+	// fetch version by version id
+	FetchVersion(string) (*types.Version, error)
+
+	// save version under a new version id
+	SaveVersion(*types.Version) error
+
+	// delete version by version id
+	DeleteVersion(string) error
+
+	// check
+
+	// save checks of task
+	SaveCheck(*types.Task) error
+
+	// save the status of a check, unless it is gone or belongs to another launch of its task
+	SaveCheckStatus(*types.Check) error
+
+	// delete the checks of a task
+	DeleteCheck(string) error
+
+	// deployment
+
+	// fetch deployment by deployment id
+	FetchDeployment(string) (*types.Deployment, error)
+
+	// save deployment
+	SaveDeployment(*types.Deployment) error
+
+	// delete deployment
+	DeleteDeployment(string) error
+
+	// audit
+
+	// append entry to the audit log, an empty id is filled in
+	AppendAuditEntry(*types.AuditEntry) error
+
+	// subscription
+
+	// save subscription
+	SaveSubscription(*types.Subscription) error
+
+	// delete subscription together with its dead letters
+	DeleteSubscription(string) error
+
+	// save dead letter
+	SaveDeadLetter(*types.DeadLetter) error
+
+	// delete dead letter by dead letter id
+	DeleteDeadLetter(string) error
+}
+
 type Store interface {
 
+	// transaction
+
+	// run fn in a transaction, all changes are discarded if it returns an error
+	Update(fn func(tx Tx) error) error
+
+	// run fn in a read-only transaction, writes through tx fail
+	View(fn func(tx Tx) error) error
+
 	// framework
 
 	// save framework id to db
@@ -31,27 +127,6 @@ type Store interface {
 	// delete application fro db
 	DeleteApplication(string) error
 
-	// increase application updated instances count +1
-	IncreaseApplicationUpdatedInstances(string) error
-
-	// reset application updated instances to zero
-	ResetApplicationUpdatedInstances(string) error
-
-	// increase application instances
-	IncreaseApplicationInstances(string) error
-
-	// reduce application instances
-	ReduceApplicationInstances(string) error
-
-	// update application status
-	UpdateApplicationStatus(string, string) error
-
-	// increase application running instances count
-	IncreaseApplicationRunningInstances(string) error
-
-	// reduce application running instances count
-	ReduceApplicationRunningInstances(string) error
-
 	// task
 
 	// save task in db
@@ -66,9 +141,6 @@ type Store interface {
 	// delete task from db
 	DeleteTask(string) error
 
-	// update task status
-	UpdateTaskStatus(string, string) error
-
 	// version
 
 	// save version to db
@@ -85,12 +157,57 @@ type Store interface {
 
 	// check
 
-	// save check to db
-	SaveCheck(*types.Task, uint32, string) error
+	// save checks of task to db
+	SaveCheck(*types.Task) error
 
 	// list all checks
 	ListChecks() ([]*types.Check, error)
 
-	// delete check from db
+	// delete the checks of a task from db
 	DeleteCheck(string) error
+
+	// deployment
+
+	// save deployment to db
+	SaveDeployment(*types.Deployment) error
+
+	// fetch deployment from db by deployment id
+	FetchDeployment(string) (*types.Deployment, error)
+
+	// list all deployments belong to a application
+	ListDeployments(string) ([]*types.Deployment, error)
+
+	// delete deployment from db
+	DeleteDeployment(string) error
+
+	// audit
+
+	// list audit entries selected by query, oldest first
+	ListAuditEntries(*types.AuditQuery) ([]*types.AuditEntry, error)
+
+	// delete audit entries older than the unix time and all but the newest entries, zero keeps all
+	PruneAuditEntries(int64, int) error
+
+	// subscription
+
+	// save subscription to db
+	SaveSubscription(*types.Subscription) error
+
+	// fetch subscription from db by subscription id
+	FetchSubscription(string) (*types.Subscription, error)
+
+	// list all subscriptions
+	ListSubscriptions() ([]*types.Subscription, error)
+
+	// delete subscription and its dead letters from db
+	DeleteSubscription(string) error
+
+	// save dead letter to db
+	SaveDeadLetter(*types.DeadLetter) error
+
+	// list dead letters of subscription, oldest first
+	ListDeadLetters(string) ([]*types.DeadLetter, error)
+
+	// delete dead letter from db
+	DeleteDeadLetter(string) error
 }
@@ -0,0 +1,585 @@
+// Package storetest holds the conformance tests every store.Store
+// implementation has to pass.
+package storetest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+// Open returns an empty store and a function which releases it.
+type Open func(t *testing.T) (store.Store, func())
+
+// Run runs the behavioral tests against the stores returned by open.
+func Run(t *testing.T, open Open) {
+	tests := []struct {
+		name string
+		test func(*testing.T, store.Store)
+	}{
+		{"FrameworkID", testFrameworkID},
+		{"SaveApplication", testSaveApplication},
+		{"ListApplications", testListApplications},
+		{"DeleteApplication", testDeleteApplication},
+		{"Update", testUpdate},
+		{"UpdateRollback", testUpdateRollback},
+		{"View", testView},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"Missing", testMissing},
+		{"DeleteApplicationData", testDeleteApplicationData},
+		{"SaveTask", testSaveTask},
+		{"ListTasks", testListTasks},
+		{"DeleteTask", testDeleteTask},
+		{"Versions", testVersions},
+		{"Checks", testChecks},
+		{"Deployments", testDeployments},
+		{"Audit", testAudit},
+		{"AuditRollback", testAuditRollback},
+		{"PruneAudit", testPruneAudit},
+		{"Subscriptions", testSubscriptions},
+		{"DeadLetters", testDeadLetters},
+	}
+
+	for _, tt := range tests {
+		test := tt.test
+		t.Run(tt.name, func(t *testing.T) {
+			s, release := open(t)
+			defer release()
+
+			test(t, s)
+		})
+	}
+}
+
+func testFrameworkID(t *testing.T, s store.Store) {
+	exists, _ := s.HasFrameworkID()
+	assert.False(t, exists)
+
+	id, _ := s.FetchFrameworkID()
+	assert.Equal(t, "", id)
+
+	assert.Nil(t, s.SaveFrameworkID("xxx-yyy-zzz"))
+
+	exists, _ = s.HasFrameworkID()
+	assert.True(t, exists)
+
+	id, _ = s.FetchFrameworkID()
+	assert.Equal(t, "xxx-yyy-zzz", id)
+}
+
+func testSaveApplication(t *testing.T, s store.Store) {
+	assert.Nil(t, s.SaveApplication(&types.Application{ID: "test", Name: "testapp"}))
+
+	app, _ := s.FetchApplication("test")
+	assert.Equal(t, "testapp", app.Name)
+}
+
+func testListApplications(t *testing.T, s store.Store) {
+	s.SaveApplication(&types.Application{ID: "test1", Name: "testapp1"})
+	s.SaveApplication(&types.Application{ID: "test2", Name: "testapp2"})
+
+	apps, _ := s.ListApplications()
+	assert.Equal(t, 2, len(apps))
+	assert.Equal(t, "test1", apps[0].ID)
+	assert.Equal(t, "testapp2", apps[1].Name)
+}
+
+func testDeleteApplication(t *testing.T, s store.Store) {
+	s.SaveApplication(&types.Application{ID: "test", Name: "testapp"})
+
+	apps, _ := s.ListApplications()
+	assert.Equal(t, 1, len(apps))
+
+	assert.Nil(t, s.DeleteApplication("test"))
+
+	apps, _ = s.ListApplications()
+	assert.Equal(t, 0, len(apps))
+}
+
+func testUpdate(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Tx) error {
+		if err := tx.SaveApplication(&types.Application{ID: "test", Name: "testapp", Instances: 1}); err != nil {
+			return err
+		}
+
+		return tx.SaveVersion(&types.Version{ID: "test"})
+	})
+	assert.Nil(t, err)
+
+	err = s.Update(func(tx store.Tx) error {
+		app, err := tx.FetchApplication("test")
+		if err != nil {
+			return err
+		}
+
+		app.Instances += 1
+		app.Status = "RUNNING"
+
+		return tx.SaveApplication(app)
+	})
+	assert.Nil(t, err)
+
+	app, _ := s.FetchApplication("test")
+	assert.Equal(t, 2, app.Instances)
+	assert.Equal(t, "RUNNING", app.Status)
+
+	versions, _ := s.ListVersions("test")
+	assert.Equal(t, 1, len(versions))
+}
+
+func testUpdateRollback(t *testing.T, s store.Store) {
+	s.SaveApplication(&types.Application{ID: "test", Name: "testapp", Status: "STAGING"})
+
+	err := s.Update(func(tx store.Tx) error {
+		app, err := tx.FetchApplication("test")
+		if err != nil {
+			return err
+		}
+
+		app.Status = "RUNNING"
+		if err := tx.SaveApplication(app); err != nil {
+			return err
+		}
+
+		if err := tx.SaveTask(&types.Task{ID: "xxxyyy", Name: "x.y.z", AppId: "test"}); err != nil {
+			return err
+		}
+
+		return errors.New("abort")
+	})
+	assert.NotNil(t, err)
+
+	app, _ := s.FetchApplication("test")
+	assert.Equal(t, "STAGING", app.Status)
+
+	task, _ := s.FetchTask("x.y.z")
+	assert.Nil(t, task)
+}
+
+func testView(t *testing.T, s store.Store) {
+	s.SaveApplication(&types.Application{ID: "test", Name: "testapp"})
+	s.SaveTask(&types.Task{ID: "xxxyyy", Name: "x.y.z", AppId: "test"})
+
+	err := s.View(func(tx store.Tx) error {
+		app, err := tx.FetchApplication("test")
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, "testapp", app.Name)
+
+		tasks, err := tx.ListTasks("test")
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, 1, len(tasks))
+
+		// Writes are refused.
+		assert.NotNil(t, tx.SaveApplication(&types.Application{ID: "other"}))
+
+		return nil
+	})
+	assert.Nil(t, err)
+
+	app, _ := s.FetchApplication("other")
+	assert.Nil(t, app)
+}
+
+func testConcurrentUpdates(t *testing.T, s store.Store) {
+	s.SaveApplication(&types.Application{ID: "test", Name: "testapp"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			s.Update(func(tx store.Tx) error {
+				app, err := tx.FetchApplication("test")
+				if err != nil {
+					return err
+				}
+
+				app.Instances += 1
+
+				return tx.SaveApplication(app)
+			})
+		}()
+	}
+	wg.Wait()
+
+	app, _ := s.FetchApplication("test")
+	assert.Equal(t, 20, app.Instances)
+}
+
+// testMissing pins down what every store returns for records which do not
+// exist.
+func testMissing(t *testing.T, s store.Store) {
+	app, err := s.FetchApplication("test")
+	assert.Nil(t, app)
+	assert.Nil(t, err)
+
+	apps, err := s.ListApplications()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(apps))
+
+	task, err := s.FetchTask("x.y.z")
+	assert.Nil(t, task)
+	assert.NotNil(t, err)
+
+	tasks, err := s.ListTasks("test")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(tasks))
+
+	version, err := s.FetchVersion("1000")
+	assert.Nil(t, version)
+	assert.NotNil(t, err)
+
+	versions, err := s.ListVersions("test")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(versions))
+
+	deployment, err := s.FetchDeployment("1")
+	assert.Nil(t, deployment)
+	assert.NotNil(t, err)
+
+	// Deleting what does not exist is not an error.
+	assert.Nil(t, s.DeleteApplication("test"))
+	assert.Nil(t, s.DeleteTask("x.y.z"))
+	assert.Nil(t, s.DeleteVersion("1000"))
+	assert.Nil(t, s.DeleteCheck("x.y.z"))
+	assert.Nil(t, s.DeleteDeployment("1"))
+
+	// Tasks and versions belong to an application.
+	assert.NotNil(t, s.SaveTask(&types.Task{ID: "xxxyyy", Name: "x.y.z"}))
+	assert.NotNil(t, s.SaveVersion(&types.Version{}))
+}
+
+func testDeleteApplicationData(t *testing.T, s store.Store) {
+	s.SaveApplication(&types.Application{ID: "test", Name: "testapp"})
+	s.SaveTask(&types.Task{ID: "xxxyyy", Name: "x.y.z", AppId: "test"})
+	s.SaveVersion(&types.Version{ID: "test"})
+
+	versions, _ := s.ListVersions("test")
+	assert.Equal(t, 1, len(versions))
+
+	assert.Nil(t, s.DeleteApplication("test"))
+
+	// Tasks and versions left behind go with the application.
+	task, _ := s.FetchTask("x.y.z")
+	assert.Nil(t, task)
+
+	version, _ := s.FetchVersion(versions[0])
+	assert.Nil(t, version)
+
+	tasks, _ := s.ListTasks("test")
+	assert.Equal(t, 0, len(tasks))
+}
+
+func testSaveTask(t *testing.T, s store.Store) {
+	task, _ := s.FetchTask("x.y.z")
+	assert.Nil(t, task)
+
+	assert.Nil(t, s.SaveTask(&types.Task{ID: "xxxyyy", Name: "x.y.z", AppId: "y"}))
+
+	task, _ = s.FetchTask("x.y.z")
+	assert.Equal(t, "x.y.z", task.Name)
+	assert.Equal(t, "xxxyyy", task.ID)
+}
+
+func testListTasks(t *testing.T, s store.Store) {
+	s.SaveTask(&types.Task{ID: "xxxyyy", Name: "x.y.z", AppId: "m"})
+	s.SaveTask(&types.Task{ID: "mmmnnn", Name: "m.n.l", AppId: "m"})
+	s.SaveTask(&types.Task{ID: "zzzzzz", Name: "z.z.z", AppId: "z"})
+
+	tasks, _ := s.ListTasks("m")
+	assert.Equal(t, 2, len(tasks))
+}
+
+func testDeleteTask(t *testing.T, s store.Store) {
+	s.SaveTask(&types.Task{ID: "xxxyyy", Name: "x.y.z", AppId: "m"})
+
+	assert.Nil(t, s.DeleteTask("x.y.z"))
+
+	task, _ := s.FetchTask("x.y.z")
+	assert.Nil(t, task)
+}
+
+func testVersions(t *testing.T, s store.Store) {
+	assert.Nil(t, s.SaveVersion(&types.Version{ID: "xxxxxx"}))
+
+	versions, _ := s.ListVersions("xxxxxx")
+	assert.Equal(t, 1, len(versions))
+
+	version, _ := s.FetchVersion(versions[0])
+	assert.Equal(t, "xxxxxx", version.ID)
+
+	version, _ = s.FetchVersion("yyxxzz")
+	assert.Nil(t, version)
+
+	assert.Nil(t, s.DeleteVersion(versions[0]))
+
+	versions, _ = s.ListVersions("xxxxxx")
+	assert.Equal(t, 0, len(versions))
+}
+
+func testChecks(t *testing.T, s store.Store) {
+	task := &types.Task{
+		Name:          "xxxxxx",
+		AppId:         "abc",
+		AgentHostname: proto.String("x.x.x.x"),
+		Ports:         []uint32{8080},
+		HealthChecks: []*types.HealthCheck{
+			&types.HealthCheck{
+				Protocol:        "http",
+				IntervalSeconds: 2,
+				TimeoutSeconds:  2,
+				Path:            proto.String("/"),
+			},
+			&types.HealthCheck{
+				Protocol: "tcp",
+			},
+		},
+	}
+
+	assert.Nil(t, s.SaveCheck(task))
+
+	// Every health check of a task has a check of its own.
+	checks, _ := s.ListChecks()
+	assert.Equal(t, 2, len(checks))
+	assert.Equal(t, 8080, checks[0].Port)
+	assert.Equal(t, "xxxxxx-0", checks[0].ID)
+	assert.Equal(t, "xxxxxx-1", checks[1].ID)
+	assert.Equal(t, "xxxxxx", checks[1].TaskID)
+
+	// Statuses are saved with the checks of the same launch only.
+	err := s.Update(func(tx store.Tx) error {
+		status := &types.CheckStatus{ID: "xxxxxx-0", Result: "unhealthy", ConsecutiveFailures: 2}
+		if err := tx.SaveCheckStatus(&types.Check{ID: "xxxxxx-0", Launched: checks[0].Launched, Status: status}); err != nil {
+			return err
+		}
+
+		stale := &types.CheckStatus{ID: "xxxxxx-1", Result: "unhealthy"}
+		if err := tx.SaveCheckStatus(&types.Check{ID: "xxxxxx-1", Launched: checks[1].Launched - 1, Status: stale}); err != nil {
+			return err
+		}
+
+		return tx.SaveCheckStatus(&types.Check{ID: "yyyyyy-0", Status: status})
+	})
+	assert.Nil(t, err)
+
+	checks, _ = s.ListChecks()
+	assert.Equal(t, 2, len(checks))
+	assert.Equal(t, 2, checks[0].Status.ConsecutiveFailures)
+	assert.Nil(t, checks[1].Status)
+
+	// Saving the checks of the same launch again keeps their statuses.
+	assert.Nil(t, s.SaveCheck(task))
+
+	checks, _ = s.ListChecks()
+	assert.Equal(t, 2, len(checks))
+	assert.Equal(t, 2, checks[0].Status.ConsecutiveFailures)
+
+	// Saving the checks of a task again replaces them.
+	task.HealthChecks = task.HealthChecks[:1]
+	assert.Nil(t, s.SaveCheck(task))
+	assert.Nil(t, s.SaveCheck(&types.Task{Name: "xxxxxx1", AppId: "abc", AgentHostname: proto.String("x.x.x.x"), Ports: []uint32{8080}, HealthChecks: task.HealthChecks}))
+
+	checks, _ = s.ListChecks()
+	assert.Equal(t, 2, len(checks))
+
+	assert.Nil(t, s.DeleteCheck("xxxxxx"))
+
+	checks, _ = s.ListChecks()
+	assert.Equal(t, 1, len(checks))
+	assert.Equal(t, "xxxxxx1", checks[0].TaskID)
+
+	assert.Nil(t, s.DeleteCheck("xxxxxx1"))
+
+	checks, _ = s.ListChecks()
+	assert.Equal(t, 0, len(checks))
+}
+
+func testDeployments(t *testing.T, s store.Store) {
+	deployment := &types.Deployment{ID: "1", AppID: "xxxxxx", Type: "UPDATE", Status: "RUNNING"}
+	s.SaveDeployment(deployment)
+
+	deployment.Completed = 2
+	s.SaveDeployment(deployment)
+
+	deployment, _ = s.FetchDeployment("1")
+	assert.Equal(t, 2, deployment.Completed)
+
+	deployment, err := s.FetchDeployment("2")
+	assert.Nil(t, deployment)
+	assert.NotNil(t, err)
+
+	s.SaveDeployment(&types.Deployment{ID: "2", AppID: "xxxxxx"})
+	s.SaveDeployment(&types.Deployment{ID: "3", AppID: "yyyyyy"})
+
+	deployments, _ := s.ListDeployments("xxxxxx")
+	assert.Equal(t, 2, len(deployments))
+
+	assert.Nil(t, s.DeleteDeployment("1"))
+
+	deployments, _ = s.ListDeployments("xxxxxx")
+	assert.Equal(t, 1, len(deployments))
+	assert.Equal(t, "2", deployments[0].ID)
+}
+
+// appendAudit appends entries at the given times in one update.
+func appendAudit(t *testing.T, s store.Store, entries ...*types.AuditEntry) {
+	err := s.Update(func(tx store.Tx) error {
+		for _, entry := range entries {
+			if err := tx.AppendAuditEntry(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func testAudit(t *testing.T, s store.Store) {
+	appendAudit(t, s,
+		&types.AuditEntry{Time: 100, Actor: "api:alice@10.0.0.1", Operation: "app.create", AppID: "test"},
+		&types.AuditEntry{Time: 200, Actor: types.ActorScheduler, Operation: "task.status", AppID: "test"},
+		&types.AuditEntry{Time: 300, Actor: "api:bob@10.0.0.2", Operation: "app.scale", AppID: "test"},
+		&types.AuditEntry{Time: 400, Actor: "api:alice@10.0.0.1", Operation: "app.create", AppID: "other"},
+	)
+
+	entries, err := s.ListAuditEntries(&types.AuditQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(entries))
+
+	// Ids are filled in and sort in the order of the log.
+	for i := 1; i < len(entries); i++ {
+		assert.True(t, entries[i-1].ID < entries[i].ID)
+	}
+	assert.Equal(t, "app.create", entries[0].Operation)
+
+	entries, _ = s.ListAuditEntries(&types.AuditQuery{AppID: "test"})
+	assert.Equal(t, 3, len(entries))
+
+	entries, _ = s.ListAuditEntries(&types.AuditQuery{Actor: "api"})
+	assert.Equal(t, 3, len(entries))
+
+	entries, _ = s.ListAuditEntries(&types.AuditQuery{AppID: "test", Since: 200})
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "task.status", entries[0].Operation)
+
+	// Limited queries return the newest entries, still oldest first.
+	entries, _ = s.ListAuditEntries(&types.AuditQuery{Limit: 2})
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "app.scale", entries[0].Operation)
+	assert.Equal(t, "other", entries[1].AppID)
+}
+
+func testAuditRollback(t *testing.T, s store.Store) {
+	err := s.Update(func(tx store.Tx) error {
+		if err := tx.AppendAuditEntry(&types.AuditEntry{Time: 100, Operation: "app.create"}); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	assert.NotNil(t, err)
+
+	appendAudit(t, s, &types.AuditEntry{Time: 200, Operation: "app.delete"})
+
+	entries, _ := s.ListAuditEntries(&types.AuditQuery{})
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "app.delete", entries[0].Operation)
+
+	err = s.View(func(tx store.Tx) error {
+		return tx.AppendAuditEntry(&types.AuditEntry{Time: 300})
+	})
+	assert.NotNil(t, err)
+}
+
+func testPruneAudit(t *testing.T, s store.Store) {
+	for i := 1; i <= 5; i++ {
+		appendAudit(t, s, &types.AuditEntry{Time: int64(i * 100)})
+	}
+
+	assert.Nil(t, s.PruneAuditEntries(200, 0))
+
+	entries, _ := s.ListAuditEntries(&types.AuditQuery{})
+	assert.Equal(t, 4, len(entries))
+	assert.Equal(t, int64(200), entries[0].Time)
+
+	assert.Nil(t, s.PruneAuditEntries(0, 2))
+
+	entries, _ = s.ListAuditEntries(&types.AuditQuery{})
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, int64(400), entries[0].Time)
+
+	appendAudit(t, s, &types.AuditEntry{Time: 600})
+
+	entries, _ = s.ListAuditEntries(&types.AuditQuery{})
+	assert.Equal(t, 3, len(entries))
+	assert.True(t, entries[1].ID < entries[2].ID)
+}
+
+func testSubscriptions(t *testing.T, s store.Store) {
+	subscriptions, err := s.ListSubscriptions()
+	assert.Nil(t, err)
+	assert.NotNil(t, subscriptions)
+	assert.Equal(t, 0, len(subscriptions))
+
+	s.SaveSubscription(&types.Subscription{ID: "1", URL: "http://x/hook", AppID: "test", Types: []string{types.EventAppStatus}})
+	s.SaveSubscription(&types.Subscription{ID: "2", URL: "http://y/hook"})
+
+	subscription, err := s.FetchSubscription("1")
+	assert.Nil(t, err)
+	assert.Equal(t, "http://x/hook", subscription.URL)
+	assert.Equal(t, []string{types.EventAppStatus}, subscription.Types)
+
+	subscription, err = s.FetchSubscription("3")
+	assert.Nil(t, subscription)
+	assert.NotNil(t, err)
+
+	subscriptions, _ = s.ListSubscriptions()
+	assert.Equal(t, 2, len(subscriptions))
+
+	assert.Nil(t, s.DeleteSubscription("1"))
+	assert.Nil(t, s.DeleteSubscription("3"))
+
+	subscriptions, _ = s.ListSubscriptions()
+	assert.Equal(t, 1, len(subscriptions))
+	assert.Equal(t, "2", subscriptions[0].ID)
+}
+
+func testDeadLetters(t *testing.T, s store.Store) {
+	s.SaveSubscription(&types.Subscription{ID: "1", URL: "http://x/hook"})
+	s.SaveSubscription(&types.Subscription{ID: "2", URL: "http://y/hook"})
+
+	event := &types.Event{ID: 7, Type: types.EventAppStatus}
+	s.SaveDeadLetter(&types.DeadLetter{ID: "a", SubscriptionID: "1", Event: event, Attempts: 5})
+	s.SaveDeadLetter(&types.DeadLetter{ID: "b", SubscriptionID: "1", Event: event})
+	s.SaveDeadLetter(&types.DeadLetter{ID: "c", SubscriptionID: "2", Event: event})
+
+	letters, err := s.ListDeadLetters("1")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(letters))
+	assert.Equal(t, "a", letters[0].ID)
+	assert.Equal(t, uint64(7), letters[0].Event.ID)
+	assert.Equal(t, 5, letters[0].Attempts)
+
+	assert.Nil(t, s.DeleteDeadLetter("a"))
+
+	letters, _ = s.ListDeadLetters("1")
+	assert.Equal(t, 1, len(letters))
+
+	// Dead letters go with their subscription.
+	assert.Nil(t, s.DeleteSubscription("1"))
+
+	letters, _ = s.ListDeadLetters("1")
+	assert.Equal(t, 0, len(letters))
+
+	letters, _ = s.ListDeadLetters("2")
+	assert.Equal(t, 1, len(letters))
+}
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"strings"
+)
+
+// Actors of the changes made by swan itself, changes requested through the
+// API are recorded with the caller as actor.
+const (
+	ActorScheduler = "scheduler"
+	ActorHealth    = "health"
+	ActorConverger = "converger"
+)
+
+// AuditEntry records a change of the state of an application, one of its
+// versions, tasks or health checks.
+type AuditEntry struct {
+	ID        string `json:"id"`
+	Time      int64  `json:"time"`
+	Actor     string `json:"actor"`
+	Operation string `json:"operation"`
+	AppID     string `json:"appId"`
+	Object    string `json:"object,omitempty"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+}
+
+// AuditQuery selects audit entries, empty fields match every entry.
+type AuditQuery struct {
+	AppID string
+
+	// Actor matches the entries whose actor starts with it.
+	Actor string
+
+	// Since is the unix time of the oldest entry.
+	Since int64
+
+	// Limit keeps the newest entries only, zero means no limit.
+	Limit int
+}
+
+// Match reports whether entry is selected by q, the limit aside.
+func (q *AuditQuery) Match(entry *AuditEntry) bool {
+	if q.AppID != "" && entry.AppID != q.AppID {
+		return false
+	}
+
+	if q.Actor != "" && !strings.HasPrefix(entry.Actor, q.Actor) {
+		return false
+	}
+
+	return entry.Time >= q.Since
+}
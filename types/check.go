@@ -12,4 +12,44 @@ type Check struct {
 	Timeout     int      `json:"timeout"`
 	TaskID      string   `json:"task_id"`
 	AppID       string   `json:"app_id"`
+
+	// Failures in the first GracePeriod seconds since Launched, a unix
+	// time, do not count until the check succeeded once.
+	GracePeriod int   `json:"grace_period,omitempty"`
+	Launched    int64 `json:"launched,omitempty"`
+
+	// Readiness checks run until they pass once and do not count towards
+	// the health of the task.
+	Readiness bool `json:"readiness,omitempty"`
+
+	// Status is the state of the check when it was last saved, restored
+	// when the check is registered again.
+	Status *CheckStatus `json:"status,omitempty"`
+
+	Method        string            `json:"method,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	StatusCodes   []string          `json:"status_codes,omitempty"`
+	ExpectedBody  string            `json:"expected_body,omitempty"`
+	IgnoreHttp1xx bool              `json:"ignore_http1xx,omitempty"`
+}
+
+// CheckStatus is the state of a check of a task. Result is "healthy" or
+// "unhealthy" after the last counted run and "unknown" before the first.
+// Times are unix seconds, 0 if it never happened.
+type CheckStatus struct {
+	ID                  string `json:"id"`
+	Protocol            string `json:"protocol"`
+	Result              string `json:"result"`
+	LastSuccess         int64  `json:"lastSuccess"`
+	LastFailure         int64  `json:"lastFailure"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+}
+
+// TaskHealth is the health of a task, "healthy" if all its checks are,
+// "unhealthy" if any of them is and "unknown" otherwise.
+type TaskHealth struct {
+	TaskID string         `json:"taskId"`
+	Health string         `json:"health"`
+	Checks []*CheckStatus `json:"checks"`
 }
@@ -0,0 +1,11 @@
+package types
+
+// ConvergeAction is a correction made, or planned in dry-run mode, by the
+// convergence loop to bring an application back to its desired state.
+type ConvergeAction struct {
+	AppID  string `json:"appId"`
+	Task   string `json:"task"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+}
@@ -0,0 +1,22 @@
+package types
+
+// Deployment records the progress of a rolling-update, scale or rollback so
+// that it can be resumed if swan restarts before it has finished.
+type Deployment struct {
+	ID        string `json:"id"`
+	AppID     string `json:"appId"`
+	Type      string `json:"type"`
+	Actor     string `json:"actor,omitempty"`
+	VersionID string `json:"versionId"`
+	Begin     int    `json:"begin"`
+	End       int    `json:"end"`
+	Completed int    `json:"completed"`
+	Instances int    `json:"instances"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	Created   int64  `json:"created"`
+	Updated   int64  `json:"updated"`
+
+	// Tasks names the tasks an update or rollback replaces, in order.
+	Tasks []string `json:"tasks,omitempty"`
+}
@@ -1,7 +1,30 @@
 package types
 
+// Types of the events published on the event bus.
+const (
+	EventTaskStatus          = "task_status"
+	EventTaskFailover        = "task_failover"
+	EventTaskReady           = "task_ready"
+	EventAppCreated          = "app_created"
+	EventAppDeleted          = "app_deleted"
+	EventAppStatus           = "app_status"
+	EventDeploymentStarted   = "deployment_started"
+	EventDeploymentStep      = "deployment_step"
+	EventDeploymentFinished  = "deployment_finished"
+	EventHealthStatus        = "health_status"
+	EventSubscriptionCreated = "subscription_created"
+	EventSubscriptionDeleted = "subscription_deleted"
+)
+
+// Event reports a change of an application, one of its tasks, deployments,
+// health checks or of a subscription. ID and Time are set when the event is
+// published.
 type Event struct {
-	ID      string `json:"ID"`
-	Message string `json:"message"`
-	Status  string `json:"status"`
+	ID      uint64 `json:"id"`
+	Type    string `json:"type"`
+	Time    int64  `json:"time"`
+	AppID   string `json:"appId,omitempty"`
+	TaskID  string `json:"taskId,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
 }
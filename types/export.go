@@ -0,0 +1,22 @@
+package types
+
+// Export holds the definitions of applications without their runtime state,
+// so they can be imported by another swan.
+type Export struct {
+	Created      int64                  `json:"created"`
+	Applications []*ExportedApplication `json:"applications"`
+}
+
+// ExportedApplication is an application with its versions, oldest first.
+type ExportedApplication struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	UserId   string     `json:"userId"`
+	Versions []*Version `json:"versions"`
+}
+
+// ImportResult is the outcome of importing one application.
+type ImportResult struct {
+	AppID string `json:"appId"`
+	Error string `json:"error,omitempty"`
+}
@@ -15,8 +15,33 @@ type HealthCheck struct {
 	GracePeriodSeconds     int64    `json:"gracePeriodSeconds,omitempty"`
 	IntervalSeconds        int64    `json:"intervalSeconds,omitempty"`
 	TimeoutSeconds         int64    `json:"timeoutSeconds,omitempty"`
+
+	// The options below apply to HTTP and HTTPS checks. Method defaults to
+	// GET and StatusCodes, codes like "200" or ranges like "200-399", to
+	// 200-399. A response is healthy if its status is accepted and its
+	// body contains ExpectedBody. Informational responses, interim ones
+	// included, are failures unless IgnoreHttp1xx is set, which ignores
+	// them.
+	Method        string            `json:"method,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	StatusCodes   []string          `json:"statusCodes,omitempty"`
+	ExpectedBody  string            `json:"expectedBody,omitempty"`
+	IgnoreHttp1xx bool              `json:"ignoreHttp1xx,omitempty"`
 }
 
 type Command struct {
 	Value string `json:"value"`
 }
+
+// ReadinessCheck is the definition of a check which tells when a new
+// instance is ready to serve. It runs until it passes once, an HTTP or
+// HTTPS request to Path on the port mapping at PortIndex answered with one
+// of StatusCodes, 200-399 by default.
+type ReadinessCheck struct {
+	Protocol        string   `json:"protocol,omitempty"`
+	Path            string   `json:"path,omitempty"`
+	PortIndex       int      `json:"portIndex,omitempty"`
+	StatusCodes     []string `json:"statusCodes,omitempty"`
+	IntervalSeconds int64    `json:"intervalSeconds,omitempty"`
+	TimeoutSeconds  int64    `json:"timeoutSeconds,omitempty"`
+}
@@ -0,0 +1,13 @@
+package types
+
+// Orphan is a task running under the framework of swan which swan has lost
+// track of.
+type Orphan struct {
+	ID        string `json:"id"`
+	AppID     string `json:"appId"`
+	AgentID   string `json:"agentId"`
+	State     string `json:"state"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+	FirstSeen int64  `json:"firstSeen"`
+}
@@ -1,7 +1,17 @@
 package types
 
+import "errors"
+
+// ErrFailoverDeferred is the answer to a ReschedulerMsg whose failover the
+// limits of the application deferred.
+var ErrFailoverDeferred = errors.New("Failover deferred")
+
 type ReschedulerMsg struct {
 	AppID  string
 	TaskID string
 	Err    chan error
+
+	// Recovered reports that the task passed its health checks again, its
+	// deferred failover is dropped. Nothing is answered on Err.
+	Recovered bool
 }
@@ -0,0 +1,42 @@
+package types
+
+// Subscription registers a callback URL to which the events selected by
+// AppID and Types are posted. Empty AppID or Types match every event.
+type Subscription struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	AppID  string   `json:"appId,omitempty"`
+	Types  []string `json:"types,omitempty"`
+	Secret string   `json:"secret,omitempty"`
+
+	Created int64 `json:"created"`
+}
+
+// Match reports whether event is selected by s.
+func (s *Subscription) Match(event *Event) bool {
+	if s.AppID != "" && event.AppID != s.AppID {
+		return false
+	}
+
+	if len(s.Types) == 0 {
+		return true
+	}
+
+	for _, t := range s.Types {
+		if event.Type == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DeadLetter is an event which could not be delivered to a subscription.
+type DeadLetter struct {
+	ID             string `json:"id"`
+	SubscriptionID string `json:"subscriptionId"`
+	Event          *Event `json:"event"`
+	Attempts       int    `json:"attempts"`
+	Error          string `json:"error"`
+	Time           int64  `json:"time"`
+}
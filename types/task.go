@@ -24,13 +24,33 @@ type Task struct {
 	Labels         *map[string]string `json:"labels"`
 	HealthChecks   []*HealthCheck     `json:"health_checks"`
 
+	// Ready tells the readiness checks of the task passed, tasks without
+	// readiness checks are ready once they run.
+	ReadinessChecks []*ReadinessCheck `json:"readiness_checks,omitempty"`
+	Ready           bool              `json:"ready"`
+
 	OfferId       *string `json:"offer_id"`
-	AgentId       *string `json:"agent_id,string"`
+	AgentId       *string `json:"agent_id"`
 	AgentHostname *string `json:"agent_hostname"`
 	Status        string  `json:"status"`
 	AppId         string  `json:"app_id"`
 
+	// Ports are the host ports allocated to the port mappings, in order,
+	// and IPAddress the address of the container on a USER network.
+	Ports     []uint32 `json:"ports,omitempty"`
+	IPAddress string   `json:"ip_address,omitempty"`
+
+	// Health is "healthy", "unhealthy" or "unknown" for tasks with health
+	// checks, since HealthSince, a unix time, with HealthMessage the output
+	// of the check which decided it.
+	Health        string `json:"health,omitempty"`
+	HealthSince   int64  `json:"health_since,omitempty"`
+	HealthMessage string `json:"health_message,omitempty"`
+
 	KillPolicy *KillPolicy `json:"kill_policy"`
+
+	UnreachablePolicy *UnreachablePolicy `json:"unreachable_policy"`
+	UnreachableSince  int64              `json:"unreachable_since"`
 }
 
 type PortMappings struct {
@@ -0,0 +1,13 @@
+package types
+
+// UnreachablePolicy decides what happens to the tasks of an application when
+// their agent fails or is partitioned from the master.
+type UnreachablePolicy struct {
+	// RescheduleAfterSeconds is how long to wait for the agent to come back
+	// before the tasks are launched somewhere else.
+	RescheduleAfterSeconds int64 `json:"rescheduleAfterSeconds"`
+
+	// WaitForever never reschedules the tasks, for stateful applications
+	// bound to the data on their agent.
+	WaitForever bool `json:"waitForever"`
+}
@@ -5,4 +5,8 @@ type UpdatePolicy struct {
 	MaxRetries   int    `json:"maxRetries"`
 	MaxFailovers int    `json:"maxFailovers"`
 	Action       string `json:"action"`
+
+	// ReadyTimeout is how long, in seconds, a rolling update waits for a
+	// new instance with readiness checks to become ready, 600 if unset.
+	ReadyTimeout int `json:"readyTimeout,omitempty"`
 }
@@ -10,9 +10,14 @@ type Version struct {
 	Container    *Container         `json:"container"`
 	Labels       *map[string]string `json:"labels"`
 	HealthChecks []*HealthCheck     `json:"healthChecks"`
-	Env          map[string]string  `json:"env"`
-	KillPolicy   *KillPolicy        `json:"killPolicy"`
-	UpdatePolicy *UpdatePolicy      `json:"updatePolicy"`
+
+	ReadinessChecks []*ReadinessCheck `json:"readinessChecks,omitempty"`
+
+	Env          map[string]string `json:"env"`
+	KillPolicy   *KillPolicy       `json:"killPolicy"`
+	UpdatePolicy *UpdatePolicy     `json:"updatePolicy"`
+
+	UnreachablePolicy *UnreachablePolicy `json:"unreachablePolicy"`
 }
 
 // Container is the definition for a container type in marathon
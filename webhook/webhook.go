@@ -0,0 +1,380 @@
+// Package webhook posts the events of the event bus to the callback URLs
+// of subscriptions.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/event"
+	"github.com/Dataman-Cloud/swan/store"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/Sirupsen/logrus"
+)
+
+// SignatureHeader holds the HMAC-SHA256 of the body, keyed with the secret
+// of the subscription, as sha256=<hex>.
+const SignatureHeader = "X-Swan-Signature"
+
+// Store is the part of the store used by the dispatcher.
+type Store interface {
+	ListSubscriptions() ([]*types.Subscription, error)
+	SaveDeadLetter(*types.DeadLetter) error
+	ListDeadLetters(string) ([]*types.DeadLetter, error)
+	DeleteDeadLetter(string) error
+}
+
+// Config configures the delivery of events.
+type Config struct {
+	// MaxAttempts is the number of posts of an event before it is moved
+	// to the dead letters of the subscription.
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, it doubles with every
+	// retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Timeout of a single post.
+	Timeout time.Duration
+
+	// QueueSize is the number of events waiting for delivery to a
+	// subscriber, events beyond it go to the dead letters right away.
+	QueueSize int
+
+	// MaxDeadLetters is the number of dead letters kept per subscription.
+	MaxDeadLetters int
+}
+
+// DefaultConfig is the delivery configuration used by swan.
+var DefaultConfig = Config{
+	MaxAttempts:    5,
+	Backoff:        time.Second,
+	MaxBackoff:     time.Minute,
+	Timeout:        10 * time.Second,
+	QueueSize:      100,
+	MaxDeadLetters: 100,
+}
+
+// Dispatcher delivers the events of a bus to the subscriptions in the
+// store. Every subscriber has its own queue, so a slow or failing one does
+// not hold up the others.
+type Dispatcher struct {
+	store  Store
+	bus    *event.Bus
+	config Config
+	client *http.Client
+
+	// subscriptions caches the subscriptions of the store, they are
+	// reloaded when stale, on the events of subscription changes. Only
+	// the dispatching goroutine uses them.
+	subscriptions []*types.Subscription
+	stale         bool
+
+	mu      sync.Mutex
+	workers map[string]*worker
+
+	letterMu   sync.Mutex
+	lastLetter string
+
+	quit chan struct{}
+}
+
+// NewDispatcher returns a dispatcher of the events of bus.
+func NewDispatcher(store Store, bus *event.Bus, config Config) *Dispatcher {
+	return &Dispatcher{
+		store:   store,
+		bus:     bus,
+		config:  config,
+		client:  &http.Client{Timeout: config.Timeout},
+		stale:   true,
+		workers: make(map[string]*worker),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start starts delivering events.
+func (d *Dispatcher) Start() {
+	go d.run()
+}
+
+// Stop stops delivering events, events waiting for delivery are dropped.
+func (d *Dispatcher) Stop() {
+	close(d.quit)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for id, w := range d.workers {
+		close(w.quit)
+		delete(d.workers, id)
+	}
+}
+
+func (d *Dispatcher) run() {
+	var lastID uint64
+	for {
+		sub, replay := d.bus.Subscribe(event.Filter{}, lastID)
+		for _, e := range replay {
+			d.dispatch(e)
+			lastID = e.ID
+		}
+
+		if !d.consume(sub, &lastID) {
+			sub.Close()
+			return
+		}
+
+		logrus.Warnf("Webhook dispatcher fell behind, resume from event %d", lastID)
+	}
+}
+
+// consume dispatches the events of sub until it is dropped, it returns
+// false if the dispatcher stopped.
+func (d *Dispatcher) consume(sub *event.Subscription, lastID *uint64) bool {
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return true
+			}
+
+			d.dispatch(e)
+			*lastID = e.ID
+		case <-d.quit:
+			return false
+		}
+	}
+}
+
+// dispatch queues e for every subscription it matches, events which find
+// the queue of their subscriber full become dead letters.
+func (d *Dispatcher) dispatch(e *types.Event) {
+	if e.Type == types.EventSubscriptionCreated || e.Type == types.EventSubscriptionDeleted {
+		d.stale = true
+	}
+
+	if d.stale {
+		d.refresh()
+	}
+
+	for _, subscription := range d.enqueue(e) {
+		d.deadLetter(subscription, e, 0, "Delivery queue is full")
+	}
+}
+
+// refresh reloads the subscriptions, the cached ones are kept if the store
+// fails and reloading is retried on the next event.
+func (d *Dispatcher) refresh() {
+	subscriptions, err := d.store.ListSubscriptions()
+	if err != nil {
+		logrus.Errorf("List subscriptions failed: %s", err.Error())
+		return
+	}
+
+	d.subscriptions = subscriptions
+	d.stale = false
+}
+
+// enqueue queues e for every subscription it matches and returns the ones
+// whose queue was full. The workers of deleted subscriptions are stopped on
+// the way.
+func (d *Dispatcher) enqueue(e *types.Event) []*types.Subscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var full []*types.Subscription
+
+	current := make(map[string]bool)
+	for _, subscription := range d.subscriptions {
+		current[subscription.ID] = true
+
+		if !subscription.Match(e) {
+			continue
+		}
+
+		w, ok := d.workers[subscription.ID]
+		if !ok {
+			w = &worker{
+				dispatcher:   d,
+				subscription: subscription,
+				queue:        make(chan *types.Event, d.config.QueueSize),
+				quit:         make(chan struct{}),
+			}
+			d.workers[subscription.ID] = w
+
+			go w.run()
+		}
+
+		if !w.enqueue(e) {
+			full = append(full, subscription)
+		}
+	}
+
+	for id, w := range d.workers {
+		if !current[id] {
+			close(w.quit)
+			delete(d.workers, id)
+		}
+	}
+
+	return full
+}
+
+// deadLetter records that e could not be delivered to subscription and
+// drops the oldest dead letters beyond the limit.
+func (d *Dispatcher) deadLetter(subscription *types.Subscription, e *types.Event, attempts int, reason string) {
+	logrus.Errorf("Deliver event %d to subscription %s failed after %d attempts: %s", e.ID, subscription.ID, attempts, reason)
+
+	letter := &types.DeadLetter{
+		ID:             d.nextLetterID(),
+		SubscriptionID: subscription.ID,
+		Event:          e,
+		Attempts:       attempts,
+		Error:          reason,
+		Time:           time.Now().Unix(),
+	}
+
+	if err := d.store.SaveDeadLetter(letter); err != nil {
+		logrus.Errorf("Save dead letter of subscription %s failed: %s", subscription.ID, err.Error())
+		return
+	}
+
+	letters, err := d.store.ListDeadLetters(subscription.ID)
+	if err != nil {
+		logrus.Errorf("List dead letters of subscription %s failed: %s", subscription.ID, err.Error())
+		return
+	}
+
+	for i := 0; i < len(letters)-d.config.MaxDeadLetters; i++ {
+		if err := d.store.DeleteDeadLetter(letters[i].ID); err != nil {
+			logrus.Errorf("Delete dead letter %s failed: %s", letters[i].ID, err.Error())
+		}
+	}
+}
+
+// nextLetterID returns a dead letter id, unique even for the dead letters of
+// concurrent workers.
+func (d *Dispatcher) nextLetterID() string {
+	d.letterMu.Lock()
+	defer d.letterMu.Unlock()
+
+	d.lastLetter = store.NextID(d.lastLetter)
+
+	return d.lastLetter
+}
+
+// Sign returns the signature of body with secret, as sent in
+// SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// worker delivers the events of one subscription in order.
+type worker struct {
+	dispatcher   *Dispatcher
+	subscription *types.Subscription
+	queue        chan *types.Event
+	quit         chan struct{}
+}
+
+func (w *worker) run() {
+	for {
+		select {
+		case e := <-w.queue:
+			w.deliver(e)
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// enqueue queues e without blocking, it returns false if the queue is full.
+func (w *worker) enqueue(e *types.Event) bool {
+	select {
+	case w.queue <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver posts e until it is accepted, retrying with backoff, and moves
+// it to the dead letters if it never is.
+func (w *worker) deliver(e *types.Event) {
+	config := w.dispatcher.config
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		logrus.Errorf("Encode event %d failed: %s", e.ID, err.Error())
+		return
+	}
+
+	backoff := config.Backoff
+	attempts := 0
+	for {
+		attempts++
+		if err = w.post(e, body); err == nil {
+			return
+		}
+
+		logrus.Warnf("Deliver event %d to subscription %s failed: %s", e.ID, w.subscription.ID, err.Error())
+
+		if attempts >= config.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-w.quit:
+			return
+		}
+
+		backoff *= 2
+		if backoff > config.MaxBackoff {
+			backoff = config.MaxBackoff
+		}
+	}
+
+	w.dispatcher.deadLetter(w.subscription, e, attempts, err.Error())
+}
+
+func (w *worker) post(e *types.Event, body []byte) error {
+	req, err := http.NewRequest("POST", w.subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Swan-Event", e.Type)
+	req.Header.Set("X-Swan-Delivery", fmt.Sprintf("%d", e.ID))
+	if w.subscription.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.subscription.Secret, body))
+	}
+
+	resp, err := w.dispatcher.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
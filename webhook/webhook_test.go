@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dataman-Cloud/swan/event"
+	"github.com/Dataman-Cloud/swan/store/memory"
+	"github.com/Dataman-Cloud/swan/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = Config{
+	MaxAttempts:    3,
+	Backoff:        time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+	Timeout:        time.Second,
+	QueueSize:      10,
+	MaxDeadLetters: 2,
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []*types.Event
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, Sign("s3cret", body), r.Header.Get(SignatureHeader))
+		assert.Equal(t, types.EventDeploymentFinished, r.Header.Get("X-Swan-Event"))
+
+		var e types.Event
+		assert.Nil(t, json.Unmarshal(body, &e))
+
+		mu.Lock()
+		received = append(received, &e)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	db := memory.NewMemoryStore()
+	db.SaveSubscription(&types.Subscription{
+		ID:     "1",
+		URL:    server.URL,
+		AppID:  "foo",
+		Types:  []string{types.EventDeploymentFinished},
+		Secret: "s3cret",
+	})
+
+	bus := event.NewBus(10)
+	d := NewDispatcher(db, bus, testConfig)
+	d.Start()
+	defer d.Stop()
+
+	// wait for the dispatcher to subscribe to the bus.
+	time.Sleep(50 * time.Millisecond)
+
+	bus.Publish(&types.Event{Type: types.EventTaskStatus, AppID: "foo"})
+	bus.Publish(&types.Event{Type: types.EventDeploymentFinished, AppID: "bar"})
+	bus.Publish(&types.Event{Type: types.EventDeploymentFinished, AppID: "foo", Status: "RUNNING"})
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 1
+	})
+
+	assert.Equal(t, uint64(3), received[0].ID)
+	assert.Equal(t, "RUNNING", received[0].Status)
+}
+
+func TestRetryAndDeadLetter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		n := attempts
+		mu.Unlock()
+
+		// the first event succeeds on its second attempt.
+		if n == 2 {
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	db := memory.NewMemoryStore()
+	db.SaveSubscription(&types.Subscription{ID: "1", URL: server.URL})
+
+	bus := event.NewBus(10)
+	d := NewDispatcher(db, bus, testConfig)
+	d.Start()
+	defer d.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		bus.Publish(&types.Event{Type: types.EventTaskStatus})
+	}
+
+	// 2 attempts for the first event and 3 for each of the others.
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts == 11
+	})
+
+	waitFor(t, func() bool {
+		letters, _ := db.ListDeadLetters("1")
+		return len(letters) == 2 && letters[1].Event.ID == 4
+	})
+
+	letters, _ := db.ListDeadLetters("1")
+	assert.Equal(t, uint64(3), letters[0].Event.ID)
+	assert.Equal(t, 3, letters[1].Attempts)
+	assert.Equal(t, "Unexpected status code 500", letters[1].Error)
+}
+
+func TestDeletedSubscription(t *testing.T) {
+	db := memory.NewMemoryStore()
+	db.SaveSubscription(&types.Subscription{ID: "1", URL: "http://127.0.0.1:1"})
+
+	bus := event.NewBus(10)
+	d := NewDispatcher(db, bus, testConfig)
+
+	d.dispatch(&types.Event{ID: 1, Type: types.EventTaskStatus})
+	assert.Len(t, d.workers, 1)
+
+	db.DeleteSubscription("1")
+
+	// the subscriptions are cached until the bus reports a change.
+	d.dispatch(&types.Event{ID: 2, Type: types.EventTaskStatus})
+	assert.Len(t, d.workers, 1)
+
+	d.dispatch(&types.Event{ID: 3, Type: types.EventSubscriptionDeleted})
+	assert.Len(t, d.workers, 0)
+
+	d.Stop()
+}
+
+func TestFullQueue(t *testing.T) {
+	db := memory.NewMemoryStore()
+	db.SaveSubscription(&types.Subscription{ID: "1", URL: "http://127.0.0.1:1"})
+
+	config := testConfig
+	config.MaxDeadLetters = 10
+
+	bus := event.NewBus(10)
+	d := NewDispatcher(db, bus, config)
+
+	// a worker which does not run, with a full queue.
+	d.workers["1"] = &worker{
+		dispatcher: d,
+		queue:      make(chan *types.Event, 1),
+		quit:       make(chan struct{}),
+	}
+	d.workers["1"].queue <- &types.Event{ID: 1}
+
+	for i := uint64(2); i <= 4; i++ {
+		d.dispatch(&types.Event{ID: i, Type: types.EventTaskStatus})
+	}
+
+	letters, _ := db.ListDeadLetters("1")
+	assert.Len(t, letters, 3)
+	for i, letter := range letters {
+		assert.Equal(t, uint64(i+2), letter.Event.ID)
+		assert.Equal(t, "Delivery queue is full", letter.Error)
+	}
+
+	d.Stop()
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", Sign("key", []byte("The quick brown fox jumps over the lazy dog")))
+}